@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/Adjanour/vesper/internal/config"
+	"github.com/Adjanour/vesper/internal/database"
+	"github.com/Adjanour/vesper/internal/database/migrate"
+	"github.com/Adjanour/vesper/internal/database/migrations"
+)
+
+const usage = `Usage: go run ./cmd/migrate [-db file] [-dir directory] <command>
+
+Commands:
+  status          list migrations and whether each is applied
+  up [N]          apply all pending migrations, or only the next N
+  down [N]        roll back the most recent migration, or the last N
+  goto VERSION    migrate up or down to VERSION (0 rolls everything back)
+
+Flags:
+`
+
+func main() {
+	// the database defaults to the server's, from DATABASE_PATH or DATA_DIR
+	cfg, err := config.Load(nil, os.LookupEnv)
+	if err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+
+	dbPath := flag.String("db", cfg.Database.Path, "SQLite database file")
+	dir := flag.String("dir", "", "directory of .up.sql and .down.sql files (default: the migrations built into this binary)")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() < 1 || flag.NArg() > 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	command, arg := flag.Arg(0), flag.Arg(1)
+
+	db, err := database.Connect(*dbPath)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	var source fs.FS = migrations.FS
+	if *dir != "" {
+		source = os.DirFS(*dir)
+	}
+	loaded, err := migrate.Load(source)
+	if err != nil {
+		log.Fatal(err)
+	}
+	m := migrate.NewMigrator(db, loaded)
+	ctx := context.Background()
+
+	switch command {
+	case "status":
+		if err := printStatus(ctx, m); err != nil {
+			log.Fatalf("Status failed: %v", err)
+		}
+	case "up":
+		done, err := m.Up(ctx, count(arg, 0))
+		report("Applied", done)
+		if err != nil {
+			log.Fatalf("Migration up failed: %v", err)
+		}
+		log.Println("✓ Migrations applied successfully")
+	case "down":
+		done, err := m.Down(ctx, count(arg, 1))
+		report("Rolled back", done)
+		if err != nil {
+			log.Fatalf("Migration down failed: %v", err)
+		}
+		log.Println("✓ Migrations rolled back successfully")
+	case "goto":
+		version, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil || version < 0 {
+			log.Fatalf("goto needs a migration version, e.g. goto 20261017140000")
+		}
+		current, err := m.Version(ctx)
+		if err != nil {
+			log.Fatalf("Migration to %d failed: %v", version, err)
+		}
+		done, err := m.Goto(ctx, version)
+		if version < current {
+			report("Rolled back", done)
+		} else {
+			report("Applied", done)
+		}
+		if err != nil {
+			log.Fatalf("Migration to %d failed: %v", version, err)
+		}
+		log.Printf("✓ Database is at version %d", version)
+	default:
+		log.Fatalf("Unknown command: %s. Use status, up, down or goto", command)
+	}
+}
+
+// count parses the optional N argument of up and down
+func count(arg string, fallback int) int {
+	if arg == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 {
+		log.Fatalf("Invalid count %q: must be a positive number", arg)
+	}
+	return n
+}
+
+func report(verb string, done []migrate.Migration) {
+	for _, mig := range done {
+		log.Printf("%s migration: %d_%s", verb, mig.Version, mig.Name)
+	}
+}
+
+func printStatus(ctx context.Context, m *migrate.Migrator) error {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, s := range statuses {
+		state, appliedAt := "pending", ""
+		if s.Applied {
+			state, appliedAt = "applied", s.AppliedAt.Local().Format("2006-01-02 15:04:05")
+		}
+		switch {
+		case s.Drifted:
+			state = "applied, changed since"
+		case s.Missing:
+			state = "applied, file missing"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", s.Version, s.Name, state, appliedAt)
+	}
+	return w.Flush()
+}
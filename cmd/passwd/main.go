@@ -0,0 +1,68 @@
+// Command passwd sets the password of an existing account, so users created
+// before sign-in existed can log in.
+//
+//	go run ./cmd/passwd <username> < password.txt
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/Adjanour/vesper/internal/auth"
+	"github.com/Adjanour/vesper/internal/config"
+	"github.com/Adjanour/vesper/internal/database"
+)
+
+func main() {
+	if len(os.Args) != 2 {
+		log.Fatal("Usage: go run ./cmd/passwd <username>  (the password is read from stdin)")
+	}
+	username := os.Args[1]
+
+	cfg, err := config.Load(nil, os.LookupEnv)
+	if err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+	db, err := database.Connect(cfg.Database.Path)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	var userID string
+	err = db.QueryRow(`SELECT id FROM users WHERE username = ?`, username).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Fatalf("No user named %q", username)
+	}
+	if err != nil {
+		log.Fatalf("Failed to look up user: %v", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "New password for %s: ", username)
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && password == "" {
+		log.Fatalf("Failed to read password: %v", err)
+	}
+	password = strings.TrimRight(password, "\r\n")
+
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		log.Fatalf("Failed to hash password: %v", err)
+	}
+
+	q := database.NewQueries(db)
+	ctx := context.Background()
+	if err := q.SetPassword(ctx, userID, hash); err != nil {
+		log.Fatalf("Failed to set password: %v", err)
+	}
+	if err := q.DeleteUserSessions(ctx, userID); err != nil {
+		log.Fatalf("Failed to end existing sessions: %v", err)
+	}
+	log.Printf("✓ Password updated for %s", username)
+}
@@ -1,36 +1,284 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"flag"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/Adjanour/vesper/internal/api"
+	"github.com/Adjanour/vesper/internal/calsync"
+	"github.com/Adjanour/vesper/internal/config"
 	"github.com/Adjanour/vesper/internal/database"
+	"github.com/Adjanour/vesper/internal/database/migrate"
+	"github.com/Adjanour/vesper/internal/database/migrations"
+	"github.com/Adjanour/vesper/internal/email"
+	"github.com/Adjanour/vesper/internal/events"
+	"github.com/Adjanour/vesper/internal/logging"
+	"github.com/Adjanour/vesper/internal/metrics"
+	"github.com/Adjanour/vesper/internal/planning"
+	"github.com/Adjanour/vesper/internal/tracing"
+	"github.com/Adjanour/vesper/internal/trash"
+	"github.com/Adjanour/vesper/internal/webhooks"
 	"github.com/go-chi/chi/v5"
 )
 
 func main() {
-	db, err := database.Connect()
+	cfg, err := config.Load(os.Args[1:], os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+	if cfg.PrintConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	logger, err := logging.New(os.Stderr, cfg.Log.Format, cfg.Log.Level)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// the standard log package writes through it too
+	slog.SetDefault(logger)
+
+	stopTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		log.Fatalf("Tracing: %v", err)
+	}
+
+	db, err := database.Connect(cfg.Database.Path)
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
+	if err := prepareSchema(db, cfg.Database.Migrate); err != nil {
+		log.Fatalf("Database schema: %v", err)
+	}
 
 	queries := database.NewQueries(db)
-	apiRouter := api.NewAPIRouter(queries)
+	metrics.RegisterDB(db)
+
+	// workers stop in this order: the producers of mail and webhook events
+	// before the dispatcher that delivers them
+	var background workers
+	mailer := newMailer(cfg.Mail)
+	signer := startPlanningScheduler(cfg, queries, mailer, &background)
+	startCalendarSync(cfg.Google, queries, &background)
+	startTrashPurge(cfg.Trash, queries, &background)
+	dispatcher := startWebhooks(cfg.Webhooks, queries, &background)
+
+	broker := events.NewBroker(events.DefaultHistory)
+	apiRouter := api.NewAPIRouter(queries,
+		api.WithCORS(cfg.CORS),
+		api.WithLinkSigner(signer),
+		api.WithBroker(broker),
+		api.WithWebhooks(dispatcher),
+		api.WithAccountNotifier(&email.AccountNotifier{Mailer: mailer, DB: queries}),
+		api.WithLogger(logger),
+		api.WithRequireIfMatch(cfg.Server.RequireIfMatch),
+	)
 
 	// Create main router
 	mainRouter := chi.NewRouter()
-	
+
 	// Mount API routes (apiRouter already has /api prefix in its routes)
 	mainRouter.Mount("/", apiRouter)
-	
+
 	// Serve index.html for root path
 	mainRouter.Get("/", func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFile(w, r, "./web/index.html")
 	})
 
-	log.Println("Server starting on :8080")
-	log.Println("Web UI available at http://localhost:8080")
-	log.Println("API available at http://localhost:8080/api")
-	http.ListenAndServe(":8080", mainRouter)
+	srv := &http.Server{
+		Addr:              cfg.Server.Addr(),
+		Handler:           mainRouter,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		// event streams lift this deadline for themselves
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+	}
+	// Shutdown waits for requests to finish, which open event streams never do
+	srv.RegisterOnShutdown(broker.Close)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 2)
+	go func() {
+		slog.Info("Server starting", "addr", srv.Addr, "url", cfg.Server.PublicURL)
+		serveErr <- srv.ListenAndServe()
+	}()
+	metricsSrv := startMetrics(cfg.Server, serveErr)
+
+	exitCode := 0
+	select {
+	case err := <-serveErr:
+		slog.Error("Server failed", "error", err)
+		exitCode = 1
+	case <-ctx.Done():
+		slog.Info("Shutting down; send the signal again to exit immediately")
+	}
+	// a second signal now kills the process
+	stop()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("HTTP server did not drain", "error", err)
+		exitCode = 1
+	}
+	if metricsSrv != nil {
+		metricsSrv.Shutdown(shutdownCtx)
+	}
+	background.stop(shutdownCtx)
+	if err := stopTracing(shutdownCtx); err != nil {
+		slog.Error("Failed to flush traces", "error", err)
+	}
+	if err := db.Close(); err != nil {
+		slog.Error("Failed to close database", "error", err)
+		exitCode = 1
+	}
+
+	slog.Info("Server stopped")
+	os.Exit(exitCode)
+}
+
+// prepareSchema applies pending migrations when asked to, and refuses to run
+// against a schema written by a newer build or edited since it was applied
+func prepareSchema(db *sql.DB, apply bool) error {
+	loaded, err := migrate.Load(migrations.FS)
+	if err != nil {
+		return err
+	}
+	m := migrate.NewMigrator(db, loaded)
+	ctx := context.Background()
+
+	pending, err := m.Check(ctx)
+	if err != nil || pending == 0 {
+		return err
+	}
+	if !apply {
+		slog.Warn("Database migrations are pending; start with -migrate or run `make migrate`", "pending", pending)
+		return nil
+	}
+
+	done, err := m.Up(ctx, 0)
+	for _, mig := range done {
+		slog.Info("Applied migration", "version", mig.Version, "name", mig.Name)
+	}
+	return err
+}
+
+// startMetrics serves /metrics on its own address, so Prometheus can scrape
+// it without the endpoint being reachable wherever the API is. A failure to
+// listen is sent to serveErr like the API server's.
+func startMetrics(cfg config.Server, serveErr chan<- error) *http.Server {
+	if cfg.MetricsAddr == "off" {
+		return nil
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	srv := &http.Server{
+		Addr:              cfg.MetricsAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+	}
+	go func() {
+		slog.Info("Metrics listening", "addr", srv.Addr)
+		serveErr <- srv.ListenAndServe()
+	}()
+	return srv
+}
+
+// startCalendarSync mirrors one user's tasks to Google Calendar when OAuth credentials are configured
+func startCalendarSync(cfg config.Google, q *database.Queries, ws *workers) {
+	if cfg.RefreshToken == "" {
+		return
+	}
+
+	tokens := &calsync.RefreshTokenSource{
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		RefreshToken: cfg.RefreshToken,
+	}
+	engine := calsync.NewEngine(calsync.NewGoogleClient(tokens.Token), q, cfg.CalendarID)
+	ws.start("Google Calendar sync", func(ctx context.Context) {
+		engine.Run(ctx, cfg.UserID, cfg.SyncInterval)
+	})
+
+	slog.Info("Google Calendar sync enabled", "user_id", cfg.UserID, "interval", cfg.SyncInterval)
+}
+
+// startTrashPurge permanently deletes tasks left in the trash past the retention period
+func startTrashPurge(cfg config.Trash, q *database.Queries, ws *workers) {
+	if cfg.Retention == 0 {
+		return
+	}
+	purger := trash.NewPurger(q, cfg.Retention, cfg.PurgeInterval)
+	ws.start("trash purge", purger.Run)
+}
+
+// startWebhooks delivers queued webhook events and retries failed ones
+func startWebhooks(cfg config.Webhooks, q *database.Queries, ws *workers) *webhooks.Dispatcher {
+	dispatcher := webhooks.NewDispatcher(q, cfg.RetryInterval)
+	dispatcher.AllowPrivateNetworks = cfg.AllowPrivateNetworks
+	ws.start("webhook dispatcher", dispatcher.Run)
+	return dispatcher
+}
+
+// startPlanningScheduler sends each user's nightly planning link and returns the signer used for those links
+func startPlanningScheduler(cfg *config.Config, q *database.Queries, mailer *email.Mailer, ws *workers) *planning.Signer {
+	secret := []byte(cfg.Planning.LinkSecret)
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			log.Fatalf("Failed to generate planning link secret: %v", err)
+		}
+		slog.Warn("PLANNING_LINK_SECRET is not set; planning links will not survive a restart")
+	}
+
+	notifier := &email.PlanningNotifier{
+		Mailer:   mailer,
+		DB:       q,
+		Fallback: planning.LogNotifier{},
+	}
+	signer := planning.NewSigner(secret, cfg.Server.PublicURL, 24*time.Hour)
+	scheduler := planning.NewScheduler(q, notifier, signer, cfg.Planning.CheckInterval)
+	// planning emails are sent from the scheduler's loop, so stopping it also
+	// stops any mail still being retried
+	ws.start("planning scheduler", scheduler.Run)
+
+	return signer
+}
+
+// newMailer sends through SMTP_HOST when it is set and prints emails to stdout otherwise
+func newMailer(cfg config.Mail) *email.Mailer {
+	if cfg.Transport == "log" || (cfg.Transport == "" && cfg.Host == "") {
+		slog.Info("Email delivery: printing messages to stdout")
+		return email.NewMailer(&email.LogTransport{}, cfg.From)
+	}
+
+	transport := &email.SMTPTransport{
+		Addr:     net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+		Username: cfg.Username,
+		Password: cfg.Password,
+		TLS:      email.TLSMode(cfg.TLS),
+	}
+	slog.Info("Email delivery: SMTP relay", "addr", transport.Addr)
+	return email.NewMailer(transport, cfg.From)
 }
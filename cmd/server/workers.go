@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+)
+
+// worker is a background loop that runs until its context is cancelled
+type worker struct {
+	name   string
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// workers are stopped one at a time in the order they were started, so
+// producers can be listed before the consumers that drain their output
+type workers []*worker
+
+// start runs fn in its own goroutine with a context cancelled by stop
+func (ws *workers) start(name string, fn func(ctx context.Context)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &worker{name: name, cancel: cancel, done: make(chan struct{})}
+	*ws = append(*ws, w)
+
+	go func() {
+		defer close(w.done)
+		fn(ctx)
+	}()
+}
+
+// stop cancels each worker in turn and waits for it to return. Workers still
+// running when ctx expires are abandoned so shutdown cannot hang.
+func (ws workers) stop(ctx context.Context) {
+	for _, w := range ws {
+		w.cancel()
+		select {
+		case <-w.done:
+			slog.Info("Stopped worker", "worker", w.name)
+		case <-ctx.Done():
+			slog.Warn("Gave up waiting for worker", "worker", w.name, "error", ctx.Err())
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/mail"
+
+	"github.com/Adjanour/vesper/internal/database"
+)
+
+// emailRequest is the body for setting the caller's notification address
+type emailRequest struct {
+	Email string `json:"email"`
+}
+
+func (ar *APIRouter) getAccount(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := userIDFromRequest(r)
+
+	user, err := ar.db.GetUser(ctx, userID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			writeProblem(w, r, http.StatusNotFound, codeNotFound, "user not found")
+			return
+		}
+		writeError(w, r, err)
+		return
+	}
+
+	email, err := ar.db.GetUserEmail(ctx, userID)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		writeError(w, r, err)
+		return
+	}
+	user.Email = email
+
+	WriteJsonResponse(w, http.StatusOK, user)
+}
+
+func (ar *APIRouter) updateAccountEmail(w http.ResponseWriter, r *http.Request) {
+	var req emailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidJSON, "invalid JSON")
+		return
+	}
+	if req.Email != "" {
+		addr, err := mail.ParseAddress(req.Email)
+		if err != nil || addr.Name != "" {
+			writeInvalidField(w, r, "email", "invalid email address")
+			return
+		}
+	}
+
+	if err := ar.db.SetUserEmail(r.Context(), userIDFromRequest(r), req.Email); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	WriteJsonResponse(w, http.StatusOK, req)
+}
@@ -0,0 +1,363 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Adjanour/vesper/internal/auth"
+	"github.com/Adjanour/vesper/internal/database"
+	"github.com/Adjanour/vesper/internal/models"
+)
+
+const (
+	sessionCookieName = "vesper_session"
+	sessionTTL        = 30 * 24 * time.Hour
+	maxUsernameLength = 64
+)
+
+type contextKey int
+
+const (
+	principalContextKey contextKey = iota
+	requestLogContextKey
+)
+
+// principal is who a request acts as. Token is nil for browser sessions,
+// which may do anything the user can.
+type principal struct {
+	User  *models.User
+	Token *models.APIToken
+}
+
+// actor names the principal in task revisions
+func (p *principal) actor() string {
+	if p.Token != nil {
+		return "token:" + p.Token.ID
+	}
+	return "user:" + p.User.ID
+}
+
+// credentialsRequest is the body for registering and signing in
+type credentialsRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// passwordRequest is the body for changing the caller's password
+type passwordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// requireUser authenticates the request with a personal API token from the
+// Authorization header or, failing that, the session cookie. The verified
+// user is put into the request context for the handlers behind it.
+func (ar *APIRouter) requireUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p *principal
+		var err error
+		if header := r.Header.Get("Authorization"); header != "" {
+			p, err = ar.authenticateToken(r, header)
+		} else {
+			p, err = ar.authenticateSession(r)
+		}
+		if err != nil {
+			if errors.Is(err, database.ErrUnauthorized) {
+				if r.Header.Get("Authorization") != "" {
+					w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				}
+				writeProblem(w, r, http.StatusUnauthorized, codeUnauthenticated, "authentication required")
+				return
+			}
+			writeError(w, r, err)
+			return
+		}
+
+		setLoggedUser(r, p.User.ID)
+		ctx := context.WithValue(r.Context(), principalContextKey, p)
+		ctx = database.WithActor(ctx, p.actor())
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (ar *APIRouter) authenticateSession(r *http.Request) (*principal, error) {
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil || cookie.Value == "" {
+		return nil, database.ErrUnauthorized
+	}
+
+	user, err := ar.db.GetSessionUser(r.Context(), auth.HashToken(cookie.Value), time.Now())
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return nil, database.ErrUnauthorized
+		}
+		return nil, err
+	}
+	return &principal{User: user}, nil
+}
+
+func (ar *APIRouter) authenticateToken(r *http.Request, header string) (*principal, error) {
+	scheme, secret, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || secret == "" {
+		return nil, database.ErrUnauthorized
+	}
+
+	ctx := r.Context()
+	now := time.Now()
+	token, err := ar.db.GetAPITokenByHash(ctx, auth.HashToken(strings.TrimSpace(secret)))
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return nil, database.ErrUnauthorized
+		}
+		return nil, err
+	}
+	if token.Expired(now) {
+		return nil, database.ErrUnauthorized
+	}
+
+	user, err := ar.db.GetUser(ctx, token.UserID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return nil, database.ErrUnauthorized
+		}
+		return nil, err
+	}
+	if err := ar.db.TouchAPIToken(ctx, token.ID, now); err != nil {
+		return nil, err
+	}
+	return &principal{User: user, Token: token}, nil
+}
+
+// requireScope lets browser sessions through and limits API tokens to those granted scope
+func requireScope(scope models.Scope) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if p := principalFromRequest(r); p != nil && p.Token != nil && !p.Token.HasScope(scope) {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope="%s"`, scope))
+				writeProblem(w, r, http.StatusForbidden, codeForbidden, fmt.Sprintf("token lacks the %s scope", scope))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requireSession keeps API tokens away from account management, so a leaked
+// token cannot mint more tokens or change the password.
+func requireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p := principalFromRequest(r); p != nil && p.Token != nil {
+			writeProblem(w, r, http.StatusForbidden, codeForbidden, "this endpoint requires a browser session")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func principalFromRequest(r *http.Request) *principal {
+	p, _ := r.Context().Value(principalContextKey).(*principal)
+	return p
+}
+
+// userFromRequest returns the user authenticated by requireUser
+func userFromRequest(r *http.Request) *models.User {
+	if p := principalFromRequest(r); p != nil {
+		return p.User
+	}
+	return nil
+}
+
+// userIDFromRequest returns the ID of the user authenticated by requireUser
+func userIDFromRequest(r *http.Request) string {
+	if user := userFromRequest(r); user != nil {
+		return user.ID
+	}
+	return ""
+}
+
+func (ar *APIRouter) register(w http.ResponseWriter, r *http.Request) {
+	var req credentialsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidJSON, "invalid JSON")
+		return
+	}
+	req.Username = strings.TrimSpace(req.Username)
+	if req.Username == "" || len(req.Username) > maxUsernameLength {
+		writeInvalidField(w, r, "username", "username must be 1 to 64 characters")
+		return
+	}
+
+	hash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		if errors.Is(err, auth.ErrPasswordTooShort) {
+			writeInvalidField(w, r, "password", err.Error())
+			return
+		}
+		writeError(w, r, err)
+		return
+	}
+
+	user := models.User{ID: newUserID(), Username: req.Username}
+	if err := ar.db.CreateUser(r.Context(), user, hash); err != nil {
+		if errors.Is(err, database.ErrDuplicate) {
+			writeProblem(w, r, http.StatusConflict, codeDuplicate, "username is taken")
+			return
+		}
+		writeError(w, r, err)
+		return
+	}
+
+	if err := ar.startSession(w, r, user.ID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	WriteJsonResponse(w, http.StatusCreated, user)
+}
+
+func (ar *APIRouter) login(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req credentialsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidJSON, "invalid JSON")
+		return
+	}
+
+	user, hash, err := ar.db.GetCredentials(ctx, strings.TrimSpace(req.Username))
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+		auth.CheckDummyPassword(req.Password)
+		writeProblem(w, r, http.StatusUnauthorized, codeInvalidCredentials, "invalid username or password")
+		return
+	case err != nil:
+		writeError(w, r, err)
+		return
+	}
+	if err := auth.CheckPassword(hash, req.Password); err != nil {
+		writeProblem(w, r, http.StatusUnauthorized, codeInvalidCredentials, "invalid username or password")
+		return
+	}
+
+	// sign-ins are rare enough to double as the sweep for stale sessions
+	_, _ = ar.db.DeleteExpiredSessions(ctx, time.Now())
+
+	if err := ar.startSession(w, r, user.ID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	WriteJsonResponse(w, http.StatusOK, user)
+}
+
+func (ar *APIRouter) logout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(sessionCookieName); err == nil && cookie.Value != "" {
+		if err := ar.db.DeleteSession(r.Context(), auth.HashToken(cookie.Value)); err != nil {
+			writeError(w, r, err)
+			return
+		}
+	}
+
+	http.SetCookie(w, sessionCookie(r, "", -1))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AccountNotifier tells users about security-relevant changes to their account
+type AccountNotifier interface {
+	PasswordChanged(ctx context.Context, user models.User, at time.Time) error
+}
+
+func (ar *APIRouter) changePassword(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := userFromRequest(r)
+
+	var req passwordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidJSON, "invalid JSON")
+		return
+	}
+
+	_, current, err := ar.db.GetCredentials(ctx, user.Username)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		writeError(w, r, err)
+		return
+	}
+	if err != nil || auth.CheckPassword(current, req.CurrentPassword) != nil {
+		writeProblem(w, r, http.StatusForbidden, codeInvalidCredentials, "current password is incorrect")
+		return
+	}
+
+	hash, err := auth.HashPassword(req.NewPassword)
+	if err != nil {
+		if errors.Is(err, auth.ErrPasswordTooShort) {
+			writeInvalidField(w, r, "new_password", err.Error())
+			return
+		}
+		writeError(w, r, err)
+		return
+	}
+
+	// a new password signs out every other session
+	err = ar.db.InTx(ctx, func(tx *database.Queries) error {
+		if err := tx.SetPassword(ctx, user.ID, hash); err != nil {
+			return err
+		}
+		return tx.DeleteUserSessions(ctx, user.ID)
+	})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if ar.accounts != nil {
+		// mail is retried with backoff, so it is sent after the response
+		go func(ctx context.Context, user models.User, at time.Time) {
+			if err := ar.accounts.PasswordChanged(ctx, user, at); err != nil {
+				slog.ErrorContext(ctx, "Failed to send password change notice", "user_id", user.ID, "error", err)
+			}
+		}(context.WithoutCancel(ctx), *user, time.Now())
+	}
+	if err := ar.startSession(w, r, user.ID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// startSession creates a session for the user and sets its cookie
+func (ar *APIRouter) startSession(w http.ResponseWriter, r *http.Request, userID string) error {
+	token, hash, err := auth.NewToken()
+	if err != nil {
+		return err
+	}
+	if err := ar.db.CreateSession(r.Context(), hash, userID, time.Now().Add(sessionTTL)); err != nil {
+		return err
+	}
+	http.SetCookie(w, sessionCookie(r, token, int(sessionTTL.Seconds())))
+	return nil
+}
+
+func sessionCookie(r *http.Request, value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		// Lax keeps the cookie off cross-site requests, which is what stops
+		// other origins from riding on a session despite the permissive CORS policy.
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+func newUserID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,213 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Adjanour/vesper/internal/auth"
+	"github.com/Adjanour/vesper/internal/models"
+)
+
+func postJSON(router http.Handler, path string, body any, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	payload, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func sessionFrom(t *testing.T, w *httptest.ResponseRecorder) *http.Cookie {
+	t.Helper()
+	for _, c := range w.Result().Cookies() {
+		if c.Name == sessionCookieName {
+			return c
+		}
+	}
+	t.Fatalf("Expected a %s cookie, got headers %v", sessionCookieName, w.Header())
+	return nil
+}
+
+func TestRequiresAuthentication(t *testing.T) {
+	queries := setupTestDB(t)
+	router := NewAPIRouter(queries)
+
+	for _, tc := range []struct {
+		name   string
+		header func(*http.Request)
+	}{
+		{"no session", func(*http.Request) {}},
+		{"unknown session", func(r *http.Request) {
+			r.AddCookie(&http.Cookie{Name: sessionCookieName, Value: "forged"})
+		}},
+		{"user header alone", func(r *http.Request) { r.Header.Set("X-User-ID", "test-user") }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/tasks/", nil)
+			tc.header(req)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != http.StatusUnauthorized {
+				t.Errorf("Expected status 401, got %d", w.Code)
+			}
+		})
+	}
+}
+
+func TestCreateTaskUsesSessionUser(t *testing.T) {
+	queries := setupTestDB(t)
+	router := NewAPIRouter(queries)
+
+	w := createTestTask(t, router, models.Task{
+		ID:     "test-owner-001",
+		Title:  "Mine",
+		Start:  time.Date(2026, 2, 8, 9, 0, 0, 0, time.UTC),
+		End:    time.Date(2026, 2, 8, 10, 0, 0, 0, time.UTC),
+		UserID: "test-user",
+		Status: models.StatusScheduled,
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	// a body naming someone else still creates the task for the caller
+	body, _ := json.Marshal(map[string]any{
+		"id":      "test-owner-002",
+		"title":   "Not theirs",
+		"start":   "2026-02-08T11:00:00Z",
+		"end":     "2026-02-08T12:00:00Z",
+		"user_id": "other-user",
+		"status":  "scheduled",
+	})
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks/", bytes.NewReader(body))
+	signIn(req, "test-user")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	task, err := queries.GetTask(req.Context(), "test-owner-002")
+	if err != nil {
+		t.Fatalf("GetTask failed: %v", err)
+	}
+	if task.UserID != "test-user" {
+		t.Errorf("Expected task to belong to test-user, got %q", task.UserID)
+	}
+}
+
+func TestRegisterLoginLogout(t *testing.T) {
+	defer func(n int) { auth.Iterations = n }(auth.Iterations)
+	auth.Iterations = 1000
+
+	queries := setupTestDB(t)
+	router := NewAPIRouter(queries)
+
+	creds := credentialsRequest{Username: "ada", Password: "analytical engine"}
+	w := postJSON(router, "/api/auth/register", creds)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201 registering, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	if cookie := sessionFrom(t, w); !cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode {
+		t.Errorf("Expected an HttpOnly, SameSite=Lax session cookie, got %+v", cookie)
+	}
+
+	if w := postJSON(router, "/api/auth/register", creds); w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for a taken username, got %d", w.Code)
+	}
+	if w := postJSON(router, "/api/auth/register", credentialsRequest{Username: "bob", Password: "short"}); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a short password, got %d", w.Code)
+	}
+
+	if w := postJSON(router, "/api/auth/login", credentialsRequest{Username: "ada", Password: "wrong password"}); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 for a wrong password, got %d", w.Code)
+	}
+	if w := postJSON(router, "/api/auth/login", credentialsRequest{Username: "nobody", Password: "analytical engine"}); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 for an unknown user, got %d", w.Code)
+	}
+
+	w = postJSON(router, "/api/auth/login", creds)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 signing in, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	session := sessionFrom(t, w)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/me", nil)
+	req.AddCookie(session)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"username":"ada"`) {
+		t.Fatalf("Expected the signed-in user, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if w := postJSON(router, "/api/auth/logout", nil, session); w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204 signing out, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/me", nil)
+	req.AddCookie(session)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 after signing out, got %d", w.Code)
+	}
+}
+
+// accountNotices records the account notices the API sends
+type accountNotices chan models.User
+
+func (n accountNotices) PasswordChanged(_ context.Context, user models.User, _ time.Time) error {
+	n <- user
+	return nil
+}
+
+func TestChangePasswordNotifiesUser(t *testing.T) {
+	defer func(n int) { auth.Iterations = n }(auth.Iterations)
+	auth.Iterations = 1000
+
+	queries := setupTestDB(t)
+	notices := make(accountNotices, 1)
+	router := NewAPIRouter(queries, WithAccountNotifier(notices))
+
+	w := postJSON(router, "/api/auth/register", credentialsRequest{Username: "ada", Password: "analytical engine"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201 registering, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	session := sessionFrom(t, w)
+
+	change := func(current string) int {
+		payload, _ := json.Marshal(passwordRequest{CurrentPassword: current, NewPassword: "difference engine"})
+		req := httptest.NewRequest(http.MethodPut, "/api/me/password", bytes.NewReader(payload))
+		req.AddCookie(session)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := change("wrong password"); code != http.StatusForbidden {
+		t.Fatalf("Expected status 403 for a wrong current password, got %d", code)
+	}
+	if code := change("analytical engine"); code != http.StatusNoContent {
+		t.Fatalf("Expected status 204 changing the password, got %d", code)
+	}
+	select {
+	case user := <-notices:
+		if user.Username != "ada" {
+			t.Errorf("Expected a notice for ada, got %+v", user)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected a password change notice")
+	}
+	if len(notices) != 0 {
+		t.Error("Expected a single notice")
+	}
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Adjanour/vesper/internal/ical"
+	"github.com/Adjanour/vesper/internal/models"
+)
+
+const calendarProdID = "-//Vesper//Time Blocks//EN"
+
+// eventStatus maps a task status onto the closest VEVENT STATUS
+func eventStatus(s models.TaskStatus) string {
+	switch s {
+	case models.StatusScheduled:
+		return ical.StatusConfirmed
+	default:
+		return ical.StatusCancelled
+	}
+}
+
+// taskEvent converts a task, or an expanded occurrence of one, into a VEVENT.
+// Imported tasks keep the UID of their original event; occurrences are
+// exported as standalone events with their own stable UID.
+func taskEvent(t *models.Task, stamp time.Time) ical.Event {
+	uid := t.ID + "@vesper"
+	if t.ICalUID != "" {
+		uid = t.ICalUID
+	}
+	ev := ical.Event{
+		UID:     uid,
+		Summary: t.Title,
+		Start:   t.Start,
+		End:     t.End,
+		Status:  eventStatus(t.Status),
+		RRule:   t.Recurrence,
+		Stamp:   stamp,
+	}
+	if t.Recurrence != "" {
+		// a series repeats in its own zone, so clients must expand it there
+		ev.TZID = t.Timezone
+	}
+	if t.RecurrenceID != nil {
+		ev.UID = fmt.Sprintf("%s-%s@vesper", t.ID, t.RecurrenceID.UTC().Format("20060102T150405Z"))
+		ev.RRule, ev.TZID = "", ""
+	}
+	return ev
+}
+
+// seriesEvents adds a series' single-occurrence changes to its VEVENT:
+// cancelled occurrences become EXDATEs and edited ones are exported as
+// RECURRENCE-ID events sharing the series UID
+func seriesEvents(series ical.Event, overrides []models.OccurrenceOverride) []ical.Event {
+	events := []ical.Event{series}
+	for _, o := range overrides {
+		if o.Cancelled {
+			events[0].ExDates = append(events[0].ExDates, o.RecurrenceID)
+			continue
+		}
+		ev := series
+		ev.RRule, ev.ExDates = "", nil
+		ev.Start, ev.End = o.Start, o.End
+		if o.Title != "" {
+			ev.Summary = o.Title
+		}
+		ev.RecurrenceID = &o.RecurrenceID
+		events = append(events, ev)
+	}
+	return events
+}
+
+func (ar *APIRouter) exportCalendar(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := userIDFromRequest(r)
+
+	filter, err := parseTaskFilter(r)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+
+	if len(filter.Statuses) == 0 {
+		filter.Statuses = listedStatuses
+	}
+
+	tasks, err := ar.db.ListTasks(ctx, userID, filter)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	stamp := time.Now().UTC()
+	cal := ical.Calendar{ProdID: calendarProdID, Name: "Vesper"}
+	for _, t := range tasks {
+		ev := taskEvent(t, stamp)
+		if ev.RRule == "" {
+			cal.Events = append(cal.Events, ev)
+			continue
+		}
+		overrides, err := ar.db.GetOccurrenceOverrides(ctx, t.ID)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		cal.Events = append(cal.Events, seriesEvents(ev, overrides)...)
+	}
+
+	var buf bytes.Buffer
+	if err := cal.Encode(&buf); err != nil {
+		writeError(w, r, fmt.Errorf("failed to encode calendar: %w", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="vesper.ics"`)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(buf.Bytes())
+}
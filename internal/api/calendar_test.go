@@ -0,0 +1,225 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Adjanour/vesper/internal/models"
+)
+
+func TestExportCalendar(t *testing.T) {
+	queries := setupTestDB(t)
+	router := NewAPIRouter(queries)
+
+	day := time.Date(2026, 2, 8, 0, 0, 0, 0, time.UTC)
+	for _, task := range []models.Task{
+		{ID: "ics-001", Title: "Review, plan", Start: day.Add(9 * time.Hour), End: day.Add(10 * time.Hour), UserID: "test-user", Status: models.StatusScheduled},
+		{ID: "ics-002", Title: "Dropped", Start: day.Add(11 * time.Hour), End: day.Add(12 * time.Hour), UserID: "test-user", Status: models.StatusDeleted},
+		{ID: "ics-003", Title: "Next Week", Start: day.Add(200 * time.Hour), End: day.Add(201 * time.Hour), UserID: "test-user", Status: models.StatusScheduled},
+	} {
+		if w := createTestTask(t, router, task); w.Code != http.StatusCreated {
+			t.Fatalf("Expected status 201, got %d", w.Code)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/tasks/export.ics?from=2026-02-08&to=2026-02-09", nil)
+	signIn(req, "test-user")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/calendar") {
+		t.Errorf("Expected text/calendar content type, got %q", ct)
+	}
+
+	body := w.Body.String()
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"UID:ics-001@vesper\r\nDTSTAMP:",
+		"DTSTART:20260208T090000Z\r\nDTEND:20260208T100000Z\r\n",
+		"SUMMARY:Review\\, plan\r\nSTATUS:CONFIRMED\r\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Export missing %q:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "ics-003") {
+		t.Error("Export included a task outside the window")
+	}
+	// like the task list, the export leaves the trash out unless asked
+	if strings.Contains(body, "ics-002") {
+		t.Error("Export included a deleted task by default")
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/tasks/export.ics?status=deleted", nil)
+	signIn(req, "test-user")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if body := w.Body.String(); !strings.Contains(body, "UID:ics-002@vesper\r\n") || !strings.Contains(body, "STATUS:CANCELLED\r\n") {
+		t.Errorf("Expected the deleted task exported as cancelled on request:\n%s", body)
+	}
+}
+
+func TestExportCalendarSeriesOverrides(t *testing.T) {
+	queries := setupTestDB(t)
+	router := NewAPIRouter(queries)
+
+	start := time.Date(2026, 2, 9, 9, 0, 0, 0, time.UTC)
+	standup := models.Task{ID: "standup", Title: "Standup", Start: start, End: start.Add(15 * time.Minute), UserID: "test-user", Status: models.StatusScheduled, Recurrence: "FREQ=DAILY;COUNT=5"}
+	if w := createTestTask(t, router, standup); w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	ctx := t.Context()
+	if _, err := queries.UpdateOccurrence(ctx, "standup", start.AddDate(0, 0, 1), "Late Standup", start.AddDate(0, 0, 1).Add(6*time.Hour), start.AddDate(0, 0, 1).Add(6*time.Hour+15*time.Minute)); err != nil {
+		t.Fatalf("UpdateOccurrence failed: %v", err)
+	}
+	for _, day := range []int{2, 3} {
+		if err := queries.CancelOccurrence(ctx, "standup", start.AddDate(0, 0, day)); err != nil {
+			t.Fatalf("CancelOccurrence failed: %v", err)
+		}
+	}
+
+	// without a window the series keeps its rule, so its exceptions travel with it
+	req := httptest.NewRequest(http.MethodGet, "/api/tasks/export.ics", nil)
+	signIn(req, "test-user")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	body := w.Body.String()
+	for _, want := range []string{
+		"RRULE:FREQ=DAILY;COUNT=5\r\nEXDATE:20260211T090000Z,20260212T090000Z\r\n",
+		"DTSTART:20260210T150000Z\r\nDTEND:20260210T151500Z\r\nRECURRENCE-ID:20260210T090000Z\r\nSUMMARY:Late Standup\r\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Export missing %q:\n%s", want, body)
+		}
+	}
+	if n := strings.Count(body, "UID:standup@vesper\r\n"); n != 2 {
+		t.Errorf("Expected the series and its edited occurrence to share a UID, got %d events:\n%s", n, body)
+	}
+}
+
+const importFixture = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"PRODID:-//Other//Tool//EN\r\n" +
+	"BEGIN:VEVENT\r\nUID:imp-1\r\nSUMMARY:Focus\r\nDTSTART:20260208T090000Z\r\nDTEND:20260208T100000Z\r\nEND:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\nUID:imp-2\r\nSUMMARY:Clash\r\nDTSTART:20260208T093000Z\r\nDTEND:20260208T103000Z\r\nEND:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\nUID:imp-1\r\nSUMMARY:Focus again\r\nDTSTART:20260209T090000Z\r\nDTEND:20260209T100000Z\r\nEND:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\nUID:imp-3\r\nDTSTART:20260210T090000Z\r\nDTEND:20260210T100000Z\r\nEND:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+type importResponse struct {
+	Committed bool `json:"committed"`
+	Results   []struct {
+		UID    string `json:"uid"`
+		TaskID string `json:"task_id"`
+		Status string `json:"status"`
+	} `json:"results"`
+}
+
+func postImport(t *testing.T, router http.Handler, userID, query string) (int, importResponse) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks/import"+query, strings.NewReader(importFixture))
+	req.Header.Set("Content-Type", "text/calendar")
+	signIn(req, userID)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var response importResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return w.Code, response
+}
+
+func TestImportCalendar(t *testing.T) {
+	queries := setupTestDB(t)
+	router := NewAPIRouter(queries)
+
+	code, response := postImport(t, router, "test-user", "")
+	if code != http.StatusOK || !response.Committed {
+		t.Fatalf("Expected committed 200 import, got %d %+v", code, response)
+	}
+
+	want := []string{"imp-1:created", "imp-2:overlap", "imp-1:duplicate", "imp-3:invalid"}
+	var got []string
+	for _, r := range response.Results {
+		got = append(got, r.UID+":"+r.Status)
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected results %v, got %v", want, got)
+	}
+
+	// importing the same file again only finds duplicates of what was created
+	_, response = postImport(t, router, "test-user", "")
+	if response.Results[0].Status != "duplicate" {
+		t.Errorf("Expected re-import to report duplicate, got %s", response.Results[0].Status)
+	}
+}
+
+func TestImportCalendarPerUser(t *testing.T) {
+	queries := setupTestDB(t)
+	router := NewAPIRouter(queries)
+
+	// the same feed imported by two users gives each their own tasks
+	_, mine := postImport(t, router, "test-user", "")
+	_, theirs := postImport(t, router, "other-user", "")
+	if mine.Results[0].Status != "created" || theirs.Results[0].Status != "created" {
+		t.Fatalf("Expected imp-1 to be created for both users, got %+v and %+v", mine.Results[0], theirs.Results[0])
+	}
+	if id := mine.Results[0].TaskID; id == "imp-1" || id == theirs.Results[0].TaskID {
+		t.Errorf("Expected distinct Vesper IDs, got %q and %q", id, theirs.Results[0].TaskID)
+	}
+	task, err := queries.GetTask(t.Context(), mine.Results[0].TaskID)
+	if err != nil || task.UserID != "test-user" || task.ICalUID != "imp-1" {
+		t.Fatalf("Expected test-user's task to keep the UID, got %+v (%v)", task, err)
+	}
+
+	// an export keeps the UID, so importing it again finds the task
+	req := httptest.NewRequest(http.MethodGet, "/api/tasks/export.ics", nil)
+	signIn(req, "test-user")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if !strings.Contains(w.Body.String(), "UID:imp-1\r\n") {
+		t.Errorf("Expected the export to keep UID imp-1:\n%s", w.Body.String())
+	}
+
+	// another user's Vesper UID does not reach their task: other-user's import
+	// is checked against their own schedule instead
+	feed := strings.ReplaceAll(importFixture, "UID:imp-1\r\n", "UID:"+task.ID+"@vesper\r\n")
+	req = httptest.NewRequest(http.MethodPost, "/api/tasks/import", strings.NewReader(feed))
+	req.Header.Set("Content-Type", "text/calendar")
+	signIn(req, "other-user")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var response importResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if r := response.Results[0]; r.Status != "overlap" {
+		t.Errorf("Expected an overlap with other-user's own imp-1, got %+v", r)
+	}
+}
+
+func TestImportCalendarAtomicRollsBack(t *testing.T) {
+	queries := setupTestDB(t)
+	router := NewAPIRouter(queries)
+
+	code, response := postImport(t, router, "test-user", "?atomic=true")
+	if code != http.StatusConflict || response.Committed {
+		t.Fatalf("Expected uncommitted 409 import, got %d %+v", code, response)
+	}
+
+	if tasks := listTestTasks(t, router, ""); len(tasks) != 0 {
+		t.Errorf("Expected rollback to leave no tasks, got %d", len(tasks))
+	}
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Adjanour/vesper/internal/models"
+)
+
+// taskETag is the strong entity tag of a task's current version
+func taskETag(t *models.Task) string {
+	return `"` + strconv.FormatInt(t.Version, 10) + `"`
+}
+
+// entityTags splits the comma-separated tags of a conditional header, which
+// may also be repeated
+func entityTags(r *http.Request, header string) []string {
+	var tags []string
+	for _, v := range r.Header.Values(header) {
+		for _, tag := range strings.Split(v, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags
+}
+
+// ifMatchVersion evaluates If-Match against the task as read. It returns the
+// version the write must still find, 0 for any, and otherwise answers 412
+// Precondition Failed, or 428 Precondition Required when the header is
+// missing and the router requires it.
+func (ar *APIRouter) ifMatchVersion(w http.ResponseWriter, r *http.Request, task *models.Task) (int64, bool) {
+	tags := entityTags(r, "If-Match")
+	if len(tags) == 0 {
+		if ar.requireIfMatch {
+			writeProblem(w, r, http.StatusPreconditionRequired, codePreconditionRequired, "If-Match is required to change a task")
+			return 0, false
+		}
+		return 0, true
+	}
+
+	// If-Match compares strongly, so weak tags never match
+	current := taskETag(task)
+	for _, tag := range tags {
+		if tag == "*" {
+			return 0, true
+		}
+		if tag == current {
+			return task.Version, true
+		}
+	}
+	writeProblem(w, r, http.StatusPreconditionFailed, codePreconditionFailed, "task has changed since it was read")
+	return 0, false
+}
+
+// noneMatch reports whether If-None-Match names etag, comparing weakly
+func noneMatch(r *http.Request, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, tag := range entityTags(r, "If-None-Match") {
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// writeTaggedJSON answers a GET with data tagged with etag, or with 304 Not
+// Modified when the client already holds it. An empty etag is derived from
+// the body, weakly, since equal bodies are all it promises.
+func writeTaggedJSON(w http.ResponseWriter, r *http.Request, etag string, data any) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+		return
+	}
+	if etag == "" {
+		sum := sha256.Sum256(payload)
+		etag = `W/"` + hex.EncodeToString(sum[:16]) + `"`
+	}
+
+	w.Header().Set("ETag", etag)
+	if noneMatch(r, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(payload)
+}
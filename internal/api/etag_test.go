@@ -0,0 +1,128 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Adjanour/vesper/internal/database"
+	"github.com/Adjanour/vesper/internal/models"
+)
+
+func TestTaskPreconditions(t *testing.T) {
+	queries := setupTestDB(t)
+	router := NewAPIRouter(queries)
+
+	serve := func(method, target string, body any, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		if body != nil {
+			req = httptest.NewRequest(method, target, jsonBody(body))
+		}
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		signIn(req, "test-user")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	start := time.Date(2026, 2, 8, 9, 0, 0, 0, time.UTC)
+	task := models.Task{ID: "focus", Title: "Focus", Start: start, End: start.Add(time.Hour), UserID: "test-user", Status: models.StatusScheduled}
+	w := createTestTask(t, router, task)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	if etag := w.Header().Get("ETag"); etag != `"1"` {
+		t.Errorf(`Expected ETag "1" for a new task, got %q`, etag)
+	}
+
+	w = serve(http.MethodGet, "/api/tasks/focus", nil, nil)
+	if etag := w.Header().Get("ETag"); w.Code != http.StatusOK || etag != `"1"` {
+		t.Fatalf(`Expected 200 with ETag "1", got %d with %q`, w.Code, etag)
+	}
+	w = serve(http.MethodGet, "/api/tasks/focus", nil, http.Header{"If-None-Match": {`"1"`}})
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Expected status 304 for a current If-None-Match, got %d", w.Code)
+	}
+
+	list := serve(http.MethodGet, "/api/tasks", nil, nil)
+	listETag := list.Header().Get("ETag")
+	if list.Code != http.StatusOK || listETag == "" {
+		t.Fatalf("Expected 200 with an ETag listing tasks, got %d with %q", list.Code, listETag)
+	}
+	if w := serve(http.MethodGet, "/api/tasks", nil, http.Header{"If-None-Match": {listETag}}); w.Code != http.StatusNotModified {
+		t.Errorf("Expected status 304 for an unchanged listing, got %d", w.Code)
+	}
+
+	// the first tab saves; the second, still holding version 1, is refused
+	task.Title = "Deep focus"
+	w = serve(http.MethodPut, "/api/tasks/focus", task, http.Header{"If-Match": {`"1"`}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	if etag := w.Header().Get("ETag"); etag != `"2"` {
+		t.Errorf(`Expected ETag "2" after an update, got %q`, etag)
+	}
+	task.Title = "Shallow focus"
+	w = serve(http.MethodPut, "/api/tasks/focus", task, http.Header{"If-Match": {`"1"`}})
+	if w.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected status 412 for a stale If-Match, got %d", w.Code)
+	}
+	if w := serve(http.MethodDelete, "/api/tasks/focus", nil, http.Header{"If-Match": {`W/"2"`}}); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected status 412 for a weak If-Match, got %d", w.Code)
+	}
+	if current, _ := queries.GetTask(t.Context(), "focus"); current.Title != "Deep focus" || current.Version != 2 {
+		t.Errorf("Expected the first update to stand, got %+v", current)
+	}
+
+	if w := serve(http.MethodGet, "/api/tasks", nil, http.Header{"If-None-Match": {listETag}}); w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for a changed listing, got %d", w.Code)
+	}
+
+	// the version is compared again by the write itself
+	stale := task
+	stale.Version = 1
+	if err := queries.UpdateTask(t.Context(), stale); !errors.Is(err, database.ErrVersionMismatch) {
+		t.Errorf("Expected ErrVersionMismatch for a stale version, got %v", err)
+	}
+	if err := queries.DeleteTask(t.Context(), "focus", 1); !errors.Is(err, database.ErrVersionMismatch) {
+		t.Errorf("Expected ErrVersionMismatch deleting a stale version, got %v", err)
+	}
+
+	if w := serve(http.MethodDelete, "/api/tasks/focus", nil, http.Header{"If-Match": {`"7", "2"`}}); w.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204 when one of the tags matches, got %d", w.Code)
+	}
+}
+
+func TestRequireIfMatch(t *testing.T) {
+	queries := setupTestDB(t)
+	router := NewAPIRouter(queries, WithRequireIfMatch(true))
+
+	start := time.Date(2026, 2, 8, 9, 0, 0, 0, time.UTC)
+	task := models.Task{ID: "focus", Title: "Focus", Start: start, End: start.Add(time.Hour), UserID: "test-user", Status: models.StatusScheduled}
+	if w := createTestTask(t, router, task); w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	for _, method := range []string{http.MethodPut, http.MethodDelete} {
+		req := httptest.NewRequest(method, "/api/tasks/focus", jsonBody(task))
+		signIn(req, "test-user")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusPreconditionRequired {
+			t.Errorf("%s: expected status 428 without If-Match, got %d", method, w.Code)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPut, "/api/tasks/focus", jsonBody(task))
+	req.Header.Set("If-Match", "*")
+	signIn(req, "test-user")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 with If-Match: *, got %d. Body: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Adjanour/vesper/internal/events"
+	"github.com/Adjanour/vesper/internal/models"
+)
+
+// eventsHeartbeat keeps idle streams alive through proxies that cut silent connections
+const eventsHeartbeat = 25 * time.Second
+
+// publish announces a change to one of the caller's tasks to open streams
+// and queues it for their webhooks. task may be nil when only the ID is
+// known, e.g. for deletions. The change is already stored, so a failure to
+// queue webhooks is logged rather than failing the request.
+func (ar *APIRouter) publish(r *http.Request, typ events.Type, taskID string, task *models.Task) {
+	e := ar.events.Publish(events.Event{
+		Type:   typ,
+		UserID: userIDFromRequest(r),
+		TaskID: taskID,
+		Task:   task,
+	})
+	if ar.webhooks == nil {
+		return
+	}
+	if err := ar.webhooks.Enqueue(r.Context(), e); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to queue webhooks", "event_id", e.ID, "error", err)
+	}
+}
+
+// streamEvents serves the caller's task changes as Server-Sent Events. A
+// client reconnecting with Last-Event-ID first receives what it missed, or a
+// "reset" event when that is no longer known and it should reload instead.
+func (ar *APIRouter) streamEvents(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		// EventSource cannot set headers on its first connection
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+	missed, complete, ch, cancel := ar.events.Subscribe(userIDFromRequest(r), lastEventID)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// the stream outlives any server-wide write timeout
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	if !complete {
+		fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+	}
+	for _, e := range missed {
+		writeEvent(w, e)
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(eventsHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-ch:
+			if !ok {
+				return // fell behind; the client reconnects with Last-Event-ID
+			}
+			writeEvent(w, e)
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, e events.Event) {
+	data, _ := json.Marshal(e)
+	fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Adjanour/vesper/internal/events"
+)
+
+func newTestTask(id string) map[string]any {
+	return map[string]any{
+		"id":     id,
+		"title":  "Focus block",
+		"start":  "2026-03-02T09:00:00Z",
+		"end":    "2026-03-02T10:00:00Z",
+		"status": "scheduled",
+	}
+}
+
+func jsonBody(v any) io.Reader {
+	payload, _ := json.Marshal(v)
+	return bytes.NewReader(payload)
+}
+
+func TestEventStreamDeliversOwnChanges(t *testing.T) {
+	queries := setupTestDB(t)
+	server := httptest.NewServer(NewAPIRouter(queries))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/events", nil)
+	signIn(req, "test-user")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Expected text/event-stream, got %q", ct)
+	}
+
+	other, _ := http.NewRequest(http.MethodPost, server.URL+"/api/tasks/", jsonBody(newTestTask("other-task")))
+	signIn(other, "other-user")
+	own, _ := http.NewRequest(http.MethodPost, server.URL+"/api/tasks/", jsonBody(newTestTask("own-task")))
+	signIn(own, "test-user")
+	for _, r := range []*http.Request{other, own} {
+		res, err := http.DefaultClient.Do(r)
+		if err != nil {
+			t.Fatalf("Failed to create task: %v", err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("Expected status 201, got %d", res.StatusCode)
+		}
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			break
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "id: ") || lines[1] != "event: task.created" {
+		t.Fatalf("Unexpected event: %q", lines)
+	}
+	if !strings.Contains(lines[2], `"task_id":"own-task"`) {
+		t.Errorf("Expected the caller's own task, got %s", lines[2])
+	}
+}
+
+func TestEventStreamResume(t *testing.T) {
+	queries := setupTestDB(t)
+	broker := events.NewBroker(events.DefaultHistory)
+	router := NewAPIRouter(queries, WithBroker(broker))
+
+	first := broker.Publish(events.Event{Type: events.TaskCreated, UserID: "test-user", TaskID: "a"})
+	broker.Publish(events.Event{Type: events.TaskDeleted, UserID: "test-user", TaskID: "a"})
+
+	stream := func(lastEventID string) string {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		req := httptest.NewRequest(http.MethodGet, "/api/events", nil).WithContext(ctx)
+		req.Header.Set("Last-Event-ID", lastEventID)
+		signIn(req, "test-user")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+
+	body := stream(first.ID)
+	if strings.Contains(body, "task.created") || !strings.Contains(body, "event: task.deleted") {
+		t.Errorf("Expected only the missed deletion, got %q", body)
+	}
+
+	body = stream("stale-1")
+	if !strings.HasPrefix(body, "event: reset\n") {
+		t.Errorf("Expected a reset for an unknown event ID, got %q", body)
+	}
+}
@@ -3,32 +3,53 @@ package api
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/Adjanour/vesper/internal/database"
+	"github.com/Adjanour/vesper/internal/events"
 	"github.com/Adjanour/vesper/internal/models"
 	"github.com/go-chi/chi/v5"
 )
 
-// validateTask validates task fields
+// validateTask validates task fields, returning a validationError that lists
+// every field in error
 func validateTask(t *models.Task) error {
+	var invalid validationError
+	fail := func(field, message string) {
+		invalid = append(invalid, fieldError{Field: field, Message: message})
+	}
+
 	if t.Title == "" {
-		return errors.New("title is required")
+		fail("title", "title is required")
 	}
 	if t.UserID == "" {
-		return errors.New("user_id is required")
+		fail("user_id", "user_id is required")
 	}
 	if t.Start.IsZero() {
-		return errors.New("start time is required")
+		fail("start", "start time is required")
 	}
 	if t.End.IsZero() {
-		return errors.New("end time is required")
-	}
-	if t.End.Before(t.Start) || t.End.Equal(t.Start) {
-		return errors.New("end time must be after start time")
+		fail("end", "end time is required")
+	} else if !t.Start.IsZero() && !t.End.After(t.Start) {
+		fail("end", "end time must be after start time")
 	}
 	if !models.IsValidStatus(t.Status) {
-		return errors.New("invalid status")
+		fail("status", "invalid status")
+	}
+	if t.Recurrence != "" {
+		if _, err := models.ParseRRule(t.Recurrence); err != nil {
+			fail("recurrence", fmt.Sprintf("invalid recurrence: %v", err))
+		}
+	}
+	if _, err := time.LoadLocation(t.Timezone); err != nil {
+		fail("timezone", "invalid timezone")
+	}
+
+	if len(invalid) > 0 {
+		return invalid
 	}
 	return nil
 }
@@ -36,36 +57,106 @@ func validateTask(t *models.Task) error {
 func (ar *APIRouter) GetTasks(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	userID := userIDFromRequest(r)
-	tasks, err := ar.db.GetTasks(ctx, userID)
+
+	filter, err := parseTaskFilter(r)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+	if len(filter.Statuses) == 0 {
+		filter.Statuses = listedStatuses
+	}
+
+	if err := parsePagination(r, &filter); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+	limit := filter.Limit
+	if limit > 0 {
+		filter.Limit++ // look ahead one row to know whether another page exists
+	}
+
+	tasks, err := ar.db.ListTasks(ctx, userID, filter)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
-	WriteJsonResponse(w, http.StatusOK, map[string]any{
+
+	tasks, cursor := nextPage(tasks, limit)
+	response := map[string]any{
 		"tasks": tasks,
-	})
+	}
+	if cursor != "" {
+		response["next_cursor"] = cursor
+		setNextLink(w, r, cursor)
+	}
+	writeTaggedJSON(w, r, "", response)
 }
 
-func (ar *APIRouter) getTask(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	id := chi.URLParam(r, "id")
+// listedStatuses are listed when no status is asked for; the trash is only
+// listed on request
+var listedStatuses = []models.TaskStatus{models.StatusScheduled, models.StatusReplaced}
 
-	task, err := ar.db.GetTask(ctx, id)
-	if err != nil {
-		if errors.Is(err, database.ErrNotFound) {
-			http.Error(w, "task not found", http.StatusNotFound)
-			return
+// parseTaskFilter reads the from, to, status and sort query parameters
+func parseTaskFilter(r *http.Request) (database.TaskFilter, error) {
+	var f database.TaskFilter
+	query := r.URL.Query()
+
+	var err error
+	if v := query.Get("from"); v != "" {
+		if f.From, err = parseTimeParam(v); err != nil {
+			return f, fmt.Errorf("invalid from: %w", err)
+		}
+	}
+	if v := query.Get("to"); v != "" {
+		if f.To, err = parseTimeParam(v); err != nil {
+			return f, fmt.Errorf("invalid to: %w", err)
+		}
+	}
+	if !f.From.IsZero() && !f.To.IsZero() && !f.To.After(f.From) {
+		return f, errors.New("to must be after from")
+	}
+
+	// status may be repeated (?status=a&status=b) or comma-separated (?status=a,b)
+	for _, v := range query["status"] {
+		for _, s := range strings.Split(v, ",") {
+			status := models.TaskStatus(strings.TrimSpace(s))
+			if !models.IsValidStatus(status) {
+				return f, fmt.Errorf("invalid status %q", status)
+			}
+			f.Statuses = append(f.Statuses, status)
+		}
+	}
+
+	if v := query.Get("sort"); v != "" {
+		f.Sort = database.TaskSort(v)
+		if !database.IsValidSort(f.Sort) {
+			return f, fmt.Errorf("invalid sort %q", v)
 		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
 	}
 
-	if userID, ok := userIDFromHeader(r); ok && task.UserID != userID {
-		http.Error(w, "task not found", http.StatusNotFound)
+	return f, nil
+}
+
+// parseTimeParam accepts an RFC3339 timestamp or a bare YYYY-MM-DD date (UTC midnight)
+func parseTimeParam(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t.UTC(), nil
+	}
+	t, err := time.Parse(time.DateOnly, v)
+	if err != nil {
+		return time.Time{}, errors.New("expected RFC3339 timestamp or YYYY-MM-DD date")
+	}
+	return t, nil
+}
+
+func (ar *APIRouter) getTask(w http.ResponseWriter, r *http.Request) {
+	task, ok := ar.ownedTask(w, r)
+	if !ok {
 		return
 	}
 
-	WriteJsonResponse(w, http.StatusOK, task)
+	writeTaggedJSON(w, r, taskETag(task), task)
 }
 
 func (ar *APIRouter) createTask(w http.ResponseWriter, r *http.Request) {
@@ -73,17 +164,19 @@ func (ar *APIRouter) createTask(w http.ResponseWriter, r *http.Request) {
 
 	var t models.Task
 	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
-		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidJSON, "invalid JSON")
 		return
 	}
 
-	if userID, ok := userIDFromHeader(r); ok {
-		t.UserID = userID
+	// tasks always belong to the caller, whatever user_id the body names
+	t.UserID = userIDFromRequest(r)
+	if t.ID == "" {
+		t.ID = newUserID()
 	}
 
 	// Validate task
 	if err := validateTask(&t); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, r, err)
 		return
 	}
 
@@ -93,111 +186,124 @@ func (ar *APIRouter) createTask(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := ar.db.CreateTask(ctx, t); err != nil {
-		switch err {
-		case database.ErrTaskOverlap:
-			http.Error(w, "task overlaps with existing task", http.StatusConflict)
-		case database.ErrInvalid:
-			http.Error(w, "invalid user id", http.StatusBadRequest)
-		default:
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		}
+		writeError(w, r, err)
 		return
 	}
 
-	WriteJsonResponse(w, http.StatusCreated, t)
+	created, err := ar.db.GetTask(ctx, t.ID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	ar.publish(r, events.TaskCreated, created.ID, created)
+	w.Header().Set("ETag", taskETag(created))
+	WriteJsonResponse(w, http.StatusCreated, created)
 }
 
 func (ar *APIRouter) updateTask(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
 	id := chi.URLParam(r, "id")
 
 	var t models.Task
 	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
-		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidJSON, "invalid JSON")
 		return
 	}
 
 	// Set ID from URL parameter
 	t.ID = id
 
-	if userID, ok := userIDFromHeader(r); ok {
-		existing, err := ar.db.GetTask(ctx, id)
-		if err != nil {
-			if errors.Is(err, database.ErrNotFound) {
-				http.Error(w, "task not found", http.StatusNotFound)
-				return
-			}
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		if existing.UserID != userID {
-			http.Error(w, "task not found", http.StatusNotFound)
-			return
-		}
-		t.UserID = userID
+	existing, ok := ar.ownedTask(w, r)
+	if !ok {
+		return
 	}
+	t.UserID = userIDFromRequest(r)
+
+	// only If-Match makes the update conditional, whatever version the body names
+	if t.Version, ok = ar.ifMatchVersion(w, r, existing); !ok {
+		return
+	}
+
+	ar.saveTask(w, r, t)
+}
+
+// saveTask validates and stores an edited task, answering with the task as
+// stored and its new ETag
+func (ar *APIRouter) saveTask(w http.ResponseWriter, r *http.Request, t models.Task) {
+	ctx := r.Context()
 
 	// Validate task
 	if err := validateTask(&t); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, r, err)
 		return
 	}
 
 	if err := ar.db.UpdateTask(ctx, t); err != nil {
-		switch err {
-		case database.ErrNotFound:
-			http.Error(w, "task not found", http.StatusNotFound)
-		case database.ErrTaskOverlap:
-			http.Error(w, "task overlaps with existing task", http.StatusConflict)
-		default:
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		}
+		writeError(w, r, err)
+		return
+	}
+
+	updated, err := ar.db.GetTask(ctx, t.ID)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	WriteJsonResponse(w, http.StatusOK, t)
+	ar.publish(r, events.TaskUpdated, updated.ID, updated)
+	w.Header().Set("ETag", taskETag(updated))
+	WriteJsonResponse(w, http.StatusOK, updated)
 }
 
 func (ar *APIRouter) deleteTask(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	id := chi.URLParam(r, "id")
 
-	if userID, ok := userIDFromHeader(r); ok {
-		task, err := ar.db.GetTask(ctx, id)
-		if err != nil {
-			if errors.Is(err, database.ErrNotFound) {
-				http.Error(w, "task not found", http.StatusNotFound)
-				return
-			}
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		if task.UserID != userID {
-			http.Error(w, "task not found", http.StatusNotFound)
-			return
-		}
+	task, ok := ar.ownedTask(w, r)
+	if !ok {
+		return
+	}
+	version, ok := ar.ifMatchVersion(w, r, task)
+	if !ok {
+		return
 	}
 
-	if err := ar.db.DeleteTask(ctx, id); err != nil {
+	if err := ar.db.DeleteTask(ctx, id, version); err != nil {
 		if errors.Is(err, database.ErrNotFound) {
-			http.Error(w, "task not found", http.StatusNotFound)
+			writeProblem(w, r, http.StatusNotFound, codeNotFound, "task not found")
 			return
 		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
+	ar.publish(r, events.TaskDeleted, id, nil)
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func userIDFromRequest(r *http.Request) string {
-	if userID, ok := userIDFromHeader(r); ok {
-		return userID
-	}
-	return "1"
+// ownedTask loads the task named in the URL, answering 404 when it does not
+// exist, is in the trash or belongs to someone other than the caller.
+func (ar *APIRouter) ownedTask(w http.ResponseWriter, r *http.Request) (*models.Task, bool) {
+	return ar.findOwnedTask(w, r, false)
+}
+
+// trashedTask is ownedTask for tasks in the trash, and only those
+func (ar *APIRouter) trashedTask(w http.ResponseWriter, r *http.Request) (*models.Task, bool) {
+	return ar.findOwnedTask(w, r, true)
 }
 
-func userIDFromHeader(r *http.Request) (string, bool) {
-	userID := r.Header.Get("X-User-ID")
-	return userID, userID != ""
+func (ar *APIRouter) findOwnedTask(w http.ResponseWriter, r *http.Request, inTrash bool) (*models.Task, bool) {
+	task, err := ar.db.GetTask(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			writeProblem(w, r, http.StatusNotFound, codeNotFound, "task not found")
+			return nil, false
+		}
+		writeError(w, r, err)
+		return nil, false
+	}
+	if task.UserID != userIDFromRequest(r) || (task.Status == models.StatusDeleted) != inTrash {
+		writeProblem(w, r, http.StatusNotFound, codeNotFound, "task not found")
+		return nil, false
+	}
+	return task, true
 }
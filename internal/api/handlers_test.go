@@ -5,45 +5,38 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/Adjanour/vesper/internal/auth"
 	"github.com/Adjanour/vesper/internal/database"
+	"github.com/Adjanour/vesper/internal/database/migrate"
+	"github.com/Adjanour/vesper/internal/database/migrations"
 	"github.com/Adjanour/vesper/internal/models"
 	_ "modernc.org/sqlite"
 )
 
 func setupTestDB(t *testing.T) *database.Queries {
-	// Use in-memory SQLite for testing
-	db, err := sql.Open("sqlite", ":memory:")
+	// Use in-memory SQLite for testing; a single connection keeps every
+	// query on the same database
+	db, err := sql.Open("sqlite", ":memory:?_pragma=foreign_keys(1)")
 	if err != nil {
 		t.Fatalf("Failed to open test database: %v", err)
 	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
 
-	// Create tables
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS users (
-			id TEXT PRIMARY KEY,
-			username TEXT NOT NULL UNIQUE
-		);
-
-		CREATE TABLE IF NOT EXISTS tasks (
-			id TEXT PRIMARY KEY,
-			title TEXT NOT NULL,
-			start DATETIME NOT NULL,
-			end DATETIME NOT NULL,
-			status TEXT NOT NULL CHECK (status IN ('scheduled', 'deleted', 'replaced')),
-			user_id TEXT NOT NULL,
-			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
-		);
-
-		CREATE INDEX IF NOT EXISTS idx_tasks_user_id ON tasks(user_id);
-		CREATE INDEX IF NOT EXISTS idx_tasks_start_end ON tasks(start, end);
-	`)
+	// tests run against the schema the migrations build
+	all, err := migrate.Load(migrations.FS)
 	if err != nil {
-		t.Fatalf("Failed to create tables: %v", err)
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+	if _, err := migrate.NewMigrator(db, all).Up(context.Background(), 0); err != nil {
+		t.Fatalf("Failed to apply migrations: %v", err)
 	}
 
 	// Insert test users
@@ -57,7 +50,25 @@ func setupTestDB(t *testing.T) *database.Queries {
 		t.Fatalf("Failed to insert test user: %v", err)
 	}
 
-	return database.NewQueries(db)
+	// Give every test user a session that signIn can present
+	queries := database.NewQueries(db)
+	for _, userID := range []string{"1", "test-user", "other-user"} {
+		err := queries.CreateSession(context.Background(), auth.HashToken(testSessionToken(userID)), userID, time.Now().Add(time.Hour))
+		if err != nil {
+			t.Fatalf("Failed to create test session: %v", err)
+		}
+	}
+
+	return queries
+}
+
+func testSessionToken(userID string) string {
+	return "test-session-" + userID
+}
+
+// signIn authenticates req as one of the users created by setupTestDB
+func signIn(req *http.Request, userID string) {
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: testSessionToken(userID)})
 }
 
 func TestHealthEndpoint(t *testing.T) {
@@ -65,6 +76,7 @@ func TestHealthEndpoint(t *testing.T) {
 	router := NewAPIRouter(queries)
 
 	req := httptest.NewRequest(http.MethodGet, "/api/health", nil)
+	signIn(req, "test-user")
 	w := httptest.NewRecorder()
 
 	router.ServeHTTP(w, req)
@@ -98,6 +110,7 @@ func TestCreateTask(t *testing.T) {
 
 	body, _ := json.Marshal(task)
 	req := httptest.NewRequest(http.MethodPost, "/api/tasks/", bytes.NewReader(body))
+	signIn(req, "test-user")
 	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 
@@ -125,6 +138,7 @@ func TestCreateTaskValidation(t *testing.T) {
 		name       string
 		task       map[string]interface{}
 		wantStatus int
+		wantField  string
 		wantError  string
 	}{
 		{
@@ -137,20 +151,9 @@ func TestCreateTaskValidation(t *testing.T) {
 				"status":  "scheduled",
 			},
 			wantStatus: http.StatusBadRequest,
+			wantField:  "title",
 			wantError:  "title is required",
 		},
-		{
-			name: "missing user_id",
-			task: map[string]interface{}{
-				"id":     "test-002",
-				"title":  "Test",
-				"start":  time.Now().Format(time.RFC3339),
-				"end":    time.Now().Add(1 * time.Hour).Format(time.RFC3339),
-				"status": "scheduled",
-			},
-			wantStatus: http.StatusBadRequest,
-			wantError:  "user_id is required",
-		},
 		{
 			name: "invalid status",
 			task: map[string]interface{}{
@@ -162,6 +165,7 @@ func TestCreateTaskValidation(t *testing.T) {
 				"status":  "invalid",
 			},
 			wantStatus: http.StatusBadRequest,
+			wantField:  "status",
 			wantError:  "invalid status",
 		},
 		{
@@ -175,6 +179,7 @@ func TestCreateTaskValidation(t *testing.T) {
 				"status":  "scheduled",
 			},
 			wantStatus: http.StatusBadRequest,
+			wantField:  "end",
 			wantError:  "end time must be after start time",
 		},
 	}
@@ -183,6 +188,7 @@ func TestCreateTaskValidation(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			body, _ := json.Marshal(tt.task)
 			req := httptest.NewRequest(http.MethodPost, "/api/tasks/", bytes.NewReader(body))
+			signIn(req, "test-user")
 			req.Header.Set("Content-Type", "application/json")
 			w := httptest.NewRecorder()
 
@@ -192,8 +198,18 @@ func TestCreateTaskValidation(t *testing.T) {
 				t.Errorf("Expected status %d, got %d", tt.wantStatus, w.Code)
 			}
 
-			if w.Body.String() != tt.wantError+"\n" {
-				t.Errorf("Expected error '%s', got '%s'", tt.wantError, w.Body.String())
+			if ct := w.Header().Get("Content-Type"); ct != problemContentType {
+				t.Errorf("Expected a problem+json response, got %q", ct)
+			}
+			var p problem
+			if err := json.NewDecoder(w.Body).Decode(&p); err != nil {
+				t.Fatalf("Failed to decode problem: %v", err)
+			}
+			if p.Code != codeValidation || p.Status != tt.wantStatus || p.RequestID == "" {
+				t.Errorf("Unexpected problem: %+v", p)
+			}
+			if len(p.Errors) != 1 || p.Errors[0].Field != tt.wantField || p.Errors[0].Message != tt.wantError {
+				t.Errorf("Expected %s: %s, got %+v", tt.wantField, tt.wantError, p.Errors)
 			}
 		})
 	}
@@ -215,12 +231,14 @@ func TestGetTask(t *testing.T) {
 
 	body, _ := json.Marshal(task)
 	createReq := httptest.NewRequest(http.MethodPost, "/api/tasks/", bytes.NewReader(body))
+	signIn(createReq, "test-user")
 	createReq.Header.Set("Content-Type", "application/json")
 	createW := httptest.NewRecorder()
 	router.ServeHTTP(createW, createReq)
 
 	// Now get the task
 	req := httptest.NewRequest(http.MethodGet, "/api/tasks/test-get-001", nil)
+	signIn(req, "test-user")
 	w := httptest.NewRecorder()
 
 	router.ServeHTTP(w, req)
@@ -244,6 +262,7 @@ func TestGetTaskNotFound(t *testing.T) {
 	router := NewAPIRouter(queries)
 
 	req := httptest.NewRequest(http.MethodGet, "/api/tasks/nonexistent", nil)
+	signIn(req, "test-user")
 	w := httptest.NewRecorder()
 
 	router.ServeHTTP(w, req)
@@ -280,6 +299,7 @@ func TestListTasks(t *testing.T) {
 	for _, task := range tasks {
 		body, _ := json.Marshal(task)
 		createReq := httptest.NewRequest(http.MethodPost, "/api/tasks/", bytes.NewReader(body))
+		signIn(createReq, "test-user")
 		createReq.Header.Set("Content-Type", "application/json")
 		createW := httptest.NewRecorder()
 		router.ServeHTTP(createW, createReq)
@@ -287,6 +307,7 @@ func TestListTasks(t *testing.T) {
 
 	// List tasks
 	req := httptest.NewRequest(http.MethodGet, "/api/tasks/", nil)
+	signIn(req, "test-user")
 	w := httptest.NewRecorder()
 
 	router.ServeHTTP(w, req)
@@ -321,6 +342,7 @@ func TestUpdateTask(t *testing.T) {
 
 	body, _ := json.Marshal(task)
 	createReq := httptest.NewRequest(http.MethodPost, "/api/tasks/", bytes.NewReader(body))
+	signIn(createReq, "test-user")
 	createReq.Header.Set("Content-Type", "application/json")
 	createW := httptest.NewRecorder()
 	router.ServeHTTP(createW, createReq)
@@ -331,6 +353,7 @@ func TestUpdateTask(t *testing.T) {
 
 	updateBody, _ := json.Marshal(updatedTask)
 	updateReq := httptest.NewRequest(http.MethodPut, "/api/tasks/test-update-001", bytes.NewReader(updateBody))
+	signIn(updateReq, "test-user")
 	updateReq.Header.Set("Content-Type", "application/json")
 	updateW := httptest.NewRecorder()
 
@@ -366,12 +389,14 @@ func TestDeleteTask(t *testing.T) {
 
 	body, _ := json.Marshal(task)
 	createReq := httptest.NewRequest(http.MethodPost, "/api/tasks/", bytes.NewReader(body))
+	signIn(createReq, "test-user")
 	createReq.Header.Set("Content-Type", "application/json")
 	createW := httptest.NewRecorder()
 	router.ServeHTTP(createW, createReq)
 
 	// Delete the task
 	deleteReq := httptest.NewRequest(http.MethodDelete, "/api/tasks/test-delete-001", nil)
+	signIn(deleteReq, "test-user")
 	deleteW := httptest.NewRecorder()
 
 	router.ServeHTTP(deleteW, deleteReq)
@@ -382,6 +407,7 @@ func TestDeleteTask(t *testing.T) {
 
 	// Verify it's deleted
 	getReq := httptest.NewRequest(http.MethodGet, "/api/tasks/test-delete-001", nil)
+	signIn(getReq, "test-user")
 	getW := httptest.NewRecorder()
 	router.ServeHTTP(getW, getReq)
 
@@ -390,7 +416,7 @@ func TestDeleteTask(t *testing.T) {
 	}
 }
 
-func TestGetTaskRespectsUserHeader(t *testing.T) {
+func TestGetTaskRespectsSessionUser(t *testing.T) {
 	queries := setupTestDB(t)
 	router := NewAPIRouter(queries)
 
@@ -405,12 +431,13 @@ func TestGetTaskRespectsUserHeader(t *testing.T) {
 
 	body, _ := json.Marshal(task)
 	createReq := httptest.NewRequest(http.MethodPost, "/api/tasks/", bytes.NewReader(body))
+	signIn(createReq, "test-user")
 	createReq.Header.Set("Content-Type", "application/json")
 	createW := httptest.NewRecorder()
 	router.ServeHTTP(createW, createReq)
 
 	req := httptest.NewRequest(http.MethodGet, "/api/tasks/test-get-user-001", nil)
-	req.Header.Set("X-User-ID", "other-user")
+	signIn(req, "other-user")
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
@@ -419,7 +446,7 @@ func TestGetTaskRespectsUserHeader(t *testing.T) {
 	}
 
 	req = httptest.NewRequest(http.MethodGet, "/api/tasks/test-get-user-001", nil)
-	req.Header.Set("X-User-ID", "test-user")
+	signIn(req, "test-user")
 	w = httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
@@ -428,7 +455,7 @@ func TestGetTaskRespectsUserHeader(t *testing.T) {
 	}
 }
 
-func TestDeleteTaskRespectsUserHeader(t *testing.T) {
+func TestDeleteTaskRespectsSessionUser(t *testing.T) {
 	queries := setupTestDB(t)
 	router := NewAPIRouter(queries)
 
@@ -443,12 +470,13 @@ func TestDeleteTaskRespectsUserHeader(t *testing.T) {
 
 	body, _ := json.Marshal(task)
 	createReq := httptest.NewRequest(http.MethodPost, "/api/tasks/", bytes.NewReader(body))
+	signIn(createReq, "test-user")
 	createReq.Header.Set("Content-Type", "application/json")
 	createW := httptest.NewRecorder()
 	router.ServeHTTP(createW, createReq)
 
 	deleteReq := httptest.NewRequest(http.MethodDelete, "/api/tasks/test-delete-user-001", nil)
-	deleteReq.Header.Set("X-User-ID", "other-user")
+	signIn(deleteReq, "other-user")
 	deleteW := httptest.NewRecorder()
 	router.ServeHTTP(deleteW, deleteReq)
 
@@ -457,7 +485,7 @@ func TestDeleteTaskRespectsUserHeader(t *testing.T) {
 	}
 
 	deleteReq = httptest.NewRequest(http.MethodDelete, "/api/tasks/test-delete-user-001", nil)
-	deleteReq.Header.Set("X-User-ID", "test-user")
+	signIn(deleteReq, "test-user")
 	deleteW = httptest.NewRecorder()
 	router.ServeHTTP(deleteW, deleteReq)
 
@@ -482,6 +510,7 @@ func TestTaskOverlapDetection(t *testing.T) {
 
 	body1, _ := json.Marshal(task1)
 	createReq1 := httptest.NewRequest(http.MethodPost, "/api/tasks/", bytes.NewReader(body1))
+	signIn(createReq1, "test-user")
 	createReq1.Header.Set("Content-Type", "application/json")
 	createW1 := httptest.NewRecorder()
 	router.ServeHTTP(createW1, createReq1)
@@ -498,6 +527,7 @@ func TestTaskOverlapDetection(t *testing.T) {
 
 	body2, _ := json.Marshal(task2)
 	createReq2 := httptest.NewRequest(http.MethodPost, "/api/tasks/", bytes.NewReader(body2))
+	signIn(createReq2, "test-user")
 	createReq2.Header.Set("Content-Type", "application/json")
 	createW2 := httptest.NewRecorder()
 	router.ServeHTTP(createW2, createReq2)
@@ -523,6 +553,7 @@ func TestTaskOverlapIgnoresInactiveStatuses(t *testing.T) {
 
 	body1, _ := json.Marshal(task1)
 	createReq1 := httptest.NewRequest(http.MethodPost, "/api/tasks/", bytes.NewReader(body1))
+	signIn(createReq1, "test-user")
 	createReq1.Header.Set("Content-Type", "application/json")
 	createW1 := httptest.NewRecorder()
 	router.ServeHTTP(createW1, createReq1)
@@ -543,6 +574,7 @@ func TestTaskOverlapIgnoresInactiveStatuses(t *testing.T) {
 
 	body2, _ := json.Marshal(task2)
 	createReq2 := httptest.NewRequest(http.MethodPost, "/api/tasks/", bytes.NewReader(body2))
+	signIn(createReq2, "test-user")
 	createReq2.Header.Set("Content-Type", "application/json")
 	createW2 := httptest.NewRecorder()
 	router.ServeHTTP(createW2, createReq2)
@@ -568,6 +600,7 @@ func TestTaskOverlapIsScopedToUser(t *testing.T) {
 
 	body1, _ := json.Marshal(task1)
 	createReq1 := httptest.NewRequest(http.MethodPost, "/api/tasks/", bytes.NewReader(body1))
+	signIn(createReq1, "test-user")
 	createReq1.Header.Set("Content-Type", "application/json")
 	createW1 := httptest.NewRecorder()
 	router.ServeHTTP(createW1, createReq1)
@@ -588,6 +621,7 @@ func TestTaskOverlapIsScopedToUser(t *testing.T) {
 
 	body2, _ := json.Marshal(task2)
 	createReq2 := httptest.NewRequest(http.MethodPost, "/api/tasks/", bytes.NewReader(body2))
+	signIn(createReq2, "other-user")
 	createReq2.Header.Set("Content-Type", "application/json")
 	createW2 := httptest.NewRecorder()
 	router.ServeHTTP(createW2, createReq2)
@@ -596,3 +630,296 @@ func TestTaskOverlapIsScopedToUser(t *testing.T) {
 		t.Errorf("Expected status 201 for overlapping task with different user, got %d", createW2.Code)
 	}
 }
+
+func TestListTasksFilters(t *testing.T) {
+	queries := setupTestDB(t)
+	router := NewAPIRouter(queries)
+
+	day := time.Date(2026, 2, 8, 0, 0, 0, 0, time.UTC)
+	tasks := []models.Task{
+		{ID: "filter-001", Title: "B Morning", Start: day.Add(9 * time.Hour), End: day.Add(10 * time.Hour), UserID: "test-user", Status: models.StatusScheduled},
+		{ID: "filter-002", Title: "A Afternoon", Start: day.Add(14 * time.Hour), End: day.Add(15 * time.Hour), UserID: "test-user", Status: models.StatusScheduled},
+		{ID: "filter-003", Title: "Old", Start: day.Add(11 * time.Hour), End: day.Add(12 * time.Hour), UserID: "test-user", Status: models.StatusDeleted},
+		{ID: "filter-004", Title: "Next Day", Start: day.Add(33 * time.Hour), End: day.Add(34 * time.Hour), UserID: "test-user", Status: models.StatusScheduled},
+	}
+	for _, task := range tasks {
+		body, _ := json.Marshal(task)
+		req := httptest.NewRequest(http.MethodPost, "/api/tasks/", bytes.NewReader(body))
+		signIn(req, "test-user")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected status 201 creating %s, got %d", task.ID, w.Code)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		query   string
+		wantIDs []string
+	}{
+		{"no filter leaves out the trash", "", []string{"filter-001", "filter-002", "filter-004"}},
+		{"day window", "?from=2026-02-08&to=2026-02-09", []string{"filter-001", "filter-002"}},
+		{"window clips partial overlap", "?from=2026-02-08T09:30:00Z&to=2026-02-08T11:00:00Z", []string{"filter-001"}},
+		{"status filter", "?from=2026-02-08&to=2026-02-09&status=scheduled", []string{"filter-001", "filter-002"}},
+		{"multi status", "?status=deleted,replaced", []string{"filter-003"}},
+		{"repeated status", "?status=deleted&status=scheduled&to=2026-02-09", []string{"filter-001", "filter-003", "filter-002"}},
+		{"sort by title", "?status=scheduled&sort=title", []string{"filter-002", "filter-001", "filter-004"}},
+		{"sort descending", "?sort=-start", []string{"filter-004", "filter-002", "filter-001"}},
+		{"trash on request", "?status=deleted&sort=-start", []string{"filter-003"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/tasks/"+tt.query, nil)
+			signIn(req, "test-user")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+			}
+
+			var response map[string][]*models.Task
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+
+			var gotIDs []string
+			for _, task := range response["tasks"] {
+				gotIDs = append(gotIDs, task.ID)
+			}
+			if strings.Join(gotIDs, ",") != strings.Join(tt.wantIDs, ",") {
+				t.Errorf("Expected tasks %v, got %v", tt.wantIDs, gotIDs)
+			}
+		})
+	}
+}
+
+func TestListTasksWindowInLocalZone(t *testing.T) {
+	queries := setupTestDB(t)
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("Europe/Berlin unavailable: %v", err)
+	}
+
+	at := func(day, hour, minute int) time.Time { return time.Date(2026, 2, day, hour, minute, 0, 0, time.UTC) }
+	for _, task := range []models.Task{
+		// 00:30 on Feb 9 in Berlin
+		{ID: "early", Title: "Early", Start: at(8, 23, 30), End: at(8, 23, 45), UserID: "test-user", Status: models.StatusScheduled},
+		// 00:30 on Feb 10 in Berlin
+		{ID: "late", Title: "Late", Start: at(9, 23, 30), End: at(9, 23, 45), UserID: "test-user", Status: models.StatusScheduled},
+		{ID: "nightly", Title: "Nightly", Start: at(7, 23, 0), End: at(7, 23, 15), UserID: "test-user", Status: models.StatusScheduled, Recurrence: "FREQ=DAILY;COUNT=5"},
+	} {
+		if err := queries.CreateTask(t.Context(), task); err != nil {
+			t.Fatalf("CreateTask(%s) failed: %v", task.ID, err)
+		}
+	}
+
+	// Feb 9 in Berlin is 2026-02-08T23:00Z to 2026-02-09T23:00Z
+	from := time.Date(2026, 2, 9, 0, 0, 0, 0, berlin)
+	tasks, err := queries.ListTasks(t.Context(), "test-user", database.TaskFilter{From: from, To: from.AddDate(0, 0, 1)})
+	if err != nil {
+		t.Fatalf("ListTasks failed: %v", err)
+	}
+
+	var got []string
+	for _, task := range tasks {
+		got = append(got, task.ID+"@"+task.Start.Format("02T15:04"))
+	}
+	want := []string{"nightly@08T23:00", "early@08T23:30"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected %v in the Berlin day, got %v", want, got)
+	}
+}
+
+func TestListTasksInvalidFilters(t *testing.T) {
+	queries := setupTestDB(t)
+	router := NewAPIRouter(queries)
+
+	for _, query := range []string{
+		"?from=yesterday",
+		"?from=2026-02-09&to=2026-02-08",
+		"?status=done",
+		"?sort=priority",
+	} {
+		t.Run(query, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/tasks/"+query, nil)
+			signIn(req, "test-user")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("Expected status 400, got %d", w.Code)
+			}
+		})
+	}
+}
+
+func TestListTasksPagination(t *testing.T) {
+	queries := setupTestDB(t)
+	router := NewAPIRouter(queries)
+
+	start := time.Date(2026, 2, 8, 8, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		task := models.Task{
+			ID:     fmt.Sprintf("page-%03d", i),
+			Title:  "Paged Task",
+			Start:  start.Add(time.Duration(i) * time.Hour),
+			End:    start.Add(time.Duration(i)*time.Hour + 30*time.Minute),
+			UserID: "test-user",
+			Status: models.StatusScheduled,
+		}
+		body, _ := json.Marshal(task)
+		req := httptest.NewRequest(http.MethodPost, "/api/tasks/", bytes.NewReader(body))
+		signIn(req, "test-user")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected status 201, got %d", w.Code)
+		}
+	}
+
+	var gotIDs []string
+	url := "/api/tasks/?limit=2"
+	for pages := 0; url != ""; pages++ {
+		if pages > 3 {
+			t.Fatal("Pagination did not terminate")
+		}
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		signIn(req, "test-user")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+		}
+
+		var response struct {
+			Tasks      []*models.Task `json:"tasks"`
+			NextCursor string         `json:"next_cursor"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		for _, task := range response.Tasks {
+			gotIDs = append(gotIDs, task.ID)
+		}
+
+		link := w.Header().Get("Link")
+		if response.NextCursor == "" {
+			if link != "" {
+				t.Errorf("Expected no Link header on last page, got %q", link)
+			}
+			url = ""
+			continue
+		}
+		if !strings.Contains(link, `rel="next"`) {
+			t.Fatalf("Expected Link header with rel=next, got %q", link)
+		}
+		url = link[strings.Index(link, "<")+1 : strings.Index(link, ">")]
+	}
+
+	want := "page-000,page-001,page-002,page-003,page-004"
+	if strings.Join(gotIDs, ",") != want {
+		t.Errorf("Expected %s, got %v", want, gotIDs)
+	}
+}
+
+func TestListTasksUnpaginatedByDefault(t *testing.T) {
+	queries := setupTestDB(t)
+	router := NewAPIRouter(queries)
+
+	start := time.Date(2026, 2, 8, 0, 0, 0, 0, time.UTC)
+	const count = defaultPageLimit + 5
+	for i := range count {
+		task := models.Task{
+			ID:     fmt.Sprintf("many-%03d", i),
+			Title:  "Block",
+			Start:  start.Add(time.Duration(i) * time.Hour),
+			End:    start.Add(time.Duration(i)*time.Hour + 30*time.Minute),
+			UserID: "test-user",
+			Status: models.StatusScheduled,
+		}
+		if err := queries.CreateTask(t.Context(), task); err != nil {
+			t.Fatalf("CreateTask failed: %v", err)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/tasks/", nil)
+	signIn(req, "test-user")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var response map[string]any
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if tasks, _ := response["tasks"].([]any); len(tasks) != count {
+		t.Errorf("Expected all %d tasks without limit or cursor, got %d", count, len(tasks))
+	}
+	if _, ok := response["next_cursor"]; ok || w.Header().Get("Link") != "" {
+		t.Errorf("Expected no next page without limit or cursor")
+	}
+}
+
+func TestListTasksInvalidPagination(t *testing.T) {
+	queries := setupTestDB(t)
+	router := NewAPIRouter(queries)
+
+	for _, query := range []string{
+		"?limit=0",
+		"?limit=abc",
+		"?cursor=not-a-cursor",
+		"?sort=title&limit=10",
+		"?sort=-end&cursor=eyJzIjoiMjAyNi0wMi0wOFQwOTowMDowMFoiLCJpIjoicGFnZS0wMDAifQ",
+	} {
+		t.Run(query, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/tasks/"+query, nil)
+			signIn(req, "test-user")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("Expected status 400, got %d", w.Code)
+			}
+		})
+	}
+}
+
+func TestCreateTaskIDs(t *testing.T) {
+	queries := setupTestDB(t)
+	router := NewAPIRouter(queries)
+
+	create := func(task models.Task) *httptest.ResponseRecorder {
+		t.Helper()
+		task.UserID, task.Status = "test-user", models.StatusScheduled
+		return createTestTask(t, router, task)
+	}
+	day := time.Date(2026, 2, 8, 0, 0, 0, 0, time.UTC)
+
+	// without an id, each task gets its own
+	var ids []string
+	for i := range 2 {
+		start := day.Add(time.Duration(9+i) * time.Hour)
+		w := create(models.Task{Title: "Unnamed", Start: start, End: start.Add(time.Hour)})
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected status 201 without an id, got %d. Body: %s", w.Code, w.Body.String())
+		}
+		var created models.Task
+		if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		ids = append(ids, created.ID)
+	}
+	if ids[0] == "" || ids[0] == ids[1] {
+		t.Errorf("Expected two distinct generated ids, got %q", ids)
+	}
+
+	// a taken id is a conflict, not a server error
+	start := day.Add(14 * time.Hour)
+	w := create(models.Task{ID: ids[0], Title: "Clash", Start: start, End: start.Add(time.Hour)})
+	if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), string(codeDuplicate)) {
+		t.Errorf("Expected a duplicate conflict for a taken id, got %d. Body: %s", w.Code, w.Body.String())
+	}
+}
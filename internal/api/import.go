@@ -0,0 +1,215 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Adjanour/vesper/internal/database"
+	"github.com/Adjanour/vesper/internal/events"
+	"github.com/Adjanour/vesper/internal/ical"
+	"github.com/Adjanour/vesper/internal/models"
+)
+
+// maxImportBytes caps the size of an uploaded calendar
+const maxImportBytes = 5 << 20
+
+// Import outcomes reported per event
+const (
+	importCreated   = "created"
+	importDuplicate = "duplicate"
+	importOverlap   = "overlap"
+	importInvalid   = "invalid"
+)
+
+// errImportRejected rolls back an atomic import that had rejected events
+var errImportRejected = errors.New("import rejected")
+
+type importResult struct {
+	UID    string `json:"uid"`
+	TaskID string `json:"task_id,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// exportedTaskID returns the task ID behind a UID from Vesper's own export
+func exportedTaskID(uid string) (string, bool) {
+	id, ok := strings.CutSuffix(uid, "@vesper")
+	return id, ok && id != ""
+}
+
+// eventTask converts an imported VEVENT into a new task owned by userID.
+// The task gets its own ID; the event's UID is kept to recognise a re-import.
+func eventTask(ev ical.Event, userID string) models.Task {
+	status := models.StatusScheduled
+	if ev.Status == ical.StatusCancelled {
+		status = models.StatusDeleted
+	}
+	timezone := ""
+	if ev.RRule != "" {
+		timezone = ev.TZID
+	}
+	return models.Task{
+		ID:         newUserID(),
+		Title:      ev.Summary,
+		Start:      ev.Start,
+		End:        ev.End,
+		UserID:     userID,
+		Status:     status,
+		Recurrence: ev.RRule,
+		Timezone:   timezone,
+		ICalUID:    ev.UID,
+	}
+}
+
+// readCalendarUpload returns the calendar from a raw text/calendar body or a multipart "file" field
+func readCalendarUpload(r *http.Request) (io.Reader, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return r.Body, nil
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		return nil, errors.New(`missing "file" form field`)
+	}
+	return file, nil
+}
+
+func (ar *APIRouter) importCalendar(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := userIDFromRequest(r)
+
+	atomic, _ := strconv.ParseBool(r.URL.Query().Get("atomic"))
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+	upload, err := readCalendarUpload(r)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalid, err.Error())
+		return
+	}
+	cal, err := ical.Decode(upload)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalid, "invalid calendar: "+err.Error())
+		return
+	}
+
+	var results []importResult
+	importEvents := func(q *database.Queries) error {
+		results = make([]importResult, 0, len(cal.Events))
+		rejected := false
+		seen := make(map[string]bool, len(cal.Events))
+
+		for _, ev := range cal.Events {
+			res, err := importEvent(ctx, q, ev, userID, seen)
+			if err != nil {
+				return err
+			}
+			if res.Status == importOverlap || res.Status == importInvalid {
+				rejected = true
+			}
+			results = append(results, res)
+		}
+		if atomic && rejected {
+			return errImportRejected
+		}
+		return nil
+	}
+
+	if atomic {
+		err = ar.db.InTx(ctx, importEvents)
+	} else {
+		err = importEvents(ar.db)
+	}
+
+	// without a transaction, whatever was created before a failure stays
+	if err == nil || !atomic {
+		for _, res := range results {
+			if res.Status == importCreated {
+				ar.publish(r, events.TaskCreated, res.TaskID, nil)
+			}
+		}
+	}
+
+	if errors.Is(err, errImportRejected) {
+		WriteJsonResponse(w, http.StatusConflict, map[string]any{
+			"committed": false,
+			"results":   results,
+		})
+		return
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	WriteJsonResponse(w, http.StatusOK, map[string]any{
+		"committed": true,
+		"results":   results,
+	})
+}
+
+// importEvent creates the task for a single event. Rejections are reported in
+// the result; the error is reserved for storage failures.
+func importEvent(ctx context.Context, q *database.Queries, ev ical.Event, userID string, seen map[string]bool) (importResult, error) {
+	res := importResult{UID: ev.UID}
+
+	switch {
+	case ev.Err != nil:
+		res.Status, res.Error = importInvalid, ev.Err.Error()
+		return res, nil
+	case ev.UID == "":
+		res.Status, res.Error = importInvalid, "UID is required"
+		return res, nil
+	case ev.RecurrenceID != nil:
+		res.Status, res.Error = importInvalid, "recurrence overrides are not supported"
+		return res, nil
+	}
+
+	if seen[ev.UID] {
+		res.Status = importDuplicate
+		return res, nil
+	}
+	seen[ev.UID] = true
+
+	// UIDs are only matched against the caller's own tasks: a task exported
+	// by Vesper, or one created by an earlier import
+	if id, ok := exportedTaskID(ev.UID); ok {
+		if existing, err := q.GetTask(ctx, id); err == nil && existing.UserID == userID {
+			res.TaskID, res.Status = existing.ID, importDuplicate
+			return res, nil
+		} else if err != nil && !errors.Is(err, database.ErrNotFound) {
+			return res, err
+		}
+	}
+	if existing, err := q.GetTaskByICalUID(ctx, userID, ev.UID); err == nil {
+		res.TaskID, res.Status = existing.ID, importDuplicate
+		return res, nil
+	} else if !errors.Is(err, database.ErrNotFound) {
+		return res, err
+	}
+
+	t := eventTask(ev, userID)
+	if err := validateTask(&t); err != nil {
+		res.Status, res.Error = importInvalid, err.Error()
+		return res, nil
+	}
+
+	if err := q.CreateTask(ctx, t); err != nil {
+		switch {
+		case errors.Is(err, database.ErrTaskOverlap):
+			res.Status, res.Error = importOverlap, "task overlaps with existing task"
+		case errors.Is(err, database.ErrInvalid):
+			res.Status, res.Error = importInvalid, err.Error()
+		default:
+			return res, err
+		}
+		return res, nil
+	}
+
+	res.TaskID, res.Status = t.ID, importCreated
+	return res, nil
+}
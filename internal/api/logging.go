@@ -0,0 +1,74 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Adjanour/vesper/internal/logging"
+	"github.com/Adjanour/vesper/internal/metrics"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// requestLog collects what the access log line needs from handlers further
+// down the chain, which only see a derived context
+type requestLog struct {
+	userID string
+}
+
+// logRequests tags each request with an ID, taken from X-Request-ID when the
+// client or a proxy sent a usable one, and logs and counts it once it has
+// been served
+func (ar *APIRouter) logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		id := r.Header.Get(requestIDHeader)
+		if !logging.ValidRequestID(id) {
+			id = logging.NewRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+
+		entry := &requestLog{}
+		ctx := logging.WithRequestID(r.Context(), id)
+		ctx = context.WithValue(ctx, requestLogContextKey, entry)
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		// the pattern, unlike the path, groups requests for the same route
+		route := ""
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			route = rctx.RoutePattern()
+		}
+		elapsed := time.Since(start)
+		metrics.ObserveRequest(r.Method, route, status, elapsed)
+		ar.logger.LogAttrs(ctx, level, "request",
+			slog.String("method", r.Method),
+			slog.String("route", route),
+			slog.Int("status", status),
+			slog.Duration("latency", elapsed),
+			slog.String("user_id", entry.userID),
+			slog.Int("bytes", ww.BytesWritten()),
+		)
+	})
+}
+
+// setLoggedUser records who the request was authenticated as
+func setLoggedUser(r *http.Request, userID string) {
+	if entry, ok := r.Context().Value(requestLogContextKey).(*requestLog); ok {
+		entry.userID = userID
+	}
+}
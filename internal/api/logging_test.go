@@ -0,0 +1,122 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Adjanour/vesper/internal/database"
+	"github.com/Adjanour/vesper/internal/logging"
+)
+
+// logLines decodes the JSON log records written to buf
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var lines []map[string]any
+	for line := range strings.SplitSeq(strings.TrimSpace(buf.String()), "\n") {
+		var rec map[string]any
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("Invalid log line %q: %v", line, err)
+		}
+		lines = append(lines, rec)
+	}
+	return lines
+}
+
+func TestRequestLogging(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, "json", slog.LevelInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := NewAPIRouter(setupTestDB(t), WithLogger(logger))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/tasks/missing", nil)
+	req.Header.Set("X-Request-ID", "req-42")
+	signIn(req, "test-user")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if got := w.Header().Get("X-Request-ID"); got != "req-42" {
+		t.Errorf("Expected the incoming request ID to be echoed, got %q", got)
+	}
+	lines := logLines(t, &buf)
+	if len(lines) != 1 {
+		t.Fatalf("Expected one access log line, got %d", len(lines))
+	}
+	want := map[string]any{
+		"msg":        "request",
+		"method":     "GET",
+		"route":      "/api/tasks/{id}",
+		"status":     float64(http.StatusNotFound),
+		"user_id":    "test-user",
+		"request_id": "req-42",
+	}
+	for key, value := range want {
+		if lines[0][key] != value {
+			t.Errorf("Expected %s=%v, got %v", key, value, lines[0][key])
+		}
+	}
+	if _, ok := lines[0]["latency"]; !ok {
+		t.Error("Expected the latency to be logged")
+	}
+
+	// an unusable ID is replaced rather than logged
+	buf.Reset()
+	req = httptest.NewRequest(http.MethodGet, "/api/health", nil)
+	req.Header.Set("X-Request-ID", "bad id\n")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	id := w.Header().Get("X-Request-ID")
+	if id == "" || id == "bad id\n" {
+		t.Errorf("Expected a generated request ID, got %q", id)
+	}
+	if lines := logLines(t, &buf); lines[0]["request_id"] != id || lines[0]["user_id"] != "" {
+		t.Errorf("Unexpected log line for an anonymous request: %v", lines[0])
+	}
+}
+
+func TestDatabaseErrorsLogRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, "json", slog.LevelInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(logger)
+
+	// every query fails once the database is closed
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	router := NewAPIRouter(database.NewQueries(db), WithLogger(logger))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/tasks/", nil)
+	req.Header.Set("X-Request-ID", "req-500")
+	signIn(req, "test-user")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected status 500, got %d", w.Code)
+	}
+
+	var found bool
+	for _, line := range logLines(t, &buf) {
+		if line["msg"] == "database query failed" {
+			found = true
+			if line["request_id"] != "req-500" {
+				t.Errorf("Expected the database error to carry the request ID, got %v", line)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("Expected the failed query to be logged:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/Adjanour/vesper/internal/metrics"
+)
+
+// scrape returns the value of the first series matching pattern, or "-1"
+func scrape(t *testing.T, pattern string) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	m := regexp.MustCompile("(?m)^" + pattern + " (\\S+)$").FindStringSubmatch(w.Body.String())
+	if m == nil {
+		return "-1"
+	}
+	return m[1]
+}
+
+func TestRequestMetrics(t *testing.T) {
+	router := NewAPIRouter(setupTestDB(t))
+	overlapsBefore := scrape(t, `vesper_tasks_overlap_rejections_total`)
+
+	cookie := &http.Cookie{Name: sessionCookieName, Value: testSessionToken("test-user")}
+	postJSON(router, "/api/tasks/", newTestTask("metered"), cookie)
+	if w := postJSON(router, "/api/tasks/", newTestTask("metered-twin"), cookie); w.Code != http.StatusConflict {
+		t.Fatalf("Expected status 409 for an overlapping task, got %d", w.Code)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/api/tasks/nope", nil)
+	signIn(req, "test-user")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	series := []string{
+		`vesper_http_requests_total\{method="POST",route="/api/tasks",status="409"\}`,
+		`vesper_http_request_duration_seconds_count\{method="GET",route="/api/tasks/\{id\}",status="404"\}`,
+		`vesper_db_query_duration_seconds_count\{query="GetTask"\}`,
+		`vesper_db_query_duration_seconds_count\{query="scheduledOccurrences"\}`,
+	}
+	for _, s := range series {
+		if v := scrape(t, s); v == "-1" || v == "0" {
+			t.Errorf("Expected a non-zero %s, got %s", s, v)
+		}
+	}
+	// the raw path never becomes a label
+	if v := scrape(t, `vesper_http_requests_total\{method="GET",route="/api/tasks/nope",status="404"\}`); v != "-1" {
+		t.Errorf("Expected no series for the raw path, got %s", v)
+	}
+	if after := scrape(t, `vesper_tasks_overlap_rejections_total`); after == overlapsBefore {
+		t.Errorf("Expected the overlap rejection to be counted, still %s", after)
+	}
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/Adjanour/vesper/internal/database"
+	"github.com/Adjanour/vesper/internal/models"
+)
+
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 500
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// cursorPayload is the JSON shape behind an opaque page cursor
+type cursorPayload struct {
+	Start time.Time `json:"s"`
+	ID    string    `json:"i"`
+}
+
+func encodeCursor(c database.TaskCursor) string {
+	payload, _ := json.Marshal(cursorPayload{Start: c.Start.UTC(), ID: c.ID})
+	return base64.RawURLEncoding.EncodeToString(payload)
+}
+
+func decodeCursor(s string) (*database.TaskCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	var p cursorPayload
+	if err := json.Unmarshal(raw, &p); err != nil || p.ID == "" || p.Start.IsZero() {
+		return nil, errInvalidCursor
+	}
+	return &database.TaskCursor{Start: p.Start, ID: p.ID}, nil
+}
+
+// parsePagination reads the limit and cursor query parameters into f.
+// Listings are only paginated when the client asks for it with either one,
+// so clients that know nothing of pages still get every task.
+func parsePagination(r *http.Request, f *database.TaskFilter) error {
+	query := r.URL.Query()
+	if !query.Has("limit") && !query.Has("cursor") {
+		return nil
+	}
+	if f.Sort != "" && f.Sort != database.SortStartAsc && f.Sort != database.SortStartDesc {
+		// other orderings are not keyset-paginated
+		return errors.New("pagination requires sort=start or sort=-start")
+	}
+
+	f.Limit = defaultPageLimit
+	if v := query.Get("limit"); query.Has("limit") {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPageLimit {
+			return fmt.Errorf("limit must be between 1 and %d", maxPageLimit)
+		}
+		f.Limit = n
+	}
+
+	if query.Has("cursor") {
+		c, err := decodeCursor(query.Get("cursor"))
+		if err != nil {
+			return err
+		}
+		f.After = c
+	}
+	return nil
+}
+
+// nextPage trims the look-ahead row fetched past the limit and returns the cursor for the following page
+func nextPage(tasks []*models.Task, limit int) ([]*models.Task, string) {
+	if limit == 0 || len(tasks) <= limit {
+		return tasks, ""
+	}
+	tasks = tasks[:limit]
+	last := tasks[len(tasks)-1]
+	return tasks, encodeCursor(database.TaskCursor{Start: last.Start, ID: last.ID})
+}
+
+// setNextLink advertises the next page through an RFC 8288 Link header
+func setNextLink(w http.ResponseWriter, r *http.Request, cursor string) {
+	u := url.URL{Path: r.URL.Path}
+	query := r.URL.Query()
+	query.Set("cursor", cursor)
+	u.RawQuery = query.Encode()
+	w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, u.String()))
+}
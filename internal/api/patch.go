@@ -0,0 +1,77 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/Adjanour/vesper/internal/jsonpatch"
+	"github.com/Adjanour/vesper/internal/models"
+)
+
+const (
+	mergePatchType = "application/merge-patch+json"
+	jsonPatchType  = "application/json-patch+json"
+)
+
+// maxPatchBytes caps the size of a patch document
+const maxPatchBytes = 64 << 10
+
+// patchTask changes only the fields a patch names. The patch applies to the
+// task as stored, and the result is validated and overlap-checked as a PUT
+// would be. Plain application/json bodies are read as merge patches.
+func (ar *APIRouter) patchTask(w http.ResponseWriter, r *http.Request) {
+	existing, ok := ar.ownedTask(w, r)
+	if !ok {
+		return
+	}
+	version, ok := ar.ifMatchVersion(w, r, existing)
+	if !ok {
+		return
+	}
+
+	var apply func(doc, patch []byte) ([]byte, error)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case mergePatchType, "application/json":
+		apply = jsonpatch.Merge
+	case jsonPatchType:
+		apply = jsonpatch.Apply
+	default:
+		w.Header().Set("Accept-Patch", mergePatchType+", "+jsonPatchType)
+		writeProblem(w, r, http.StatusUnsupportedMediaType, codeUnsupportedMedia, "patches must be "+mergePatchType+" or "+jsonPatchType)
+		return
+	}
+
+	patch, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPatchBytes))
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidPatch, "patch is too large or unreadable")
+		return
+	}
+	doc, err := json.Marshal(existing)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	patched, err := apply(doc, patch)
+	switch {
+	case errors.Is(err, jsonpatch.ErrConflict):
+		writeProblem(w, r, http.StatusConflict, codePatchConflict, err.Error())
+		return
+	case err != nil:
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidPatch, err.Error())
+		return
+	}
+
+	var t models.Task
+	if err := json.Unmarshal(patched, &t); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidPatch, "patched task is not a task: "+err.Error())
+		return
+	}
+	// the task keeps its identity and owner, as with PUT
+	t.ID, t.UserID, t.Version = existing.ID, existing.UserID, version
+
+	ar.saveTask(w, r, t)
+}
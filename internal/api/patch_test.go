@@ -0,0 +1,105 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Adjanour/vesper/internal/models"
+)
+
+func TestPatchTask(t *testing.T) {
+	queries := setupTestDB(t)
+	router := NewAPIRouter(queries)
+
+	patch := func(contentType, body string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, "/api/tasks/focus", strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		signIn(req, "test-user")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	at := func(hour, minute int) time.Time { return time.Date(2026, 2, 8, hour, minute, 0, 0, time.UTC) }
+	for _, task := range []models.Task{
+		{ID: "focus", Title: "Focus", Start: at(9, 0), End: at(10, 0), UserID: "test-user", Status: models.StatusScheduled, Recurrence: "FREQ=DAILY;COUNT=3"},
+		{ID: "lunch", Title: "Lunch", Start: at(12, 0), End: at(13, 0), UserID: "test-user", Status: models.StatusScheduled},
+	} {
+		if w := createTestTask(t, router, task); w.Code != http.StatusCreated {
+			t.Fatalf("Expected status 201, got %d. Body: %s", w.Code, w.Body.String())
+		}
+	}
+
+	// resizing sends only the new end
+	w := patch(mergePatchType, `{"end": "2026-02-08T10:30:00Z"}`, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	var resized models.Task
+	if err := json.NewDecoder(w.Body).Decode(&resized); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resized.Title != "Focus" || !resized.Start.Equal(at(9, 0)) || !resized.End.Equal(at(10, 30)) || resized.Recurrence != "FREQ=DAILY;COUNT=3" {
+		t.Errorf("Expected only the end to change, got %+v", resized)
+	}
+	if etag := w.Header().Get("ETag"); etag != `"2"` {
+		t.Errorf(`Expected ETag "2", got %q`, etag)
+	}
+
+	// null removes a member: the recurrence goes, a missing title is refused
+	if w := patch("application/json", `{"recurrence": null}`, nil); w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 removing the recurrence, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	if task, _ := queries.GetTask(t.Context(), "focus"); task.Recurrence != "" {
+		t.Errorf("Expected the recurrence to be removed, got %q", task.Recurrence)
+	}
+	if w := patch(mergePatchType, `{"title": null}`, nil); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), string(codeValidation)) {
+		t.Errorf("Expected a validation failure removing the title, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	// the patched task is overlap-checked
+	if w := patch(mergePatchType, `{"end": "2026-02-08T12:30:00Z"}`, nil); w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), string(codeTaskOverlap)) {
+		t.Errorf("Expected an overlap conflict, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	w = patch(jsonPatchType, `[
+		{"op": "test", "path": "/title", "value": "Focus"},
+		{"op": "replace", "path": "/title", "value": "Deep focus"}
+	]`, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	if task, _ := queries.GetTask(t.Context(), "focus"); task.Title != "Deep focus" {
+		t.Errorf("Expected the JSON Patch to rename the task, got %q", task.Title)
+	}
+	if w := patch(jsonPatchType, `[{"op": "test", "path": "/title", "value": "Focus"}]`, nil); w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), string(codePatchConflict)) {
+		t.Errorf("Expected a patch conflict for a failed test, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	if w := patch(jsonPatchType, `{"op": "remove"}`, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a malformed JSON Patch, got %d", w.Code)
+	}
+
+	w = patch("text/plain", `end=10:30`, nil)
+	if w.Code != http.StatusUnsupportedMediaType || w.Header().Get("Accept-Patch") == "" {
+		t.Errorf("Expected status 415 with Accept-Patch, got %d", w.Code)
+	}
+
+	if w := patch(mergePatchType, `{"title": "Stale"}`, http.Header{"If-Match": {`"1"`}}); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected status 412 for a stale If-Match, got %d", w.Code)
+	}
+
+	// id and owner are not patchable
+	if w := patch(mergePatchType, `{"id": "other", "user_id": "other-user"}`, nil); w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	if task, err := queries.GetTask(t.Context(), "focus"); err != nil || task.UserID != "test-user" {
+		t.Errorf("Expected focus to keep its id and owner, got %+v (%v)", task, err)
+	}
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/Adjanour/vesper/internal/database"
+	"github.com/Adjanour/vesper/internal/models"
+	"github.com/Adjanour/vesper/internal/planning"
+	"github.com/go-chi/chi/v5"
+)
+
+var sendAtPattern = regexp.MustCompile(`^([01][0-9]|2[0-3]):[0-5][0-9]$`)
+
+// scheduleRequest is the body for setting the caller's planning schedule
+type scheduleRequest struct {
+	Timezone string `json:"timezone"`
+	SendAt   string `json:"send_at"`
+	Enabled  bool   `json:"enabled"`
+}
+
+func (ar *APIRouter) getPlanningSchedule(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromRequest(r)
+
+	schedule, err := ar.db.GetPlanningSchedule(r.Context(), userID)
+	if err != nil {
+		if !errors.Is(err, database.ErrNotFound) {
+			writeError(w, r, err)
+			return
+		}
+		// users without a schedule see the defaults, disabled
+		schedule = &models.PlanningSchedule{UserID: userID, Timezone: "UTC", SendAt: "21:00"}
+	}
+
+	WriteJsonResponse(w, http.StatusOK, schedule)
+}
+
+func (ar *APIRouter) updatePlanningSchedule(w http.ResponseWriter, r *http.Request) {
+	var req scheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidJSON, "invalid JSON")
+		return
+	}
+	if req.Timezone == "" {
+		req.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(req.Timezone); err != nil {
+		writeInvalidField(w, r, "timezone", "invalid timezone")
+		return
+	}
+	if !sendAtPattern.MatchString(req.SendAt) {
+		writeInvalidField(w, r, "send_at", "send_at must be HH:MM")
+		return
+	}
+
+	schedule := models.PlanningSchedule{
+		UserID:    userIDFromRequest(r),
+		Timezone:  req.Timezone,
+		SendAt:    req.SendAt,
+		Enabled:   req.Enabled,
+		UpdatedAt: time.Now().UTC(),
+	}
+	if err := ar.db.UpsertPlanningSchedule(r.Context(), schedule); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	stored, err := ar.db.GetPlanningSchedule(r.Context(), schedule.UserID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	WriteJsonResponse(w, http.StatusOK, stored)
+}
+
+// getPlanningSession opens a session from a signed planning link; the
+// signature stands in for authentication, so no user header is needed.
+func (ar *APIRouter) getPlanningSession(w http.ResponseWriter, r *http.Request) {
+	if ar.signer == nil {
+		writeProblem(w, r, http.StatusNotFound, codeNotFound, "planning links are not configured")
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	query := r.URL.Query()
+	if err := ar.signer.Verify(id, query.Get("expires"), query.Get("sig"), time.Now()); err != nil {
+		if errors.Is(err, planning.ErrLinkExpired) {
+			writeProblem(w, r, http.StatusGone, codeExpired, "planning link expired")
+			return
+		}
+		writeProblem(w, r, http.StatusForbidden, codeForbidden, "invalid planning link")
+		return
+	}
+
+	session, err := ar.db.GetPlanningSession(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			writeProblem(w, r, http.StatusNotFound, codeNotFound, "planning session not found")
+			return
+		}
+		writeError(w, r, err)
+		return
+	}
+
+	WriteJsonResponse(w, http.StatusOK, session)
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Adjanour/vesper/internal/models"
+	"github.com/Adjanour/vesper/internal/planning"
+)
+
+func TestPlanningLinkOpensSession(t *testing.T) {
+	queries := setupTestDB(t)
+	signer := planning.NewSigner([]byte("test-secret"), "https://vesper.example/", time.Hour)
+	router := NewAPIRouter(queries, WithLinkSigner(signer))
+
+	if _, err := queries.EnsurePlanningSession(t.Context(), "plan-1", "test-user", "2026-02-09"); err != nil {
+		t.Fatalf("EnsurePlanningSession() error: %v", err)
+	}
+
+	// follow a link the way the web UI does: the page at / hands plan,
+	// expires and sig to the session endpoint, without signing in
+	open := func(link string) *httptest.ResponseRecorder {
+		t.Helper()
+		u, err := url.Parse(link)
+		if err != nil {
+			t.Fatalf("Link is not a URL: %v", err)
+		}
+		if u.Path != "/" {
+			t.Fatalf("Expected the link to open the web UI at /, got %s", u.Path)
+		}
+		params := u.Query()
+		query := url.Values{"expires": {params.Get("expires")}, "sig": {params.Get("sig")}}
+		req := httptest.NewRequest(http.MethodGet, "/api/planning/sessions/"+url.PathEscape(params.Get("plan"))+"?"+query.Encode(), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	link, _ := signer.Link("plan-1", time.Now())
+	w := open(link)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	var session models.PlanningSession
+	if err := json.NewDecoder(w.Body).Decode(&session); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if session.ID != "plan-1" || session.PlanDate != "2026-02-09" {
+		t.Errorf("Expected the session for 2026-02-09, got %+v", session)
+	}
+
+	u, _ := url.Parse(link)
+	params := u.Query()
+	params.Set("plan", "plan-2")
+	u.RawQuery = params.Encode()
+	if w := open(u.String()); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for a link signed for another session, got %d", w.Code)
+	}
+
+	expired, _ := signer.Link("plan-1", time.Now().Add(-2*time.Hour))
+	if w := open(expired); w.Code != http.StatusGone {
+		t.Errorf("Expected status 410 for an expired link, got %d", w.Code)
+	}
+}
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/Adjanour/vesper/internal/database"
+	"github.com/Adjanour/vesper/internal/logging"
+)
+
+const problemContentType = "application/problem+json"
+
+// errorCode identifies a kind of error to clients. Unlike the detail text,
+// codes are stable and safe to branch on.
+type errorCode string
+
+const (
+	codeInvalidJSON          errorCode = "invalid_json"
+	codeValidation           errorCode = "validation_failed"
+	codeInvalidParameter     errorCode = "invalid_parameter"
+	codeInvalidPatch         errorCode = "invalid_patch"
+	codeInvalid              errorCode = "invalid"
+	codeNotFound             errorCode = "not_found"
+	codeMethodNotAllowed     errorCode = "method_not_allowed"
+	codeUnsupportedMedia     errorCode = "unsupported_media_type"
+	codeTaskOverlap          errorCode = "task_overlap"
+	codeTaskInactive         errorCode = "task_inactive"
+	codePatchConflict        errorCode = "patch_conflict"
+	codePreconditionFailed   errorCode = "precondition_failed"
+	codePreconditionRequired errorCode = "precondition_required"
+	codeDuplicate            errorCode = "duplicate"
+	codeUnauthenticated      errorCode = "unauthenticated"
+	codeInvalidCredentials   errorCode = "invalid_credentials"
+	codeForbidden            errorCode = "forbidden"
+	codeExpired              errorCode = "expired"
+	codeInternal             errorCode = "internal_error"
+)
+
+// problem is an RFC 9457 problem details body, extended with the error code,
+// any field errors and the request ID to quote when reporting it
+type problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      errorCode    `json:"code"`
+	Errors    []fieldError `json:"errors,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+}
+
+// fieldError explains why one field of a request body was rejected
+type fieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// validationError collects every field a request got wrong, so clients can
+// show them all at once
+type validationError []fieldError
+
+func (v validationError) Error() string {
+	messages := make([]string, len(v))
+	for i, f := range v {
+		messages[i] = f.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// writeProblem answers with a problem. The type is about:blank: the status
+// and code say everything a client needs to tell errors apart.
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, code errorCode, detail string, fields ...fieldError) {
+	p := problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		Code:      code,
+		Errors:    fields,
+		RequestID: logging.RequestID(r.Context()),
+	}
+	body, _ := json.Marshal(p)
+
+	w.Header().Set("Content-Type", problemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
+
+// writeInvalidField rejects a request because of a single field
+func writeInvalidField(w http.ResponseWriter, r *http.Request, field, message string) {
+	writeProblem(w, r, http.StatusBadRequest, codeValidation, message, fieldError{Field: field, Message: message})
+}
+
+// writeError answers with the problem err stands for. Validation failures
+// and the database package's domain errors map to client errors; anything
+// else is logged and reported as a 500 without its details.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	var invalid validationError
+	switch {
+	case errors.As(err, &invalid):
+		writeProblem(w, r, http.StatusBadRequest, codeValidation, invalid.Error(), invalid...)
+	case errors.Is(err, database.ErrNotFound):
+		writeProblem(w, r, http.StatusNotFound, codeNotFound, "not found")
+	case errors.Is(err, database.ErrTaskOverlap):
+		writeProblem(w, r, http.StatusConflict, codeTaskOverlap, "task overlaps with existing task")
+	case errors.Is(err, database.ErrTaskInactive):
+		writeProblem(w, r, http.StatusConflict, codeTaskInactive, "task is no longer scheduled")
+	case errors.Is(err, database.ErrVersionMismatch):
+		writeProblem(w, r, http.StatusPreconditionFailed, codePreconditionFailed, "task has changed since it was read")
+	case errors.Is(err, database.ErrDuplicate):
+		writeProblem(w, r, http.StatusConflict, codeDuplicate, err.Error())
+	case errors.Is(err, database.ErrInvalid):
+		writeProblem(w, r, http.StatusBadRequest, codeInvalid, err.Error())
+	case errors.Is(err, database.ErrUnauthorized):
+		writeProblem(w, r, http.StatusUnauthorized, codeUnauthenticated, "authentication required")
+	default:
+		slog.ErrorContext(r.Context(), "request failed", "error", err)
+		writeProblem(w, r, http.StatusInternalServerError, codeInternal, "internal server error")
+	}
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Adjanour/vesper/internal/models"
+)
+
+func TestErrorProblems(t *testing.T) {
+	queries := setupTestDB(t)
+	router := NewAPIRouter(queries)
+
+	existing, _ := json.Marshal(models.Task{
+		ID:     "problem-001",
+		Title:  "Existing",
+		Start:  time.Date(2026, 2, 8, 9, 0, 0, 0, time.UTC),
+		End:    time.Date(2026, 2, 8, 10, 0, 0, 0, time.UTC),
+		Status: models.StatusScheduled,
+	})
+	overlapping, _ := json.Marshal(models.Task{
+		ID:     "problem-002",
+		Title:  "Overlapping",
+		Start:  time.Date(2026, 2, 8, 9, 30, 0, 0, time.UTC),
+		End:    time.Date(2026, 2, 8, 10, 30, 0, 0, time.UTC),
+		Status: models.StatusScheduled,
+	})
+
+	later, _ := json.Marshal(models.Task{
+		ID:     "problem-003",
+		Title:  "Later",
+		Start:  time.Date(2026, 2, 8, 11, 0, 0, 0, time.UTC),
+		End:    time.Date(2026, 2, 8, 12, 0, 0, 0, time.UTC),
+		Status: models.StatusScheduled,
+	})
+	movedOnto, _ := json.Marshal(models.Task{
+		Title:  "Later",
+		Start:  time.Date(2026, 2, 8, 9, 30, 0, 0, time.UTC),
+		End:    time.Date(2026, 2, 8, 10, 30, 0, 0, time.UTC),
+		Status: models.StatusScheduled,
+	})
+
+	for _, body := range [][]byte{existing, later} {
+		req := httptest.NewRequest(http.MethodPost, "/api/tasks/", bytes.NewReader(body))
+		signIn(req, "test-user")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Failed to create task: %d %s", w.Code, w.Body.String())
+		}
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		signedIn bool
+		status   int
+		code     errorCode
+		fields   []string
+	}{
+		{"overlap", http.MethodPost, "/api/tasks/", string(overlapping), true, http.StatusConflict, codeTaskOverlap, nil},
+		{"overlapping update", http.MethodPut, "/api/tasks/problem-003", string(movedOnto), true, http.StatusConflict, codeTaskOverlap, nil},
+		{"every invalid field", http.MethodPost, "/api/tasks/", `{"id": "x"}`, true, http.StatusBadRequest, codeValidation, []string{"title", "start", "end", "status"}},
+		{"invalid JSON", http.MethodPost, "/api/tasks/", `{`, true, http.StatusBadRequest, codeInvalidJSON, nil},
+		{"missing task", http.MethodGet, "/api/tasks/nope", "", true, http.StatusNotFound, codeNotFound, nil},
+		{"unknown route", http.MethodGet, "/api/nope", "", true, http.StatusNotFound, codeNotFound, nil},
+		{"wrong method", http.MethodPatch, "/api/health", "", true, http.StatusMethodNotAllowed, codeMethodNotAllowed, nil},
+		{"signed out", http.MethodGet, "/api/tasks", "", false, http.StatusUnauthorized, codeUnauthenticated, nil},
+		{"bad parameter", http.MethodGet, "/api/tasks?sort=sideways", "", true, http.StatusBadRequest, codeInvalidParameter, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.signedIn {
+				signIn(req, "test-user")
+			}
+			req.Header.Set("X-Request-ID", "problem-test")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+			if ct := w.Header().Get("Content-Type"); ct != problemContentType {
+				t.Errorf("Expected a problem+json response, got %q", ct)
+			}
+
+			var p problem
+			if err := json.NewDecoder(w.Body).Decode(&p); err != nil {
+				t.Fatalf("Failed to decode problem: %v", err)
+			}
+			if p.Code != tt.code || p.Status != tt.status || p.RequestID != "problem-test" {
+				t.Errorf("Unexpected problem: %+v", p)
+			}
+
+			var fields []string
+			for _, f := range p.Errors {
+				fields = append(fields, f.Field)
+			}
+			if strings.Join(fields, ",") != strings.Join(tt.fields, ",") {
+				t.Errorf("Expected field errors for %v, got %v", tt.fields, fields)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Adjanour/vesper/internal/database"
+	"github.com/Adjanour/vesper/internal/events"
+	"github.com/go-chi/chi/v5"
+)
+
+// occurrenceRequest is the body for editing a single occurrence of a recurring task
+type occurrenceRequest struct {
+	Title string    `json:"title"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+func recurrenceIDFromURL(r *http.Request) (time.Time, error) {
+	return time.Parse(time.RFC3339, chi.URLParam(r, "recurrenceID"))
+}
+
+func (ar *APIRouter) updateOccurrence(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+
+	recurrenceID, err := recurrenceIDFromURL(r)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidParameter, "invalid recurrence id")
+		return
+	}
+
+	var req occurrenceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidJSON, "invalid JSON")
+		return
+	}
+	if req.Start.IsZero() {
+		writeInvalidField(w, r, "start", "start time is required")
+		return
+	}
+	if req.End.IsZero() {
+		writeInvalidField(w, r, "end", "end time is required")
+		return
+	}
+	if !req.End.After(req.Start) {
+		writeInvalidField(w, r, "end", "end time must be after start time")
+		return
+	}
+
+	if _, ok := ar.ownedTask(w, r); !ok {
+		return
+	}
+
+	occurrence, err := ar.db.UpdateOccurrence(ctx, id, recurrenceID, req.Title, req.Start, req.End)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrNotFound):
+			writeProblem(w, r, http.StatusNotFound, codeNotFound, "occurrence not found")
+		case errors.Is(err, database.ErrTaskOverlap):
+			writeProblem(w, r, http.StatusConflict, codeTaskOverlap, "task overlaps with existing task")
+		case errors.Is(err, database.ErrInvalid):
+			writeProblem(w, r, http.StatusBadRequest, codeInvalid, "task is not recurring")
+		default:
+			writeError(w, r, err)
+		}
+		return
+	}
+
+	ar.publish(r, events.TaskUpdated, id, occurrence)
+	WriteJsonResponse(w, http.StatusOK, occurrence)
+}
+
+func (ar *APIRouter) cancelOccurrence(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+
+	recurrenceID, err := recurrenceIDFromURL(r)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidParameter, "invalid recurrence id")
+		return
+	}
+
+	if _, ok := ar.ownedTask(w, r); !ok {
+		return
+	}
+
+	if err := ar.db.CancelOccurrence(ctx, id, recurrenceID); err != nil {
+		switch {
+		case errors.Is(err, database.ErrNotFound):
+			writeProblem(w, r, http.StatusNotFound, codeNotFound, "occurrence not found")
+		case errors.Is(err, database.ErrInvalid):
+			writeProblem(w, r, http.StatusBadRequest, codeInvalid, "task is not recurring")
+		default:
+			writeError(w, r, err)
+		}
+		return
+	}
+
+	ar.publish(r, events.TaskUpdated, id, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
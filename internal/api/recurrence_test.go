@@ -0,0 +1,291 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Adjanour/vesper/internal/models"
+)
+
+func createTestTask(t *testing.T, router http.Handler, task models.Task) *httptest.ResponseRecorder {
+	t.Helper()
+	body, _ := json.Marshal(task)
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks/", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	signIn(req, task.UserID)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func listTestTasks(t *testing.T, router http.Handler, query string) []*models.Task {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/api/tasks/"+query, nil)
+	signIn(req, "test-user")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 listing tasks, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	var response map[string][]*models.Task
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return response["tasks"]
+}
+
+func TestRecurringTaskExpandsInWindow(t *testing.T) {
+	queries := setupTestDB(t)
+	router := NewAPIRouter(queries)
+
+	standup := models.Task{
+		ID:         "standup",
+		Title:      "Standup",
+		Start:      time.Date(2026, 2, 2, 9, 0, 0, 0, time.UTC), // Monday
+		End:        time.Date(2026, 2, 2, 9, 15, 0, 0, time.UTC),
+		UserID:     "test-user",
+		Status:     models.StatusScheduled,
+		Recurrence: "FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR",
+	}
+	if w := createTestTask(t, router, standup); w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	tasks := listTestTasks(t, router, "?from=2026-02-09&to=2026-02-16")
+	if len(tasks) != 5 {
+		t.Fatalf("Expected 5 weekday occurrences, got %d", len(tasks))
+	}
+	for i, task := range tasks {
+		want := time.Date(2026, 2, 9+i, 9, 0, 0, 0, time.UTC)
+		if !task.Start.Equal(want) {
+			t.Errorf("Occurrence %d: expected start %v, got %v", i, want, task.Start)
+		}
+		if task.ID != "standup" || task.RecurrenceID == nil || !task.RecurrenceID.Equal(want) {
+			t.Errorf("Occurrence %d: expected series id and recurrence_id %v, got %s %v", i, want, task.ID, task.RecurrenceID)
+		}
+	}
+
+	// without a window the series is listed once
+	if tasks := listTestTasks(t, router, ""); len(tasks) != 1 || tasks[0].Recurrence == "" {
+		t.Errorf("Expected the series row with its recurrence, got %+v", tasks)
+	}
+}
+
+func TestRecurringTaskOverlapDetection(t *testing.T) {
+	queries := setupTestDB(t)
+	router := NewAPIRouter(queries)
+
+	deepWork := models.Task{
+		ID:         "deep-work",
+		Title:      "Deep Work",
+		Start:      time.Date(2026, 2, 2, 9, 0, 0, 0, time.UTC), // Monday
+		End:        time.Date(2026, 2, 2, 11, 0, 0, 0, time.UTC),
+		UserID:     "test-user",
+		Status:     models.StatusScheduled,
+		Recurrence: "FREQ=WEEKLY;BYDAY=MO",
+	}
+	if w := createTestTask(t, router, deepWork); w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	// a one-off block three weeks later collides with an expanded occurrence
+	clash := models.Task{
+		ID:     "clash",
+		Title:  "Dentist",
+		Start:  time.Date(2026, 2, 23, 10, 0, 0, 0, time.UTC),
+		End:    time.Date(2026, 2, 23, 10, 30, 0, 0, time.UTC),
+		UserID: "test-user",
+		Status: models.StatusScheduled,
+	}
+	if w := createTestTask(t, router, clash); w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for block over an occurrence, got %d", w.Code)
+	}
+
+	// a Tuesday block is fine
+	clash.Start, clash.End = clash.Start.AddDate(0, 0, 1), clash.End.AddDate(0, 0, 1)
+	if w := createTestTask(t, router, clash); w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201 for Tuesday block, got %d", w.Code)
+	}
+
+	// a new series that lands on that Tuesday block is rejected
+	series := models.Task{
+		ID:         "review",
+		Title:      "Review",
+		Start:      time.Date(2026, 2, 3, 10, 0, 0, 0, time.UTC),
+		End:        time.Date(2026, 2, 3, 11, 0, 0, 0, time.UTC),
+		UserID:     "test-user",
+		Status:     models.StatusScheduled,
+		Recurrence: "FREQ=WEEKLY;COUNT=6",
+	}
+	if w := createTestTask(t, router, series); w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for series over a stored block, got %d", w.Code)
+	}
+}
+
+func TestRecurringTaskExpandsInItsTimezone(t *testing.T) {
+	queries := setupTestDB(t)
+	router := NewAPIRouter(queries)
+
+	sydney, err := time.LoadLocation("Australia/Sydney")
+	if err != nil {
+		t.Skipf("zone database unavailable: %v", err)
+	}
+
+	// Monday 08:00 in Sydney is Sunday 21:00 UTC
+	start := time.Date(2026, 2, 2, 8, 0, 0, 0, sydney)
+	review := models.Task{
+		ID:         "review",
+		Title:      "Review",
+		Start:      start,
+		End:        start.Add(time.Hour),
+		UserID:     "test-user",
+		Status:     models.StatusScheduled,
+		Recurrence: "FREQ=WEEKLY;BYDAY=MO,WE",
+		Timezone:   "Australia/Sydney",
+	}
+	if w := createTestTask(t, router, review); w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	tasks := listTestTasks(t, router, "?from=2026-02-08&to=2026-02-15")
+	if len(tasks) != 2 {
+		t.Fatalf("Expected 2 occurrences, got %d", len(tasks))
+	}
+	for i, want := range []time.Time{time.Date(2026, 2, 9, 8, 0, 0, 0, sydney), time.Date(2026, 2, 11, 8, 0, 0, 0, sydney)} {
+		if !tasks[i].Start.Equal(want) || tasks[i].Timezone != "Australia/Sydney" {
+			t.Errorf("Expected occurrence at %v Sydney time, got %+v", want, tasks[i])
+		}
+	}
+
+	// occurrences are addressed by their zoned start
+	req := httptest.NewRequest(http.MethodDelete, "/api/tasks/review/occurrences/2026-02-08T21:00:00Z", nil)
+	signIn(req, "test-user")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204 cancelling the Sydney Monday, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	// the exported series carries its zone, so calendar clients expand it the same way
+	req = httptest.NewRequest(http.MethodGet, "/api/tasks/export.ics", nil)
+	signIn(req, "test-user")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	for _, want := range []string{
+		"DTSTART;TZID=Australia/Sydney:20260202T080000\r\n",
+		"EXDATE;TZID=Australia/Sydney:20260209T080000\r\n",
+	} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("Export missing %q:\n%s", want, w.Body.String())
+		}
+	}
+
+	review.ID, review.Timezone = "bad-zone", "Mars/Olympus"
+	if w := createTestTask(t, router, review); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown timezone, got %d", w.Code)
+	}
+}
+
+func TestEditAndCancelOccurrence(t *testing.T) {
+	queries := setupTestDB(t)
+	router := NewAPIRouter(queries)
+
+	standup := models.Task{
+		ID:         "standup",
+		Title:      "Standup",
+		Start:      time.Date(2026, 2, 9, 9, 0, 0, 0, time.UTC),
+		End:        time.Date(2026, 2, 9, 9, 15, 0, 0, time.UTC),
+		UserID:     "test-user",
+		Status:     models.StatusScheduled,
+		Recurrence: "FREQ=DAILY;COUNT=3",
+	}
+	if w := createTestTask(t, router, standup); w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	// move Tuesday's standup to the afternoon
+	body, _ := json.Marshal(map[string]any{
+		"title": "Late Standup",
+		"start": "2026-02-10T15:00:00Z",
+		"end":   "2026-02-10T15:15:00Z",
+	})
+	req := httptest.NewRequest(http.MethodPut, "/api/tasks/standup/occurrences/2026-02-10T09:00:00Z", bytes.NewReader(body))
+	signIn(req, "test-user")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 editing occurrence, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	// drop Wednesday's standup
+	req = httptest.NewRequest(http.MethodDelete, "/api/tasks/standup/occurrences/2026-02-11T09:00:00Z", nil)
+	signIn(req, "test-user")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204 cancelling occurrence, got %d", w.Code)
+	}
+
+	// both edits are writes to the series and land in its history
+	series, err := queries.GetTask(t.Context(), "standup")
+	if err != nil {
+		t.Fatalf("GetTask failed: %v", err)
+	}
+	revisions, err := queries.ListRevisions(t.Context(), "standup")
+	if err != nil {
+		t.Fatalf("ListRevisions failed: %v", err)
+	}
+	if series.Version != 3 || len(revisions) != 3 {
+		t.Errorf("Expected version 3 and 3 revisions after two occurrence edits, got %d and %d", series.Version, len(revisions))
+	}
+
+	tasks := listTestTasks(t, router, "?from=2026-02-09&to=2026-02-12")
+	if len(tasks) != 2 {
+		t.Fatalf("Expected 2 occurrences, got %d", len(tasks))
+	}
+	if tasks[1].Title != "Late Standup" || tasks[1].Start.Hour() != 15 {
+		t.Errorf("Expected moved occurrence, got %+v", tasks[1])
+	}
+
+	// a time that is not an occurrence of the series
+	req = httptest.NewRequest(http.MethodDelete, "/api/tasks/standup/occurrences/2026-02-10T10:00:00Z", nil)
+	signIn(req, "test-user")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for unknown occurrence, got %d", w.Code)
+	}
+
+	// another user's series is hidden
+	req = httptest.NewRequest(http.MethodDelete, "/api/tasks/standup/occurrences/2026-02-09T09:00:00Z", nil)
+	signIn(req, "other-user")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for another user's series, got %d", w.Code)
+	}
+}
+
+func TestCreateTaskInvalidRecurrence(t *testing.T) {
+	queries := setupTestDB(t)
+	router := NewAPIRouter(queries)
+
+	task := models.Task{
+		ID:         "bad-rule",
+		Title:      "Bad Rule",
+		Start:      time.Date(2026, 2, 9, 9, 0, 0, 0, time.UTC),
+		End:        time.Date(2026, 2, 9, 10, 0, 0, 0, time.UTC),
+		UserID:     "test-user",
+		Status:     models.StatusScheduled,
+		Recurrence: "FREQ=HOURLY",
+	}
+	if w := createTestTask(t, router, task); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for unsupported rule, got %d", w.Code)
+	}
+}
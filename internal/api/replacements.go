@@ -0,0 +1,77 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Adjanour/vesper/internal/events"
+	"github.com/Adjanour/vesper/internal/models"
+)
+
+// replaceTask swaps a scheduled task for a successor in one step: the old
+// task is kept, marked replaced and linked to the new one
+func (ar *APIRouter) replaceTask(w http.ResponseWriter, r *http.Request) {
+	old, ok := ar.ownedTask(w, r)
+	if !ok {
+		return
+	}
+
+	var next models.Task
+	if err := json.NewDecoder(r.Body).Decode(&next); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidJSON, "invalid JSON")
+		return
+	}
+
+	if next.ID == "" {
+		next.ID = newUserID()
+	}
+	if next.ID == old.ID {
+		writeInvalidField(w, r, "id", "a replacement needs an id of its own")
+		return
+	}
+	next.UserID = userIDFromRequest(r)
+	if next.Status == "" {
+		next.Status = models.StatusScheduled
+	}
+	if err := validateTask(&next); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if next.Status != models.StatusScheduled {
+		writeInvalidField(w, r, "status", "a replacement must be scheduled")
+		return
+	}
+
+	replaced, err := ar.db.ReplaceTask(r.Context(), old.ID, next)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	created, err := ar.db.GetTask(r.Context(), next.ID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	ar.publish(r, events.TaskUpdated, replaced.ID, replaced)
+	ar.publish(r, events.TaskCreated, created.ID, created)
+	w.Header().Set("ETag", taskETag(created))
+	WriteJsonResponse(w, http.StatusCreated, created)
+}
+
+// getReplacementChain lists the task's predecessors and successors in order,
+// oldest first
+func (ar *APIRouter) getReplacementChain(w http.ResponseWriter, r *http.Request) {
+	task, ok := ar.ownedTask(w, r)
+	if !ok {
+		return
+	}
+
+	chain, err := ar.db.GetReplacementChain(r.Context(), task.ID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	WriteJsonResponse(w, http.StatusOK, map[string]any{"tasks": chain})
+}
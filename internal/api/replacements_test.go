@@ -0,0 +1,97 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Adjanour/vesper/internal/models"
+)
+
+func replaceTestTask(router http.Handler, id string, next models.Task) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks/"+id+"/replace", jsonBody(next))
+	signIn(req, "test-user")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestReplaceTask(t *testing.T) {
+	queries := setupTestDB(t)
+	router := NewAPIRouter(queries)
+
+	at := func(hour, minute int) time.Time { return time.Date(2026, 2, 8, hour, minute, 0, 0, time.UTC) }
+	for _, task := range []models.Task{
+		{ID: "draft", Title: "Draft", Start: at(9, 0), End: at(10, 0), UserID: "test-user", Status: models.StatusScheduled},
+		{ID: "lunch", Title: "Lunch", Start: at(12, 0), End: at(13, 0), UserID: "test-user", Status: models.StatusScheduled},
+	} {
+		if w := createTestTask(t, router, task); w.Code != http.StatusCreated {
+			t.Fatalf("Expected status 201, got %d. Body: %s", w.Code, w.Body.String())
+		}
+	}
+
+	// the successor may overlap the block it replaces
+	w := replaceTestTask(router, "draft", models.Task{ID: "review", Title: "Review", Start: at(9, 30), End: at(10, 30)})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	var review models.Task
+	if err := json.NewDecoder(w.Body).Decode(&review); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if review.Replaces != "draft" || review.Status != models.StatusScheduled {
+		t.Errorf("Expected a scheduled successor of draft, got %+v", review)
+	}
+	// the response is the stored task, version and all
+	if review.Version != 1 || w.Header().Get("ETag") != taskETag(&review) {
+		t.Errorf("Expected the stored successor with its ETag, got %+v and %q", review, w.Header().Get("ETag"))
+	}
+
+	draft, err := queries.GetTask(t.Context(), "draft")
+	if err != nil {
+		t.Fatalf("GetTask failed: %v", err)
+	}
+	if draft.Status != models.StatusReplaced || draft.ReplacedBy != "review" {
+		t.Errorf("Expected draft replaced by review, got %+v", draft)
+	}
+
+	// a task can only be replaced once
+	w = replaceTestTask(router, "draft", models.Task{ID: "again", Title: "Again", Start: at(15, 0), End: at(16, 0)})
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 replacing a replaced task, got %d", w.Code)
+	}
+
+	// a successor overlapping another block is refused and nothing changes
+	w = replaceTestTask(router, "review", models.Task{ID: "clash", Title: "Clash", Start: at(12, 30), End: at(13, 30)})
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for an overlapping successor, got %d", w.Code)
+	}
+	if review, _ := queries.GetTask(t.Context(), "review"); review.Status != models.StatusScheduled || review.ReplacedBy != "" {
+		t.Errorf("Expected review untouched after a refused replacement, got %+v", review)
+	}
+
+	if w := replaceTestTask(router, "review", models.Task{ID: "final", Title: "Final", Start: at(10, 0), End: at(11, 0)}); w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/tasks/review/replacements", nil)
+	signIn(req, "test-user")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	var response map[string][]*models.Task
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	var chain []string
+	for _, task := range response["tasks"] {
+		chain = append(chain, task.ID)
+	}
+	if len(chain) != 3 || chain[0] != "draft" || chain[1] != "review" || chain[2] != "final" {
+		t.Errorf("Expected chain draft, review, final; got %v", chain)
+	}
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Adjanour/vesper/internal/events"
+	"github.com/Adjanour/vesper/internal/models"
+	"github.com/go-chi/chi/v5"
+)
+
+// parseRevision reads a revision number, which starts at 1
+func parseRevision(v string) (int, bool) {
+	n, err := strconv.Atoi(v)
+	return n, err == nil && n > 0
+}
+
+// listRevisions returns the task's history, oldest first
+func (ar *APIRouter) listRevisions(w http.ResponseWriter, r *http.Request) {
+	task, ok := ar.ownedTask(w, r)
+	if !ok {
+		return
+	}
+
+	revisions, err := ar.db.ListRevisions(r.Context(), task.ID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if revisions == nil {
+		revisions = []*models.Revision{}
+	}
+	WriteJsonResponse(w, http.StatusOK, map[string]any{"revisions": revisions})
+}
+
+// diffRevisions lists the fields that changed between the from and to
+// revisions
+func (ar *APIRouter) diffRevisions(w http.ResponseWriter, r *http.Request) {
+	task, ok := ar.ownedTask(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	from, ok := parseRevision(query.Get("from"))
+	if !ok {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidParameter, "from must be a revision number")
+		return
+	}
+	to, ok := parseRevision(query.Get("to"))
+	if !ok {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidParameter, "to must be a revision number")
+		return
+	}
+
+	a, err := ar.db.GetRevision(r.Context(), task.ID, from)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	b, err := ar.db.GetRevision(r.Context(), task.ID, to)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	changes := models.DiffTasks(a.Task, b.Task)
+	if changes == nil {
+		changes = []models.FieldChange{}
+	}
+	WriteJsonResponse(w, http.StatusOK, map[string]any{"from": from, "to": to, "changes": changes})
+}
+
+// revertTask puts the task's title, times and recurrence back to an earlier
+// revision, provided they still fit the schedule
+func (ar *APIRouter) revertTask(w http.ResponseWriter, r *http.Request) {
+	task, ok := ar.ownedTask(w, r)
+	if !ok {
+		return
+	}
+
+	revision, ok := parseRevision(chi.URLParam(r, "revision"))
+	if !ok {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidParameter, "invalid revision")
+		return
+	}
+
+	reverted, err := ar.db.RevertTask(r.Context(), task.ID, revision)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	ar.publish(r, events.TaskUpdated, reverted.ID, reverted)
+	WriteJsonResponse(w, http.StatusOK, reverted)
+}
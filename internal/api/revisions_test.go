@@ -0,0 +1,132 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Adjanour/vesper/internal/models"
+)
+
+func TestTaskRevisions(t *testing.T) {
+	queries := setupTestDB(t)
+	router := NewAPIRouter(queries)
+
+	serve := func(method, target string, body any) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		if body != nil {
+			req = httptest.NewRequest(method, target, jsonBody(body))
+		}
+		signIn(req, "test-user")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	at := func(hour int) time.Time { return time.Date(2026, 2, 8, hour, 0, 0, 0, time.UTC) }
+	for _, task := range []models.Task{
+		{ID: "focus", Title: "Focus", Start: at(9), End: at(10), UserID: "test-user", Status: models.StatusScheduled},
+		{ID: "lunch", Title: "Lunch", Start: at(12), End: at(13), UserID: "test-user", Status: models.StatusScheduled},
+	} {
+		if w := createTestTask(t, router, task); w.Code != http.StatusCreated {
+			t.Fatalf("Expected status 201, got %d. Body: %s", w.Code, w.Body.String())
+		}
+	}
+
+	moved := models.Task{ID: "focus", Title: "Deep focus", Start: at(14), End: at(15), Status: models.StatusScheduled}
+	if w := serve(http.MethodPut, "/api/tasks/focus", moved); w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	w := serve(http.MethodGet, "/api/tasks/focus/revisions", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	var list struct {
+		Revisions []models.Revision `json:"revisions"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(list.Revisions) != 2 {
+		t.Fatalf("Expected 2 revisions, got %+v", list.Revisions)
+	}
+	first, second := list.Revisions[0], list.Revisions[1]
+	if first.Revision != 1 || first.Action != models.RevisionCreate || first.Task.Title != "Focus" {
+		t.Errorf("Expected revision 1 to record the creation, got %+v", first)
+	}
+	if second.Revision != 2 || second.Action != models.RevisionUpdate || !second.Task.Start.Equal(at(14)) {
+		t.Errorf("Expected revision 2 to record the move, got %+v", second)
+	}
+	if first.Actor != "user:test-user" {
+		t.Errorf("Expected the signed-in user as actor, got %q", first.Actor)
+	}
+
+	w = serve(http.MethodGet, "/api/tasks/focus/revisions/diff?from=1&to=2", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	var diff struct {
+		Changes []models.FieldChange `json:"changes"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&diff); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	fields := make([]string, 0, len(diff.Changes))
+	for _, c := range diff.Changes {
+		fields = append(fields, c.Field)
+	}
+	if len(fields) != 3 || fields[0] != "end" || fields[1] != "start" || fields[2] != "title" {
+		t.Errorf("Expected end, start and title to differ, got %+v", diff.Changes)
+	}
+
+	if w := serve(http.MethodGet, "/api/tasks/focus/revisions/diff?from=1", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 without to, got %d", w.Code)
+	}
+	if w := serve(http.MethodGet, "/api/tasks/focus/revisions/diff?from=1&to=9", nil); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a missing revision, got %d", w.Code)
+	}
+
+	// reverting is refused while another block holds the old slot
+	blocker := models.Task{ID: "standup", Title: "Standup", Start: at(9), End: at(10), UserID: "test-user", Status: models.StatusScheduled}
+	if w := createTestTask(t, router, blocker); w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	if w := serve(http.MethodPost, "/api/tasks/focus/revisions/1/revert", nil); w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 reverting into an occupied slot, got %d", w.Code)
+	}
+	if w := serve(http.MethodDelete, "/api/tasks/standup", nil); w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d", w.Code)
+	}
+
+	w = serve(http.MethodPost, "/api/tasks/focus/revisions/1/revert", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	var reverted models.Task
+	if err := json.NewDecoder(w.Body).Decode(&reverted); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if reverted.Title != "Focus" || !reverted.Start.Equal(at(9)) || !reverted.End.Equal(at(10)) {
+		t.Errorf("Expected focus back at 09:00, got %+v", reverted)
+	}
+
+	revisions, err := queries.ListRevisions(t.Context(), "focus")
+	if err != nil {
+		t.Fatalf("ListRevisions failed: %v", err)
+	}
+	if last := revisions[len(revisions)-1]; len(revisions) != 3 || last.Action != models.RevisionRevert {
+		t.Errorf("Expected a third revision recording the revert, got %+v", revisions)
+	}
+
+	// other users see neither the history nor the task
+	req := httptest.NewRequest(http.MethodGet, "/api/tasks/focus/revisions", nil)
+	signIn(req, "other-user")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for another user's task, got %d", w.Code)
+	}
+}
@@ -2,23 +2,98 @@ package api
 
 import (
 	"encoding/json"
+	"log/slog"
 	"net/http"
 
+	"github.com/Adjanour/vesper/internal/config"
 	"github.com/Adjanour/vesper/internal/database"
+	"github.com/Adjanour/vesper/internal/events"
+	"github.com/Adjanour/vesper/internal/models"
+	"github.com/Adjanour/vesper/internal/planning"
+	"github.com/Adjanour/vesper/internal/webhooks"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/cors"
 )
 
 type APIRouter struct {
-	router *chi.Mux
-	db     *database.Queries
+	router   *chi.Mux
+	db       *database.Queries
+	cors     config.CORS
+	signer   *planning.Signer
+	events   *events.Broker
+	webhooks *webhooks.Dispatcher
+	accounts AccountNotifier
+	logger   *slog.Logger
+	// requireIfMatch refuses task writes that do not say which version they change
+	requireIfMatch bool
 }
 
-func NewAPIRouter(q *database.Queries) *chi.Mux {
+// Option configures optional parts of the API router
+type Option func(*APIRouter)
+
+// WithCORS sets which browser origins may call the API
+func WithCORS(c config.CORS) Option {
+	return func(ar *APIRouter) {
+		ar.cors = c
+	}
+}
+
+// WithBroker publishes task changes to b instead of a broker private to the router
+func WithBroker(b *events.Broker) Option {
+	return func(ar *APIRouter) {
+		ar.events = b
+	}
+}
+
+// WithWebhooks queues task events for delivery to users' webhooks
+func WithWebhooks(d *webhooks.Dispatcher) Option {
+	return func(ar *APIRouter) {
+		ar.webhooks = d
+	}
+}
+
+// WithAccountNotifier tells users about changes to their account, such as a new password
+func WithAccountNotifier(n AccountNotifier) Option {
+	return func(ar *APIRouter) {
+		ar.accounts = n
+	}
+}
+
+// WithLogger writes access log lines to l instead of the default logger
+func WithLogger(l *slog.Logger) Option {
+	return func(ar *APIRouter) {
+		ar.logger = l
+	}
+}
+
+// WithRequireIfMatch makes PUT, PATCH and DELETE on tasks answer 428 Precondition
+// Required unless they carry an If-Match header
+func WithRequireIfMatch(required bool) Option {
+	return func(ar *APIRouter) {
+		ar.requireIfMatch = required
+	}
+}
+
+// WithLinkSigner enables opening planning sessions from signed links
+func WithLinkSigner(s *planning.Signer) Option {
+	return func(ar *APIRouter) {
+		ar.signer = s
+	}
+}
+
+func NewAPIRouter(q *database.Queries, opts ...Option) *chi.Mux {
 	api := &APIRouter{
 		router: chi.NewRouter(),
 		db:     q,
+		cors:   config.Default().CORS,
+		logger: slog.Default(),
+	}
+	for _, opt := range opts {
+		opt(api)
+	}
+	if api.events == nil {
+		api.events = events.NewBroker(events.DefaultHistory)
 	}
 	return api.Routes()
 }
@@ -38,25 +113,100 @@ func WriteJsonResponse(w http.ResponseWriter, status int, data any) {
 }
 
 func (ar *APIRouter) Routes() *chi.Mux {
+	// tracing first, so the access log line carries the trace ID
+	ar.router.Use(traceRequests)
+	ar.router.Use(ar.logRequests)
 	ar.router.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
-		AllowCredentials: true,
-		MaxAge:           300,
+		AllowedOrigins:   ar.cors.AllowedOrigins,
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "If-Match", "If-None-Match", requestIDHeader, "traceparent", "tracestate"},
+		ExposedHeaders:   []string{"ETag", "Link", requestIDHeader},
+		AllowCredentials: ar.cors.AllowCredentials,
+		MaxAge:           int(ar.cors.MaxAge.Seconds()),
 	}))
 
+	ar.router.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		writeProblem(w, r, http.StatusNotFound, codeNotFound, "no such endpoint")
+	})
+	ar.router.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		writeProblem(w, r, http.StatusMethodNotAllowed, codeMethodNotAllowed, r.Method+" is not allowed here")
+	})
+
 	ar.router.Route("/api", func(r chi.Router) {
 		r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
 			WriteJsonResponse(w, http.StatusOK, map[string]string{"status": "ok"})
 		})
-		r.Route("/tasks", func(r chi.Router) {
-			r.Get("/", ar.GetTasks)
-			r.Post("/", ar.createTask)
-			r.Get("/{id}", ar.getTask)
-			r.Put("/{id}", ar.updateTask)
-			r.Delete("/{id}", ar.deleteTask)
+		r.Route("/auth", func(r chi.Router) {
+			r.Post("/register", ar.register)
+			r.Post("/login", ar.login)
+			r.Post("/logout", ar.logout)
+		})
+		// planning links carry their own signature instead of a session
+		r.Get("/planning/sessions/{id}", ar.getPlanningSession)
+
+		r.Group(func(r chi.Router) {
+			r.Use(ar.requireUser)
+
+			r.Route("/tasks", func(r chi.Router) {
+				r.Group(func(r chi.Router) {
+					r.Use(requireScope(models.ScopeTasksRead))
+					r.Get("/", ar.GetTasks)
+					r.Get("/export.ics", ar.exportCalendar)
+					r.Get("/{id}", ar.getTask)
+					r.Get("/{id}/replacements", ar.getReplacementChain)
+					r.Get("/{id}/revisions", ar.listRevisions)
+					r.Get("/{id}/revisions/diff", ar.diffRevisions)
+				})
+				r.Group(func(r chi.Router) {
+					r.Use(requireScope(models.ScopeTasksWrite))
+					r.Post("/", ar.createTask)
+					r.Post("/import", ar.importCalendar)
+					r.Put("/{id}", ar.updateTask)
+					r.Patch("/{id}", ar.patchTask)
+					r.Delete("/{id}", ar.deleteTask)
+					r.Post("/{id}/replace", ar.replaceTask)
+					r.Post("/{id}/revisions/{revision}/revert", ar.revertTask)
+					r.Put("/{id}/occurrences/{recurrenceID}", ar.updateOccurrence)
+					r.Delete("/{id}/occurrences/{recurrenceID}", ar.cancelOccurrence)
+				})
+			})
+			r.Route("/trash", func(r chi.Router) {
+				r.With(requireScope(models.ScopeTasksRead)).Get("/", ar.listTrash)
+				r.Group(func(r chi.Router) {
+					r.Use(requireScope(models.ScopeTasksWrite))
+					r.Post("/{id}/restore", ar.restoreTask)
+					r.Delete("/{id}", ar.purgeTask)
+				})
+			})
+			r.With(requireScope(models.ScopeTasksRead)).Get("/events", ar.streamEvents)
+			r.Route("/me", func(r chi.Router) {
+				r.With(requireScope(models.ScopeAccountRead)).Get("/", ar.getAccount)
+				r.With(requireSession).Put("/email", ar.updateAccountEmail)
+				r.With(requireSession).Put("/password", ar.changePassword)
+			})
+			r.Route("/tokens", func(r chi.Router) {
+				r.Use(requireSession)
+				r.Get("/", ar.listTokens)
+				r.Post("/", ar.createToken)
+				r.Delete("/{id}", ar.revokeToken)
+			})
+			r.Route("/webhooks", func(r chi.Router) {
+				r.Group(func(r chi.Router) {
+					r.Use(requireScope(models.ScopeWebhooksRead))
+					r.Get("/", ar.listWebhooks)
+					r.Get("/{id}", ar.getWebhook)
+					r.Get("/{id}/deliveries", ar.listWebhookDeliveries)
+				})
+				r.Group(func(r chi.Router) {
+					r.Use(requireScope(models.ScopeWebhooksWrite))
+					r.Post("/", ar.createWebhook)
+					r.Delete("/{id}", ar.deleteWebhook)
+				})
+			})
+			r.Route("/planning/schedule", func(r chi.Router) {
+				r.With(requireScope(models.ScopePlanningRead)).Get("/", ar.getPlanningSchedule)
+				r.With(requireScope(models.ScopePlanningWrite)).Put("/", ar.updatePlanningSchedule)
+			})
 		})
 	})
 
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Adjanour/vesper/internal/auth"
+	"github.com/Adjanour/vesper/internal/database"
+	"github.com/Adjanour/vesper/internal/models"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	// tokenPrefix marks Vesper tokens so secret scanners can recognise leaked ones
+	tokenPrefix        = "vsp_"
+	defaultTokenTTL    = 90 * 24 * time.Hour
+	maxTokenNameLength = 100
+)
+
+// tokenRequest is the body for creating a personal API token
+type tokenRequest struct {
+	Name   string         `json:"name"`
+	Scopes []models.Scope `json:"scopes"`
+	// ExpiresAt defaults to 90 days from now.
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// createdToken is the only response that ever contains a token's secret
+type createdToken struct {
+	*models.APIToken
+	Token string `json:"token"`
+}
+
+func (ar *APIRouter) createToken(w http.ResponseWriter, r *http.Request) {
+	var req tokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidJSON, "invalid JSON")
+		return
+	}
+
+	now := time.Now().UTC()
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > maxTokenNameLength {
+		writeInvalidField(w, r, "name", "name must be 1 to 100 characters")
+		return
+	}
+	if len(req.Scopes) == 0 {
+		writeInvalidField(w, r, "scopes", "at least one scope is required")
+		return
+	}
+	for _, s := range req.Scopes {
+		if !models.IsValidScope(s) {
+			writeInvalidField(w, r, "scopes", fmt.Sprintf("invalid scope %q", s))
+			return
+		}
+	}
+	if req.ExpiresAt == nil {
+		expires := now.Add(defaultTokenTTL)
+		req.ExpiresAt = &expires
+	}
+	if !req.ExpiresAt.After(now) {
+		writeInvalidField(w, r, "expires_at", "expires_at must be in the future")
+		return
+	}
+
+	secret, _, err := auth.NewToken()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	secret = tokenPrefix + secret
+
+	expires := req.ExpiresAt.UTC()
+	token := &models.APIToken{
+		ID:        newUserID(),
+		UserID:    userIDFromRequest(r),
+		Name:      req.Name,
+		Scopes:    req.Scopes,
+		CreatedAt: now,
+		ExpiresAt: &expires,
+	}
+	if err := ar.db.CreateAPIToken(r.Context(), *token, auth.HashToken(secret)); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	WriteJsonResponse(w, http.StatusCreated, createdToken{APIToken: token, Token: secret})
+}
+
+func (ar *APIRouter) listTokens(w http.ResponseWriter, r *http.Request) {
+	tokens, err := ar.db.ListAPITokens(r.Context(), userIDFromRequest(r))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	WriteJsonResponse(w, http.StatusOK, map[string]any{"tokens": tokens})
+}
+
+func (ar *APIRouter) revokeToken(w http.ResponseWriter, r *http.Request) {
+	err := ar.db.DeleteAPIToken(r.Context(), chi.URLParam(r, "id"), userIDFromRequest(r))
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			writeProblem(w, r, http.StatusNotFound, codeNotFound, "token not found")
+			return
+		}
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Adjanour/vesper/internal/auth"
+	"github.com/Adjanour/vesper/internal/models"
+)
+
+func createTestToken(t *testing.T, router http.Handler, body map[string]any) createdToken {
+	t.Helper()
+	payload, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPost, "/api/tokens/", bytes.NewReader(payload))
+	signIn(req, "test-user")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201 creating token, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	var token createdToken
+	if err := json.NewDecoder(w.Body).Decode(&token); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return token
+}
+
+func bearerRequest(router http.Handler, method, path, token string, body any) *httptest.ResponseRecorder {
+	var payload []byte
+	if body != nil {
+		payload, _ = json.Marshal(body)
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(payload))
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestAPITokenScopes(t *testing.T) {
+	queries := setupTestDB(t)
+	router := NewAPIRouter(queries)
+
+	token := createTestToken(t, router, map[string]any{"name": "cron", "scopes": []string{"tasks:read"}})
+	if !strings.HasPrefix(token.Token, tokenPrefix) {
+		t.Errorf("Expected token to start with %q, got %q", tokenPrefix, token.Token)
+	}
+	if token.ExpiresAt == nil || token.ExpiresAt.Before(time.Now().Add(89*24*time.Hour)) {
+		t.Errorf("Expected a default expiry about 90 days out, got %v", token.ExpiresAt)
+	}
+
+	if w := bearerRequest(router, http.MethodGet, "/api/tasks/", token.Token, nil); w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 reading with tasks:read, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	task := models.Task{
+		ID:     "token-task-001",
+		Title:  "From a script",
+		Start:  time.Date(2026, 2, 8, 9, 0, 0, 0, time.UTC),
+		End:    time.Date(2026, 2, 8, 10, 0, 0, 0, time.UTC),
+		Status: models.StatusScheduled,
+	}
+	w := bearerRequest(router, http.MethodPost, "/api/tasks/", token.Token, task)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 writing without tasks:write, got %d", w.Code)
+	}
+	if got := w.Header().Get("WWW-Authenticate"); !strings.Contains(got, "insufficient_scope") {
+		t.Errorf("Expected insufficient_scope challenge, got %q", got)
+	}
+
+	writer := createTestToken(t, router, map[string]any{"name": "sync", "scopes": []string{"tasks:read", "tasks:write"}})
+	if w := bearerRequest(router, http.MethodPost, "/api/tasks/", writer.Token, task); w.Code != http.StatusCreated {
+		t.Errorf("Expected status 201 writing with tasks:write, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	// tokens cannot manage tokens
+	if w := bearerRequest(router, http.MethodGet, "/api/tokens/", writer.Token, nil); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 listing tokens with a token, got %d", w.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/tokens/", nil)
+	signIn(req, "test-user")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var listed map[string][]*models.APIToken
+	if err := json.NewDecoder(w.Body).Decode(&listed); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(listed["tokens"]) != 2 {
+		t.Fatalf("Expected 2 tokens, got %d", len(listed["tokens"]))
+	}
+	if listed["tokens"][0].LastUsedAt == nil {
+		t.Error("Expected last_used_at to be recorded")
+	}
+	if strings.Contains(w.Body.String(), token.Token) {
+		t.Error("Expected listings never to include token secrets")
+	}
+}
+
+func TestAPITokenRevokeAndExpiry(t *testing.T) {
+	queries := setupTestDB(t)
+	router := NewAPIRouter(queries)
+
+	token := createTestToken(t, router, map[string]any{"name": "cron", "scopes": []string{"tasks:read"}})
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/tokens/"+token.ID, nil)
+	signIn(req, "other-user")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 revoking another user's token, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/tokens/"+token.ID, nil)
+	signIn(req, "test-user")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204 revoking token, got %d", w.Code)
+	}
+
+	w = bearerRequest(router, http.MethodGet, "/api/tasks/", token.Token, nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 for a revoked token, got %d", w.Code)
+	}
+	if got := w.Header().Get("WWW-Authenticate"); !strings.Contains(got, "invalid_token") {
+		t.Errorf("Expected invalid_token challenge, got %q", got)
+	}
+
+	// the API refuses past expiries, so store an already-expired token directly
+	past := time.Now().Add(-time.Minute)
+	expired := models.APIToken{ID: "expired", UserID: "test-user", Name: "old", Scopes: []models.Scope{models.ScopeTasksRead}, CreatedAt: past, ExpiresAt: &past}
+	if err := queries.CreateAPIToken(req.Context(), expired, auth.HashToken("vsp_expired")); err != nil {
+		t.Fatalf("CreateAPIToken failed: %v", err)
+	}
+	if w := bearerRequest(router, http.MethodGet, "/api/tasks/", "vsp_expired", nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 for an expired token, got %d", w.Code)
+	}
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestRequestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+	defer otel.SetTextMapPropagator(otel.GetTextMapPropagator())
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	router := NewAPIRouter(setupTestDB(t))
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks/", jsonBody(newTestTask("traced")))
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	signIn(req, "test-user")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/tasks/traced", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	signIn(req, "test-user")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	byName := make(map[string]sdktrace.ReadOnlySpan)
+	for _, s := range spans {
+		if s.SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Errorf("Span %s did not continue the incoming trace", s.Name())
+		}
+		byName[s.Name()] = s
+	}
+
+	server, ok := byName["POST /api/tasks"]
+	if !ok {
+		t.Fatalf("Expected a span named after the route, got %v", spanNames(spans))
+	}
+	if server.Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("Expected the server span to be a child of the incoming span, got %s", server.Parent().SpanID())
+	}
+	// path parameters stay out of the name
+	if _, ok := byName["GET /api/tasks/{id}"]; !ok {
+		t.Errorf("Expected a span named after the route pattern, got %v", spanNames(spans))
+	}
+
+	// the overlap check and the insert are told apart
+	for name, summary := range map[string]string{"scheduledOccurrences": "SELECT tasks", "CreateTask": "INSERT tasks"} {
+		s, ok := byName[name]
+		if !ok {
+			t.Errorf("Expected a %s span, got %v", name, spanNames(spans))
+			continue
+		}
+		if s.Parent().SpanID() != server.SpanContext().SpanID() {
+			t.Errorf("Expected %s to be a child of the request span", name)
+		}
+		var got string
+		for _, attr := range s.Attributes() {
+			if attr.Key == semconv.DBQuerySummaryKey {
+				got = attr.Value.AsString()
+			}
+		}
+		if got != summary {
+			t.Errorf("Expected %s to be summarized as %q, got %q", name, summary, got)
+		}
+	}
+}
+
+func spanNames(spans []sdktrace.ReadOnlySpan) []string {
+	var names []string
+	for _, s := range spans {
+		names = append(names, s.Name())
+	}
+	return names
+}
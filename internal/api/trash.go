@@ -0,0 +1,48 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/Adjanour/vesper/internal/events"
+)
+
+// listTrash returns the caller's deleted tasks, most recently deleted first
+func (ar *APIRouter) listTrash(w http.ResponseWriter, r *http.Request) {
+	tasks, err := ar.db.ListTrash(r.Context(), userIDFromRequest(r))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	WriteJsonResponse(w, http.StatusOK, map[string]any{"tasks": tasks})
+}
+
+// restoreTask takes a task out of the trash, provided it fits the schedule
+func (ar *APIRouter) restoreTask(w http.ResponseWriter, r *http.Request) {
+	task, ok := ar.trashedTask(w, r)
+	if !ok {
+		return
+	}
+
+	restored, err := ar.db.RestoreTask(r.Context(), task.ID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	ar.publish(r, events.TaskCreated, restored.ID, restored)
+	WriteJsonResponse(w, http.StatusOK, restored)
+}
+
+// purgeTask permanently deletes a task from the trash
+func (ar *APIRouter) purgeTask(w http.ResponseWriter, r *http.Request) {
+	task, ok := ar.trashedTask(w, r)
+	if !ok {
+		return
+	}
+
+	if err := ar.db.PurgeTask(r.Context(), task.ID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
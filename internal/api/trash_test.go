@@ -0,0 +1,103 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Adjanour/vesper/internal/models"
+)
+
+func trashRequest(router http.Handler, method, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	signIn(req, "test-user")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func listTestTrash(t *testing.T, router http.Handler) []*models.Task {
+	t.Helper()
+	w := trashRequest(router, http.MethodGet, "/api/trash")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 listing the trash, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	var response map[string][]*models.Task
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return response["tasks"]
+}
+
+func TestTrashRestoreAndPurge(t *testing.T) {
+	queries := setupTestDB(t)
+	router := NewAPIRouter(queries)
+
+	block := models.Task{
+		ID:     "trash-001",
+		Title:  "Focus",
+		Start:  time.Date(2026, 2, 8, 9, 0, 0, 0, time.UTC),
+		End:    time.Date(2026, 2, 8, 10, 0, 0, 0, time.UTC),
+		UserID: "test-user",
+		Status: models.StatusScheduled,
+	}
+	if w := createTestTask(t, router, block); w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	if w := trashRequest(router, http.MethodDelete, "/api/tasks/trash-001"); w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d", w.Code)
+	}
+
+	trash := listTestTrash(t, router)
+	if len(trash) != 1 || trash[0].ID != "trash-001" || trash[0].Status != models.StatusDeleted || trash[0].DeletedAt == nil {
+		t.Fatalf("Expected the deleted task in the trash, got %+v", trash)
+	}
+
+	// its slot is free while it is in the trash, so restoring it now clashes
+	clash := block
+	clash.ID = "trash-002"
+	if w := createTestTask(t, router, clash); w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201 reusing a deleted slot, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	if w := trashRequest(router, http.MethodPost, "/api/trash/trash-001/restore"); w.Code != http.StatusConflict {
+		t.Fatalf("Expected status 409 restoring into an occupied slot, got %d", w.Code)
+	}
+
+	if w := trashRequest(router, http.MethodDelete, "/api/tasks/trash-002"); w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d", w.Code)
+	}
+	w := trashRequest(router, http.MethodPost, "/api/trash/trash-001/restore")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 restoring, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	var restored models.Task
+	if err := json.NewDecoder(w.Body).Decode(&restored); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if restored.Status != models.StatusScheduled || restored.DeletedAt != nil {
+		t.Errorf("Expected a scheduled task after restoring, got %+v", restored)
+	}
+	if w := trashRequest(router, http.MethodGet, "/api/tasks/trash-001"); w.Code != http.StatusOK {
+		t.Errorf("Expected the restored task to be readable, got %d", w.Code)
+	}
+
+	// only tasks in the trash can be restored or purged
+	if w := trashRequest(router, http.MethodPost, "/api/trash/trash-001/restore"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 restoring a scheduled task, got %d", w.Code)
+	}
+	if w := trashRequest(router, http.MethodDelete, "/api/trash/trash-001"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 purging a scheduled task, got %d", w.Code)
+	}
+
+	if w := trashRequest(router, http.MethodDelete, "/api/trash/trash-002"); w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204 purging, got %d", w.Code)
+	}
+	if _, err := queries.GetTask(t.Context(), "trash-002"); err == nil {
+		t.Error("Expected the purged task to be gone")
+	}
+	if trash := listTestTrash(t, router); len(trash) != 0 {
+		t.Errorf("Expected an empty trash, got %+v", trash)
+	}
+}
@@ -0,0 +1,164 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/Adjanour/vesper/internal/auth"
+	"github.com/Adjanour/vesper/internal/database"
+	"github.com/Adjanour/vesper/internal/events"
+	"github.com/Adjanour/vesper/internal/models"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	// webhookSecretPrefix marks generated signing secrets
+	webhookSecretPrefix  = "whsec_"
+	minWebhookSecret     = 16
+	maxWebhookURLLength  = 2048
+	webhookDeliveryLimit = 50
+)
+
+// webhookRequest is the body for creating a webhook
+type webhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	// Secret is generated when omitted.
+	Secret string `json:"secret"`
+}
+
+// createdWebhook is the only response that ever contains a webhook's secret
+type createdWebhook struct {
+	*models.Webhook
+	Secret string `json:"secret"`
+}
+
+func (ar *APIRouter) createWebhook(w http.ResponseWriter, r *http.Request) {
+	var req webhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidJSON, "invalid JSON")
+		return
+	}
+
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || len(req.URL) > maxWebhookURLLength {
+		writeInvalidField(w, r, "url", "url must be an absolute http or https URL")
+		return
+	}
+	if ar.webhooks != nil {
+		if err := ar.webhooks.CheckURL(u); err != nil {
+			writeInvalidField(w, r, "url", "url must not point at a loopback, private or link-local address")
+			return
+		}
+	}
+	if len(req.Events) == 0 {
+		writeInvalidField(w, r, "events", "at least one event type is required")
+		return
+	}
+	for _, e := range req.Events {
+		if !slices.Contains(events.Types, events.Type(e)) {
+			writeInvalidField(w, r, "events", fmt.Sprintf("invalid event type %q", e))
+			return
+		}
+	}
+	if req.Secret == "" {
+		secret, _, err := auth.NewToken()
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		req.Secret = webhookSecretPrefix + secret
+	} else if len(req.Secret) < minWebhookSecret {
+		writeInvalidField(w, r, "secret", "secret must be at least 16 characters")
+		return
+	}
+
+	slices.Sort(req.Events)
+	hook := &models.Webhook{
+		ID:        newUserID(),
+		UserID:    userIDFromRequest(r),
+		URL:       req.URL,
+		Events:    slices.Compact(req.Events),
+		Secret:    req.Secret,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := ar.db.CreateWebhook(r.Context(), *hook); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	WriteJsonResponse(w, http.StatusCreated, createdWebhook{Webhook: hook, Secret: hook.Secret})
+}
+
+func (ar *APIRouter) listWebhooks(w http.ResponseWriter, r *http.Request) {
+	hooks, err := ar.db.ListWebhooks(r.Context(), userIDFromRequest(r))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	WriteJsonResponse(w, http.StatusOK, map[string]any{"webhooks": hooks})
+}
+
+// ownedWebhook loads the webhook named in the URL, answering 404 when it
+// belongs to someone else
+func (ar *APIRouter) ownedWebhook(w http.ResponseWriter, r *http.Request) (*models.Webhook, bool) {
+	hook, err := ar.db.GetWebhook(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			writeProblem(w, r, http.StatusNotFound, codeNotFound, "webhook not found")
+			return nil, false
+		}
+		writeError(w, r, err)
+		return nil, false
+	}
+	if hook.UserID != userIDFromRequest(r) {
+		writeProblem(w, r, http.StatusNotFound, codeNotFound, "webhook not found")
+		return nil, false
+	}
+	return hook, true
+}
+
+func (ar *APIRouter) getWebhook(w http.ResponseWriter, r *http.Request) {
+	hook, ok := ar.ownedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	WriteJsonResponse(w, http.StatusOK, hook)
+}
+
+func (ar *APIRouter) deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	err := ar.db.DeleteWebhook(r.Context(), chi.URLParam(r, "id"), userIDFromRequest(r))
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			writeProblem(w, r, http.StatusNotFound, codeNotFound, "webhook not found")
+			return
+		}
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listWebhookDeliveries returns the webhook's delivery log, newest first
+func (ar *APIRouter) listWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	hook, ok := ar.ownedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	deliveries, err := ar.db.ListWebhookDeliveries(r.Context(), hook.ID, webhookDeliveryLimit)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	WriteJsonResponse(w, http.StatusOK, map[string]any{"deliveries": deliveries})
+}
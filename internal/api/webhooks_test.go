@@ -0,0 +1,94 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Adjanour/vesper/internal/webhooks"
+)
+
+func TestWebhookLifecycle(t *testing.T) {
+	queries := setupTestDB(t)
+	router := NewAPIRouter(queries, WithWebhooks(webhooks.NewDispatcher(queries, time.Minute)))
+	cookie := &http.Cookie{Name: sessionCookieName, Value: testSessionToken("test-user")}
+
+	w := postJSON(router, "/api/webhooks/", map[string]any{"url": "ftp://example.com", "events": []string{"task.created"}}, cookie)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a non-HTTP URL, got %d", w.Code)
+	}
+	for _, internal := range []string{"http://127.0.0.1:8080/hook", "http://169.254.169.254/latest/meta-data", "http://localhost/hook"} {
+		w = postJSON(router, "/api/webhooks/", map[string]any{"url": internal, "events": []string{"task.created"}}, cookie)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", internal, w.Code)
+		}
+	}
+	w = postJSON(router, "/api/webhooks/", map[string]any{"url": "https://example.com/hook", "events": []string{"task.exploded"}}, cookie)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown event type, got %d", w.Code)
+	}
+
+	w = postJSON(router, "/api/webhooks/", map[string]any{"url": "https://example.com/hook", "events": []string{"task.created", "task.deleted"}}, cookie)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	var hook createdWebhook
+	if err := json.NewDecoder(w.Body).Decode(&hook); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !strings.HasPrefix(hook.Secret, webhookSecretPrefix) {
+		t.Errorf("Expected a generated secret, got %q", hook.Secret)
+	}
+
+	// creating a task queues a delivery; updating one is not subscribed
+	if w := postJSON(router, "/api/tasks/", newTestTask("hooked"), cookie); w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201 creating task, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	req := httptest.NewRequest(http.MethodPut, "/api/tasks/hooked", jsonBody(newTestTask("hooked")))
+	signIn(req, "test-user")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/webhooks/"+hook.ID+"/deliveries", nil)
+	signIn(req, "test-user")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var result struct {
+		Deliveries []struct {
+			EventType string `json:"event_type"`
+			Status    string `json:"status"`
+		} `json:"deliveries"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode deliveries: %v", err)
+	}
+	if len(result.Deliveries) != 1 || result.Deliveries[0].EventType != "task.created" || result.Deliveries[0].Status != "pending" {
+		t.Errorf("Expected one pending task.created delivery, got %+v", result.Deliveries)
+	}
+
+	// listings never reveal the secret, and other users cannot see the webhook
+	req = httptest.NewRequest(http.MethodGet, "/api/webhooks/", nil)
+	signIn(req, "test-user")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if strings.Contains(w.Body.String(), hook.Secret) {
+		t.Error("Webhook listing exposed the secret")
+	}
+	req = httptest.NewRequest(http.MethodDelete, "/api/webhooks/"+hook.ID, nil)
+	signIn(req, "other-user")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 deleting another user's webhook, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/webhooks/"+hook.ID, nil)
+	signIn(req, "test-user")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d", w.Code)
+	}
+}
@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestHashPassword(t *testing.T) {
+	hash, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("HashPassword failed: %v", err)
+	}
+	if !strings.HasPrefix(hash, "pbkdf2-sha256$") {
+		t.Errorf("Unexpected hash format %q", hash)
+	}
+	if err := CheckPassword(hash, "correct horse"); err != nil {
+		t.Errorf("Expected password to match, got %v", err)
+	}
+	if err := CheckPassword(hash, "wrong horse"); !errors.Is(err, ErrMismatchedPassword) {
+		t.Errorf("Expected ErrMismatchedPassword, got %v", err)
+	}
+	if err := CheckPassword("plaintext", "plaintext"); !errors.Is(err, ErrMalformedHash) {
+		t.Errorf("Expected ErrMalformedHash, got %v", err)
+	}
+
+	other, _ := HashPassword("correct horse")
+	if other == hash {
+		t.Error("Expected different salts to give different hashes")
+	}
+
+	if _, err := HashPassword("short"); !errors.Is(err, ErrPasswordTooShort) {
+		t.Errorf("Expected ErrPasswordTooShort, got %v", err)
+	}
+}
+
+func TestNewToken(t *testing.T) {
+	token, hash, err := NewToken()
+	if err != nil {
+		t.Fatalf("NewToken failed: %v", err)
+	}
+	if HashToken(token) != hash {
+		t.Error("Expected HashToken to reproduce the stored hash")
+	}
+	other, _, _ := NewToken()
+	if other == token {
+		t.Error("Expected tokens to be unique")
+	}
+}
@@ -0,0 +1,98 @@
+// Package auth holds the credential primitives behind Vesper's accounts:
+// password hashing and opaque session tokens.
+package auth
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	hashScheme = "pbkdf2-sha256"
+	saltSize   = 16
+	keySize    = 32
+
+	// MinPasswordLength is the shortest password accepted for an account.
+	MinPasswordLength = 8
+)
+
+// Iterations is the PBKDF2 work factor for new hashes (OWASP's 2023 figure for
+// SHA-256). Each hash records its own count, so raising it keeps old hashes valid.
+var Iterations = 600000
+
+var (
+	ErrMismatchedPassword = errors.New("password does not match")
+	ErrMalformedHash      = errors.New("malformed password hash")
+	ErrPasswordTooShort   = fmt.Errorf("password must be at least %d characters", MinPasswordLength)
+)
+
+// HashPassword derives a salted hash of password, encoded as
+// "pbkdf2-sha256$<iterations>$<salt>$<key>".
+func HashPassword(password string) (string, error) {
+	if len(password) < MinPasswordLength {
+		return "", ErrPasswordTooShort
+	}
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, Iterations, keySize)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join([]string{
+		hashScheme,
+		strconv.Itoa(Iterations),
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	}, "$"), nil
+}
+
+// CheckPassword reports whether password matches an encoded hash
+func CheckPassword(encoded, password string) error {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 || parts[0] != hashScheme {
+		return ErrMalformedHash
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations < 1 {
+		return ErrMalformedHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return ErrMalformedHash
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return ErrMalformedHash
+	}
+
+	got, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(want))
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare(got, want) != 1 {
+		return ErrMismatchedPassword
+	}
+	return nil
+}
+
+// dummyHash is checked against when a login names an unknown user, so the
+// response takes as long as a wrong password would.
+var dummyHash = sync.OnceValue(func() string {
+	hash, _ := HashPassword("not-a-real-password")
+	return hash
+})
+
+// CheckDummyPassword spends the same work as CheckPassword without a real hash
+func CheckDummyPassword(password string) {
+	_ = CheckPassword(dummyHash(), password)
+}
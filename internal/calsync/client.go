@@ -0,0 +1,93 @@
+// Package calsync keeps a user's tasks and a remote calendar in step. Local
+// creates, updates and deletes are pushed as events; remote changes are
+// pulled back with incremental sync tokens.
+package calsync
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrSyncTokenExpired means the remote calendar discarded the sync token and a full resync is needed
+	ErrSyncTokenExpired = errors.New("sync token expired")
+	// ErrPreconditionFailed means the remote event changed since the etag we hold
+	ErrPreconditionFailed = errors.New("remote event changed")
+	// ErrEventGone means the remote event no longer exists
+	ErrEventGone = errors.New("remote event gone")
+)
+
+// Remote event statuses
+const (
+	EventConfirmed = "confirmed"
+	EventCancelled = "cancelled"
+)
+
+// taskIDProperty is the private extended property that marks events pushed from Vesper
+const taskIDProperty = "vesperTaskId"
+
+// Event is the subset of a Google Calendar event resource that sync uses
+type Event struct {
+	ID                 string              `json:"id,omitempty"`
+	ETag               string              `json:"etag,omitempty"`
+	Status             string              `json:"status,omitempty"`
+	Summary            string              `json:"summary,omitempty"`
+	Start              *EventTime          `json:"start,omitempty"`
+	End                *EventTime          `json:"end,omitempty"`
+	Recurrence         []string            `json:"recurrence,omitempty"`
+	RecurringEventID   string              `json:"recurringEventId,omitempty"`
+	ExtendedProperties *ExtendedProperties `json:"extendedProperties,omitempty"`
+}
+
+// EventTime is either a timed (DateTime) or all-day (Date) boundary
+type EventTime struct {
+	DateTime string `json:"dateTime,omitempty"`
+	Date     string `json:"date,omitempty"`
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// ExtendedProperties carries app-specific key/value pairs on an event
+type ExtendedProperties struct {
+	Private map[string]string `json:"private,omitempty"`
+}
+
+// Changes is the result of an incremental listing
+type Changes struct {
+	Events        []Event
+	NextSyncToken string
+}
+
+// Client is the remote calendar API that the engine drives. GoogleClient
+// implements it; tests substitute a fake server or an in-memory fake.
+type Client interface {
+	InsertEvent(ctx context.Context, calendarID string, ev Event) (Event, error)
+	// UpdateEvent replaces ev.ID, sending ev.ETag as a precondition when set.
+	UpdateEvent(ctx context.Context, calendarID string, ev Event) (Event, error)
+	DeleteEvent(ctx context.Context, calendarID, eventID, etag string) error
+	// ListChanges returns everything changed since syncToken, or every event
+	// when syncToken is empty, following pagination to the end.
+	ListChanges(ctx context.Context, calendarID, syncToken string) (Changes, error)
+}
+
+func timedEventTime(t time.Time) *EventTime {
+	return &EventTime{DateTime: t.UTC().Format(time.RFC3339)}
+}
+
+// parse resolves an event boundary; all-day dates become UTC midnight
+func (et *EventTime) parse() (time.Time, error) {
+	if et == nil {
+		return time.Time{}, errors.New("missing event time")
+	}
+	if et.DateTime != "" {
+		return time.Parse(time.RFC3339, et.DateTime)
+	}
+	return time.Parse(time.DateOnly, et.Date)
+}
+
+func (ev *Event) taskID() string {
+	if ev.ExtendedProperties == nil {
+		return ""
+	}
+	return ev.ExtendedProperties.Private[taskIDProperty]
+}
@@ -0,0 +1,386 @@
+package calsync
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Adjanour/vesper/internal/database"
+	"github.com/Adjanour/vesper/internal/metrics"
+	"github.com/Adjanour/vesper/internal/models"
+)
+
+// revisionActor is recorded as the author of changes pulled from the calendar
+const revisionActor = "calendar-sync"
+
+// Engine reconciles one user's tasks with one remote calendar.
+//
+// Each pass pulls remote changes first, then pushes local ones. When both
+// sides changed the same block, the local version wins and is pushed back.
+// Only scheduled tasks are mirrored; per-occurrence edits of recurring tasks
+// stay local.
+type Engine struct {
+	client     Client
+	db         *database.Queries
+	calendarID string
+}
+
+// NewEngine creates an engine syncing against calendarID ("primary" for the user's main calendar)
+func NewEngine(client Client, q *database.Queries, calendarID string) *Engine {
+	return &Engine{client: client, db: q, calendarID: calendarID}
+}
+
+// Report summarises one sync pass
+type Report struct {
+	Pulled    int      `json:"pulled"`
+	Removed   int      `json:"removed"`
+	Pushed    int      `json:"pushed"`
+	Deleted   int      `json:"deleted"`
+	Conflicts []string `json:"conflicts,omitempty"`
+}
+
+func (r *Report) conflict(format string, args ...any) {
+	r.Conflicts = append(r.Conflicts, fmt.Sprintf(format, args...))
+}
+
+// Sync runs one pull-then-push pass for userID
+func (e *Engine) Sync(ctx context.Context, userID string) (Report, error) {
+	ctx = database.WithActor(ctx, revisionActor)
+	var report Report
+	if err := e.pull(ctx, userID, &report); err != nil {
+		return report, fmt.Errorf("pull: %w", err)
+	}
+	if err := e.push(ctx, userID, &report); err != nil {
+		return report, fmt.Errorf("push: %w", err)
+	}
+	return report, nil
+}
+
+// Run syncs userID every interval until ctx is cancelled
+func (e *Engine) Run(ctx context.Context, userID string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		start := time.Now()
+		report, err := e.Sync(ctx, userID)
+		metrics.ObserveJob("calendar_sync", start, err)
+		if err != nil {
+			slog.ErrorContext(ctx, "calendar sync failed", "user_id", userID, "error", err)
+		} else if report.Pulled+report.Removed+report.Pushed+report.Deleted > 0 || len(report.Conflicts) > 0 {
+			slog.InfoContext(ctx, "calendar sync", "user_id", userID, "report", report)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// syncState is the local side of the calendar, indexed for reconciliation
+type syncState struct {
+	tasks    map[string]*models.Task
+	byTask   map[string]models.SyncLink
+	byRemote map[string]models.SyncLink
+}
+
+func (e *Engine) load(ctx context.Context, userID string) (*syncState, error) {
+	tasks, err := e.db.ListTasks(ctx, userID, database.TaskFilter{})
+	if err != nil {
+		return nil, err
+	}
+	links, err := e.db.ListSyncLinks(ctx, userID, e.calendarID)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &syncState{
+		tasks:    make(map[string]*models.Task, len(tasks)),
+		byTask:   make(map[string]models.SyncLink, len(links)),
+		byRemote: make(map[string]models.SyncLink, len(links)),
+	}
+	for _, t := range tasks {
+		s.tasks[t.ID] = t
+	}
+	for _, l := range links {
+		s.byTask[l.TaskID] = l
+		s.byRemote[l.RemoteID] = l
+	}
+	return s, nil
+}
+
+func (e *Engine) pull(ctx context.Context, userID string, report *Report) error {
+	token, err := e.db.GetSyncToken(ctx, userID, e.calendarID)
+	if err != nil {
+		return err
+	}
+	changes, err := e.client.ListChanges(ctx, e.calendarID, token)
+	if errors.Is(err, ErrSyncTokenExpired) {
+		changes, err = e.client.ListChanges(ctx, e.calendarID, "")
+	}
+	if err != nil {
+		return err
+	}
+
+	state, err := e.load(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, ev := range changes.Events {
+		if err := e.pullEvent(ctx, userID, state, ev, report); err != nil {
+			return err
+		}
+	}
+
+	return e.db.SetSyncToken(ctx, userID, e.calendarID, changes.NextSyncToken)
+}
+
+func (e *Engine) pullEvent(ctx context.Context, userID string, state *syncState, ev Event, report *Report) error {
+	if ev.RecurringEventID != "" {
+		report.conflict("skipped change to a single occurrence of remote event %s", ev.RecurringEventID)
+		return nil
+	}
+
+	link, linked := state.byRemote[ev.ID]
+	if !linked {
+		// an event we pushed whose link was lost: re-attach it and let the local copy win
+		if id := ev.taskID(); id != "" && state.tasks[id] != nil {
+			link = models.SyncLink{TaskID: id, UserID: userID, CalendarID: e.calendarID, RemoteID: ev.ID}
+			linked = true
+		}
+	}
+	task := state.tasks[link.TaskID]
+
+	switch {
+	case ev.Status == EventCancelled:
+		if !linked {
+			return nil
+		}
+		if task != nil && contentHash(task) != link.ContentHash {
+			report.conflict("remote deleted %s after a local edit; keeping local", link.TaskID)
+			return e.db.DeleteSyncLink(ctx, link.TaskID)
+		}
+		if task != nil {
+			// the version we compared must still be the stored one
+			err := e.db.DeleteTask(ctx, task.ID, task.Version)
+			if errors.Is(err, database.ErrVersionMismatch) {
+				report.conflict("remote deleted %s after a local edit; keeping local", link.TaskID)
+				return e.db.DeleteSyncLink(ctx, link.TaskID)
+			}
+			if err != nil && !errors.Is(err, database.ErrNotFound) {
+				return err
+			}
+			report.Removed++
+		}
+		return e.db.DeleteSyncLink(ctx, link.TaskID)
+
+	case !linked:
+		// remote IDs are only unique per calendar, so the task gets its own
+		// ID and the link remembers which event it came from
+		t, err := eventTask(ev, newTaskID(), userID)
+		if err != nil {
+			report.conflict("skipped remote event %s: %v", ev.ID, err)
+			return nil
+		}
+		if err := e.db.CreateTask(ctx, t); err != nil {
+			if errors.Is(err, database.ErrTaskOverlap) || errors.Is(err, database.ErrInvalid) {
+				report.conflict("skipped remote event %s: %v", ev.ID, err)
+				return nil
+			}
+			return err
+		}
+		report.Pulled++
+		return e.link(ctx, &t, ev)
+
+	case ev.ETag == link.ETag:
+		return nil // our own write coming back
+
+	case task == nil:
+		return nil // deleted locally; push removes the remote event
+
+	case contentHash(task) != link.ContentHash:
+		report.conflict("%s changed on both sides; keeping local", task.ID)
+		link.ETag = ev.ETag
+		return e.db.UpsertSyncLink(ctx, link)
+	}
+
+	t, err := eventTask(ev, task.ID, userID)
+	if err != nil {
+		report.conflict("ignored remote change to %s: %v", task.ID, err)
+		link.ETag, link.ContentHash = ev.ETag, ""
+		return e.db.UpsertSyncLink(ctx, link)
+	}
+	t.Status, t.Version = task.Status, task.Version
+	if err := e.db.UpdateTask(ctx, t); err != nil {
+		if errors.Is(err, database.ErrVersionMismatch) {
+			// edited locally since we loaded it: the next push sends the local copy
+			report.conflict("%s changed on both sides; keeping local", task.ID)
+			link.ETag = ev.ETag
+			return e.db.UpsertSyncLink(ctx, link)
+		}
+		if errors.Is(err, database.ErrTaskOverlap) || errors.Is(err, database.ErrInvalid) {
+			// clearing the hash makes the next push restore the local version remotely
+			report.conflict("rejected remote change to %s: %v", task.ID, err)
+			link.ETag, link.ContentHash = ev.ETag, ""
+			return e.db.UpsertSyncLink(ctx, link)
+		}
+		return err
+	}
+	report.Pulled++
+	return e.link(ctx, &t, ev)
+}
+
+func (e *Engine) push(ctx context.Context, userID string, report *Report) error {
+	state, err := e.load(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]string, 0, len(state.tasks))
+	for id := range state.tasks {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		t := state.tasks[id]
+		if t.Status != models.StatusScheduled {
+			continue
+		}
+		link, linked := state.byTask[id]
+		if linked && link.ContentHash == contentHash(t) {
+			continue
+		}
+
+		ev := taskEvent(t)
+		var saved Event
+		if linked {
+			ev.ID, ev.ETag = link.RemoteID, link.ETag
+			saved, err = e.client.UpdateEvent(ctx, e.calendarID, ev)
+			if errors.Is(err, ErrEventGone) {
+				ev.ID, ev.ETag = "", ""
+				saved, err = e.client.InsertEvent(ctx, e.calendarID, ev)
+			}
+		} else {
+			saved, err = e.client.InsertEvent(ctx, e.calendarID, ev)
+		}
+		if errors.Is(err, ErrPreconditionFailed) {
+			report.conflict("remote copy of %s changed during sync; retrying next pass", id)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		report.Pushed++
+		if err := e.link(ctx, t, saved); err != nil {
+			return err
+		}
+	}
+
+	for taskID, link := range state.byTask {
+		if t := state.tasks[taskID]; t != nil && t.Status == models.StatusScheduled {
+			continue
+		}
+		if err := e.client.DeleteEvent(ctx, e.calendarID, link.RemoteID, ""); err != nil {
+			return err
+		}
+		report.Deleted++
+		if err := e.db.DeleteSyncLink(ctx, taskID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *Engine) link(ctx context.Context, t *models.Task, ev Event) error {
+	return e.db.UpsertSyncLink(ctx, models.SyncLink{
+		TaskID:      t.ID,
+		UserID:      t.UserID,
+		CalendarID:  e.calendarID,
+		RemoteID:    ev.ID,
+		ETag:        ev.ETag,
+		ContentHash: contentHash(t),
+		SyncedAt:    time.Now(),
+	})
+}
+
+// contentHash fingerprints the fields that are mirrored to the remote calendar
+func contentHash(t *models.Task) string {
+	fields := fmt.Appendf(nil, "%s\x00%s\x00%s\x00%s\x00%s",
+		t.Title, t.Start.UTC().Format(time.RFC3339Nano), t.End.UTC().Format(time.RFC3339Nano), t.Status, t.Recurrence)
+	if t.Timezone != "" {
+		// appended only when set, so links to unzoned tasks keep their hashes
+		fields = fmt.Appendf(fields, "\x00%s", t.Timezone)
+	}
+	sum := sha256.Sum256(fields)
+	return hex.EncodeToString(sum[:])
+}
+
+// taskEvent renders a task as a remote event tagged with its task ID
+func taskEvent(t *models.Task) Event {
+	ev := Event{
+		Status:  EventConfirmed,
+		Summary: t.Title,
+		Start:   timedEventTime(t.Start),
+		End:     timedEventTime(t.End),
+		ExtendedProperties: &ExtendedProperties{
+			Private: map[string]string{taskIDProperty: t.ID},
+		},
+	}
+	if t.Recurrence != "" {
+		ev.Recurrence = []string{"RRULE:" + t.Recurrence}
+		// the calendar expands the rule in this zone, as Vesper does
+		ev.Start.TimeZone, ev.End.TimeZone = t.Timezone, t.Timezone
+	}
+	return ev
+}
+
+func newTaskID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// eventTask converts a remote event into a scheduled task with the given ID
+func eventTask(ev Event, id, userID string) (models.Task, error) {
+	start, err := ev.Start.parse()
+	if err != nil {
+		return models.Task{}, fmt.Errorf("start: %w", err)
+	}
+	end, err := ev.End.parse()
+	if err != nil {
+		return models.Task{}, fmt.Errorf("end: %w", err)
+	}
+
+	t := models.Task{
+		ID:     id,
+		Title:  ev.Summary,
+		Start:  start,
+		End:    end,
+		UserID: userID,
+		Status: models.StatusScheduled,
+	}
+	if t.Title == "" {
+		t.Title = "(no title)"
+	}
+	for _, line := range ev.Recurrence {
+		if rule, ok := strings.CutPrefix(line, "RRULE:"); ok {
+			if _, err := models.ParseRRule(rule); err != nil {
+				return models.Task{}, fmt.Errorf("recurrence: %w", err)
+			}
+			t.Recurrence = rule
+			t.Timezone = ev.Start.TimeZone
+		}
+	}
+	return t, nil
+}
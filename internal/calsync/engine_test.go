@@ -0,0 +1,395 @@
+package calsync
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Adjanour/vesper/internal/database"
+	"github.com/Adjanour/vesper/internal/models"
+	_ "modernc.org/sqlite"
+)
+
+// fakeCalendar is a local stand-in for the Google Calendar events API
+type fakeCalendar struct {
+	mu      sync.Mutex
+	seq     int
+	events  map[string]*Event
+	changed map[string]int // event id -> seq of last change
+	minSync int            // tokens older than this answer 410 Gone
+}
+
+func newFakeCalendar() *fakeCalendar {
+	return &fakeCalendar{events: map[string]*Event{}, changed: map[string]int{}}
+}
+
+// put stores ev as a remote-side change, bumping its etag
+func (f *fakeCalendar) put(ev Event) Event {
+	f.seq++
+	if ev.ID == "" {
+		ev.ID = fmt.Sprintf("evt%d", f.seq)
+	}
+	ev.ETag = fmt.Sprintf(`"%d"`, f.seq)
+	if ev.Status == "" {
+		ev.Status = EventConfirmed
+	}
+	f.events[ev.ID] = &ev
+	f.changed[ev.ID] = f.seq
+	return ev
+}
+
+func (f *fakeCalendar) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 3 || parts[0] != "calendars" || parts[2] != "events" {
+		http.NotFound(w, r)
+		return
+	}
+	var existing *Event
+	if len(parts) == 4 {
+		existing = f.events[parts[3]]
+		if existing == nil || existing.Status == EventCancelled {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if m := r.Header.Get("If-Match"); m != "" && m != existing.ETag {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+	}
+
+	switch {
+	case r.Method == http.MethodGet && existing == nil:
+		since := 0
+		if token := r.URL.Query().Get("syncToken"); token != "" {
+			since, _ = strconv.Atoi(token)
+			if since < f.minSync {
+				w.WriteHeader(http.StatusGone)
+				return
+			}
+		}
+		list := eventList{Items: []Event{}, NextSyncToken: strconv.Itoa(f.seq)}
+		for id, seq := range f.changed {
+			if seq > since {
+				list.Items = append(list.Items, *f.events[id])
+			}
+		}
+		sort.Slice(list.Items, func(i, j int) bool { return list.Items[i].ID < list.Items[j].ID })
+		_ = json.NewEncoder(w).Encode(list)
+	case r.Method == http.MethodPost:
+		var ev Event
+		_ = json.NewDecoder(r.Body).Decode(&ev)
+		ev.ID = ""
+		_ = json.NewEncoder(w).Encode(f.put(ev))
+	case r.Method == http.MethodPut:
+		var ev Event
+		_ = json.NewDecoder(r.Body).Decode(&ev)
+		ev.ID = existing.ID
+		_ = json.NewEncoder(w).Encode(f.put(ev))
+	case r.Method == http.MethodDelete:
+		existing.Status = EventCancelled
+		f.put(*existing)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func setupSyncDB(t *testing.T) *database.Queries {
+	t.Helper()
+	db, err := sql.Open("sqlite", ":memory:?_pragma=foreign_keys(1)")
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	files, _ := filepath.Glob("../database/migrations/*.up.sql")
+	sort.Strings(files)
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		if _, err := db.Exec(string(content)); err != nil {
+			t.Fatalf("Failed to apply %s: %v", file, err)
+		}
+	}
+	if _, err := db.Exec(`INSERT INTO users (id, username) VALUES ('u1', 'syncer'), ('u2', 'attendee')`); err != nil {
+		t.Fatalf("Failed to insert test users: %v", err)
+	}
+	return database.NewQueries(db)
+}
+
+// linkedTaskID returns the task a user's "primary" calendar links to remoteID
+func linkedTaskID(t *testing.T, q *database.Queries, userID, remoteID string) string {
+	t.Helper()
+	links, err := q.ListSyncLinks(context.Background(), userID, "primary")
+	if err != nil {
+		t.Fatalf("ListSyncLinks() error: %v", err)
+	}
+	for _, l := range links {
+		if l.RemoteID == remoteID {
+			return l.TaskID
+		}
+	}
+	t.Fatalf("no link for remote event %s", remoteID)
+	return ""
+}
+
+func TestEngineSync(t *testing.T) {
+	ctx := context.Background()
+	q := setupSyncDB(t)
+	fake := newFakeCalendar()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	client := &GoogleClient{BaseURL: server.URL, HTTPClient: server.Client(), Token: StaticToken("test-token")}
+	engine := NewEngine(client, q, "primary")
+
+	sync := func() Report {
+		t.Helper()
+		report, err := engine.Sync(ctx, "u1")
+		if err != nil {
+			t.Fatalf("Sync() error: %v", err)
+		}
+		return report
+	}
+
+	start := time.Date(2026, 2, 8, 9, 0, 0, 0, time.UTC)
+	local := models.Task{ID: "local-1", Title: "Focus", Start: start, End: start.Add(time.Hour), UserID: "u1", Status: models.StatusScheduled}
+	if err := q.CreateTask(ctx, local); err != nil {
+		t.Fatalf("CreateTask() error: %v", err)
+	}
+
+	// local create is pushed with its task id attached
+	if r := sync(); r.Pushed != 1 || r.Pulled != 0 {
+		t.Fatalf("first sync = %+v", r)
+	}
+	var remoteID string
+	for id, ev := range fake.events {
+		if ev.taskID() == "local-1" && ev.Summary == "Focus" {
+			remoteID = id
+		}
+	}
+	if remoteID == "" {
+		t.Fatal("pushed event not found on fake calendar")
+	}
+
+	// nothing changed: the pushed event echoes back and is ignored
+	if r := sync(); r.Pushed+r.Pulled+r.Deleted+r.Removed != 0 {
+		t.Fatalf("idle sync = %+v", r)
+	}
+
+	// remote edit is pulled into the local task
+	fake.mu.Lock()
+	moved := *fake.events[remoteID]
+	moved.Summary = "Focus (moved)"
+	moved.Start, moved.End = timedEventTime(start.Add(2*time.Hour)), timedEventTime(start.Add(3*time.Hour))
+	fake.put(moved)
+	// and a brand-new remote event appears
+	added := fake.put(Event{Summary: "Lunch", Start: timedEventTime(start.Add(4 * time.Hour)), End: timedEventTime(start.Add(5 * time.Hour))})
+	fake.mu.Unlock()
+
+	if r := sync(); r.Pulled != 2 || r.Pushed != 0 {
+		t.Fatalf("pull sync = %+v", r)
+	}
+	got, err := q.GetTask(ctx, "local-1")
+	if err != nil || got.Title != "Focus (moved)" || !got.Start.Equal(start.Add(2*time.Hour)) {
+		t.Fatalf("local task after pull = %+v, %v", got, err)
+	}
+	if _, err := q.GetTask(ctx, linkedTaskID(t, q, "u1", added.ID)); err != nil {
+		t.Fatalf("pulled task missing: %v", err)
+	}
+
+	// local delete is pushed; remote cancellation removes the local copy
+	pulledID := linkedTaskID(t, q, "u1", added.ID)
+	if err := q.DeleteTask(ctx, "local-1", 0); err != nil {
+		t.Fatalf("DeleteTask() error: %v", err)
+	}
+	fake.mu.Lock()
+	cancelled := *fake.events[added.ID]
+	cancelled.Status = EventCancelled
+	fake.put(cancelled)
+	fake.mu.Unlock()
+
+	if r := sync(); r.Deleted != 1 || r.Removed != 1 {
+		t.Fatalf("delete sync = %+v", r)
+	}
+	if fake.events[remoteID].Status != EventCancelled {
+		t.Error("remote event was not deleted")
+	}
+	if pulled, err := q.GetTask(ctx, pulledID); err != nil || pulled.Status != models.StatusDeleted {
+		t.Errorf("pulled task not moved to the trash: %+v, %v", pulled, err)
+	}
+
+	// an expired sync token falls back to a full resync
+	fake.mu.Lock()
+	fake.minSync = fake.seq + 1
+	fake.mu.Unlock()
+	if _, err := engine.Sync(ctx, "u1"); err != nil {
+		t.Fatalf("Sync() after token expiry error: %v", err)
+	}
+}
+
+func TestEngineKeepsLocalOnConflict(t *testing.T) {
+	ctx := context.Background()
+	q := setupSyncDB(t)
+	fake := newFakeCalendar()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	engine := NewEngine(&GoogleClient{BaseURL: server.URL, HTTPClient: server.Client()}, q, "primary")
+
+	start := time.Date(2026, 2, 8, 9, 0, 0, 0, time.UTC)
+	task := models.Task{ID: "both", Title: "Original", Start: start, End: start.Add(time.Hour), UserID: "u1", Status: models.StatusScheduled}
+	if err := q.CreateTask(ctx, task); err != nil {
+		t.Fatalf("CreateTask() error: %v", err)
+	}
+	if _, err := engine.Sync(ctx, "u1"); err != nil {
+		t.Fatalf("Sync() error: %v", err)
+	}
+
+	task.Title = "Local edit"
+	if err := q.UpdateTask(ctx, task); err != nil {
+		t.Fatalf("UpdateTask() error: %v", err)
+	}
+	fake.mu.Lock()
+	for _, ev := range fake.events {
+		edited := *ev
+		edited.Summary = "Remote edit"
+		fake.put(edited)
+	}
+	fake.mu.Unlock()
+
+	report, err := engine.Sync(ctx, "u1")
+	if err != nil {
+		t.Fatalf("Sync() error: %v", err)
+	}
+	if len(report.Conflicts) != 1 || report.Pushed != 1 {
+		t.Fatalf("conflict sync = %+v", report)
+	}
+	for _, ev := range fake.events {
+		if ev.Summary != "Local edit" {
+			t.Errorf("remote summary = %q, want local edit", ev.Summary)
+		}
+	}
+}
+
+func TestEnginePullKeepsLocalEditsMadeMidPass(t *testing.T) {
+	ctx := context.Background()
+	q := setupSyncDB(t)
+	fake := newFakeCalendar()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	engine := NewEngine(&GoogleClient{BaseURL: server.URL, HTTPClient: server.Client()}, q, "primary")
+
+	start := time.Date(2026, 2, 8, 9, 0, 0, 0, time.UTC)
+	task := models.Task{ID: "racy", Title: "Original", Start: start, End: start.Add(time.Hour), UserID: "u1", Status: models.StatusScheduled}
+	if err := q.CreateTask(ctx, task); err != nil {
+		t.Fatalf("CreateTask() error: %v", err)
+	}
+	if _, err := engine.Sync(ctx, "u1"); err != nil {
+		t.Fatalf("Sync() error: %v", err)
+	}
+
+	// the pass loads its state, then the user edits before the remote change is applied
+	state, err := engine.load(ctx, "u1")
+	if err != nil {
+		t.Fatalf("load() error: %v", err)
+	}
+	task.Title = "Local edit"
+	if err := q.UpdateTask(ctx, task); err != nil {
+		t.Fatalf("UpdateTask() error: %v", err)
+	}
+
+	fake.mu.Lock()
+	var remote []Event
+	for _, ev := range fake.events {
+		edited := *ev
+		edited.Summary = "Remote edit"
+		remote = append(remote, fake.put(edited))
+		cancelled := edited
+		cancelled.Status = EventCancelled
+		remote = append(remote, cancelled)
+	}
+	fake.mu.Unlock()
+
+	for _, ev := range remote {
+		var report Report
+		if err := engine.pullEvent(ctx, "u1", state, ev, &report); err != nil {
+			t.Fatalf("pullEvent(%s) error: %v", ev.Status, err)
+		}
+		if len(report.Conflicts) != 1 || report.Pulled+report.Removed != 0 {
+			t.Errorf("pulling a %s event over a newer local edit = %+v, want one conflict", ev.Status, report)
+		}
+	}
+
+	got, err := q.GetTask(ctx, "racy")
+	if err != nil {
+		t.Fatalf("GetTask() error: %v", err)
+	}
+	if got.Title != "Local edit" || got.Status != models.StatusScheduled {
+		t.Errorf("task = %+v, want the local edit kept", got)
+	}
+}
+
+func TestEngineScopesRemoteIDsPerUser(t *testing.T) {
+	ctx := context.Background()
+	q := setupSyncDB(t)
+
+	// a shared event keeps its ID in each attendee's primary calendar
+	start := time.Date(2026, 2, 8, 9, 0, 0, 0, time.UTC)
+	for _, userID := range []string{"u1", "u2"} {
+		fake := newFakeCalendar()
+		fake.put(Event{ID: "shared", Summary: "Review", Start: timedEventTime(start), End: timedEventTime(start.Add(time.Hour))})
+		server := httptest.NewServer(fake)
+		defer server.Close()
+
+		client := &GoogleClient{BaseURL: server.URL, HTTPClient: server.Client(), Token: StaticToken("test-token")}
+		if r, err := NewEngine(client, q, "primary").Sync(ctx, userID); err != nil || r.Pulled != 1 {
+			t.Fatalf("%s: Sync() = %+v, %v", userID, r, err)
+		}
+	}
+
+	first, second := linkedTaskID(t, q, "u1", "shared"), linkedTaskID(t, q, "u2", "shared")
+	if first == second {
+		t.Fatalf("Expected each user to get their own task, both linked to %s", first)
+	}
+	if task, err := q.GetTask(ctx, second); err != nil || task.UserID != "u2" {
+		t.Errorf("Expected u2 to own their pulled task, got %+v, %v", task, err)
+	}
+}
+
+func TestEventKeepsSeriesTimezone(t *testing.T) {
+	start := time.Date(2026, 3, 6, 14, 0, 0, 0, time.UTC)
+	series := models.Task{ID: "ny", Title: "Standup", Start: start, End: start.Add(15 * time.Minute), Status: models.StatusScheduled,
+		Recurrence: "FREQ=DAILY", Timezone: "America/New_York"}
+
+	ev := taskEvent(&series)
+	if ev.Start.TimeZone != "America/New_York" || ev.End.TimeZone != "America/New_York" {
+		t.Errorf("event times = %+v, %+v; want the series zone", ev.Start, ev.End)
+	}
+
+	got, err := eventTask(ev, "ny", "u1")
+	if err != nil {
+		t.Fatalf("eventTask() error: %v", err)
+	}
+	if got.Timezone != series.Timezone || contentHash(&got) != contentHash(&series) {
+		t.Errorf("pulled task = %+v, want the pushed series back", got)
+	}
+}
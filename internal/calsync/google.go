@@ -0,0 +1,232 @@
+package calsync
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultGoogleBaseURL is the Google Calendar API v3 root
+const DefaultGoogleBaseURL = "https://www.googleapis.com/calendar/v3"
+
+// DefaultGoogleTokenURL is Google's OAuth 2.0 token endpoint
+const DefaultGoogleTokenURL = "https://oauth2.googleapis.com/token"
+
+// TokenSource returns a bearer access token for the calendar API
+type TokenSource func(ctx context.Context) (string, error)
+
+// StaticToken always returns the same access token
+func StaticToken(token string) TokenSource {
+	return func(context.Context) (string, error) { return token, nil }
+}
+
+// GoogleClient talks to the Google Calendar REST API. BaseURL can point at a
+// local fake server in tests.
+type GoogleClient struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	Token      TokenSource
+}
+
+// NewGoogleClient returns a client for the public Google Calendar API
+func NewGoogleClient(token TokenSource) *GoogleClient {
+	return &GoogleClient{
+		BaseURL:    DefaultGoogleBaseURL,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+		Token:      token,
+	}
+}
+
+func (c *GoogleClient) eventsURL(calendarID string, eventID string) string {
+	u := strings.TrimRight(c.BaseURL, "/") + "/calendars/" + url.PathEscape(calendarID) + "/events"
+	if eventID != "" {
+		u += "/" + url.PathEscape(eventID)
+	}
+	return u
+}
+
+func (c *GoogleClient) do(ctx context.Context, method, rawURL, etag string, body, out any) error {
+	var payload io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		payload = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, payload)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if etag != "" {
+		req.Header.Set("If-Match", etag)
+	}
+	if c.Token != nil {
+		token, err := c.Token(ctx)
+		if err != nil {
+			return fmt.Errorf("calendar token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusPreconditionFailed:
+		return ErrPreconditionFailed
+	case resp.StatusCode == http.StatusGone || resp.StatusCode == http.StatusNotFound:
+		return ErrEventGone
+	case resp.StatusCode >= 300:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("calendar api: %s %s: %s: %s", method, rawURL, resp.Status, bytes.TrimSpace(msg))
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// InsertEvent creates an event
+func (c *GoogleClient) InsertEvent(ctx context.Context, calendarID string, ev Event) (Event, error) {
+	var created Event
+	err := c.do(ctx, http.MethodPost, c.eventsURL(calendarID, ""), "", ev, &created)
+	return created, err
+}
+
+// UpdateEvent replaces an event, guarded by its etag when one is given
+func (c *GoogleClient) UpdateEvent(ctx context.Context, calendarID string, ev Event) (Event, error) {
+	etag := ev.ETag
+	ev.ETag = ""
+	var updated Event
+	err := c.do(ctx, http.MethodPut, c.eventsURL(calendarID, ev.ID), etag, ev, &updated)
+	return updated, err
+}
+
+// DeleteEvent removes an event; an already-deleted event is not an error
+func (c *GoogleClient) DeleteEvent(ctx context.Context, calendarID, eventID, etag string) error {
+	err := c.do(ctx, http.MethodDelete, c.eventsURL(calendarID, eventID), etag, nil, nil)
+	if err == ErrEventGone {
+		return nil
+	}
+	return err
+}
+
+type eventList struct {
+	Items         []Event `json:"items"`
+	NextPageToken string  `json:"nextPageToken"`
+	NextSyncToken string  `json:"nextSyncToken"`
+}
+
+// ListChanges lists events changed since syncToken, including cancelled ones
+func (c *GoogleClient) ListChanges(ctx context.Context, calendarID, syncToken string) (Changes, error) {
+	var changes Changes
+	pageToken := ""
+	for {
+		query := url.Values{}
+		query.Set("showDeleted", "true")
+		if syncToken != "" {
+			query.Set("syncToken", syncToken)
+		}
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+
+		var page eventList
+		err := c.do(ctx, http.MethodGet, c.eventsURL(calendarID, "")+"?"+query.Encode(), "", nil, &page)
+		if err == ErrEventGone {
+			// Google answers 410 Gone when the sync token is no longer valid
+			return Changes{}, ErrSyncTokenExpired
+		}
+		if err != nil {
+			return Changes{}, err
+		}
+
+		changes.Events = append(changes.Events, page.Items...)
+		if page.NextPageToken == "" {
+			changes.NextSyncToken = page.NextSyncToken
+			return changes, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// RefreshTokenSource exchanges a long-lived OAuth refresh token for access
+// tokens, caching each until shortly before it expires.
+type RefreshTokenSource struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	RefreshToken string
+	HTTPClient   *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// Token implements TokenSource
+func (s *RefreshTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && time.Now().Before(s.expires) {
+		return s.token, nil
+	}
+
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"client_id":     {s.ClientID},
+		"client_secret": {s.ClientSecret},
+		"refresh_token": {s.RefreshToken},
+	}
+	tokenURL := s.TokenURL
+	if tokenURL == "" {
+		tokenURL = DefaultGoogleTokenURL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	client := s.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token refresh: %s", resp.Status)
+	}
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	s.token = body.AccessToken
+	// refresh a minute early so in-flight requests never carry an expired token
+	s.expires = time.Now().Add(time.Duration(body.ExpiresIn)*time.Second - time.Minute)
+	return s.token, nil
+}
@@ -0,0 +1,535 @@
+// Package config loads the server's settings from defaults, an optional
+// KEY=VALUE file, environment variables and command-line flags, each
+// overriding the one before.
+package config
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	errNotNumber   = errors.New("must be a whole number")
+	errNotBool     = errors.New("must be true or false")
+	errNotDuration = errors.New("must be a duration such as 30s or 5m")
+	errNotLevel    = errors.New("must be debug, info, warn or error")
+)
+
+// Config is every setting the server reads at startup
+type Config struct {
+	Server   Server
+	Database Database
+	CORS     CORS
+	Planning Planning
+	Mail     Mail
+	Google   Google
+	Webhooks Webhooks
+	Trash    Trash
+	Log      Log
+	Tracing  Tracing
+
+	// File is the config file that was read, if any.
+	File string
+	// PrintConfig asks for the effective configuration to be printed instead of serving.
+	PrintConfig bool
+}
+
+// Server is where and how the HTTP server listens
+type Server struct {
+	Host string
+	Port int
+	// PublicURL is the address users reach the server at, used in emailed links.
+	PublicURL         string
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	// ShutdownTimeout bounds how long in-flight requests and workers get to finish.
+	ShutdownTimeout time.Duration
+	// RequireIfMatch refuses task updates and deletes without an If-Match
+	// header, so no client can overwrite a change it has not seen.
+	RequireIfMatch bool
+	// MetricsAddr is the host:port serving /metrics, apart from the public
+	// listener, or "off".
+	MetricsAddr string
+}
+
+// Addr is the host:port to listen on
+func (s Server) Addr() string {
+	return net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+}
+
+// Database locates the SQLite database
+type Database struct {
+	DataDir string
+	// Path defaults to tasks.db inside DataDir.
+	Path string
+	// Migrate applies pending migrations at startup.
+	Migrate bool
+}
+
+// CORS controls which browser origins may call the API
+type CORS struct {
+	AllowedOrigins   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// Planning configures the nightly planning links
+type Planning struct {
+	LinkSecret    string
+	CheckInterval time.Duration
+}
+
+// Mail configures outgoing email
+type Mail struct {
+	// Transport is "smtp", "log" or empty to use SMTP only when Host is set.
+	Transport string
+	Host      string
+	Port      int
+	Username  string
+	Password  string
+	From      string
+	// TLS is one of opportunistic, starttls, implicit or none.
+	TLS string
+}
+
+// Google configures the Google Calendar sync, enabled when RefreshToken is set.
+// It syncs a single user: the one whose account the refresh token grants.
+type Google struct {
+	ClientID     string
+	ClientSecret string
+	RefreshToken string
+	UserID       string
+	CalendarID   string
+	SyncInterval time.Duration
+}
+
+// Webhooks configures webhook delivery
+type Webhooks struct {
+	RetryInterval time.Duration
+	// AllowPrivateNetworks lets webhooks reach loopback, private and link-local addresses.
+	AllowPrivateNetworks bool
+}
+
+// Trash configures how long deleted tasks are kept before they are purged
+type Trash struct {
+	// Retention is how long a task stays in the trash; zero keeps it forever.
+	Retention     time.Duration
+	PurgeInterval time.Duration
+}
+
+// Log configures the server's log output
+type Log struct {
+	// Format is text or json.
+	Format string
+	Level  slog.Level
+}
+
+// Tracing configures where OpenTelemetry spans are exported
+type Tracing struct {
+	// Exporter is none, otlp, stdout or file.
+	Exporter string
+	// File receives spans as JSON when Exporter is file.
+	File string
+}
+
+// Default returns the configuration used when nothing is overridden
+func Default() *Config {
+	return &Config{
+		Server: Server{
+			Port:              8080,
+			ReadHeaderTimeout: 5 * time.Second,
+			ReadTimeout:       30 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+			ShutdownTimeout:   30 * time.Second,
+			MetricsAddr:       "localhost:9464",
+		},
+		Database: Database{
+			DataDir: "./data",
+		},
+		CORS: CORS{
+			AllowedOrigins:   []string{"*"},
+			AllowCredentials: true,
+			MaxAge:           5 * time.Minute,
+		},
+		Planning: Planning{
+			CheckInterval: time.Minute,
+		},
+		Mail: Mail{
+			Port: 587,
+			From: "Vesper <noreply@localhost>",
+			TLS:  "opportunistic",
+		},
+		Google: Google{
+			CalendarID:   "primary",
+			SyncInterval: 5 * time.Minute,
+		},
+		Webhooks: Webhooks{
+			RetryInterval: 30 * time.Second,
+		},
+		Trash: Trash{
+			PurgeInterval: time.Hour,
+		},
+		Log: Log{
+			Format: "text",
+			Level:  slog.LevelInfo,
+		},
+		Tracing: Tracing{
+			Exporter: "none",
+		},
+	}
+}
+
+// setting binds one configuration key to its Config field
+type setting struct {
+	// key names the setting in config files and the environment.
+	key string
+	// flag is the command-line flag; by default key in lower case with dashes.
+	flag    string
+	section string
+	usage   string
+	value   flag.Value
+	secret  bool
+}
+
+func (s setting) flagName() string {
+	if s.flag != "" {
+		return s.flag
+	}
+	return strings.ToLower(strings.ReplaceAll(s.key, "_", "-"))
+}
+
+func (s setting) isBool() bool {
+	_, ok := s.value.(boolValue)
+	return ok
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		{key: "HOST", flag: "host", section: "Server", usage: "interface to listen on (empty for all)", value: stringValue{&c.Server.Host}},
+		{key: "PORT", flag: "port", section: "Server", usage: "port to listen on", value: intValue{&c.Server.Port}},
+		{key: "PUBLIC_BASE_URL", flag: "public-url", section: "Server", usage: "URL users reach the server at (default http://localhost:PORT)", value: stringValue{&c.Server.PublicURL}},
+		{key: "READ_HEADER_TIMEOUT", section: "Server", usage: "time allowed to read request headers", value: durationValue{&c.Server.ReadHeaderTimeout}},
+		{key: "READ_TIMEOUT", section: "Server", usage: "time allowed to read a whole request", value: durationValue{&c.Server.ReadTimeout}},
+		{key: "WRITE_TIMEOUT", section: "Server", usage: "time allowed to write a response (event streams are exempt)", value: durationValue{&c.Server.WriteTimeout}},
+		{key: "IDLE_TIMEOUT", section: "Server", usage: "how long idle keep-alive connections stay open", value: durationValue{&c.Server.IdleTimeout}},
+		{key: "SHUTDOWN_TIMEOUT", section: "Server", usage: "time allowed for in-flight requests and workers to finish on shutdown", value: durationValue{&c.Server.ShutdownTimeout}},
+		{key: "REQUIRE_IF_MATCH", section: "Server", usage: "refuse task updates and deletes without an If-Match header", value: boolValue{&c.Server.RequireIfMatch}},
+		{key: "METRICS_ADDR", section: "Server", usage: "host:port serving Prometheus metrics, apart from the API or off", value: stringValue{&c.Server.MetricsAddr}},
+
+		{key: "DATA_DIR", section: "Database", usage: "directory holding the database", value: stringValue{&c.Database.DataDir}},
+		{key: "DATABASE_PATH", flag: "db", section: "Database", usage: "SQLite database file (default DATA_DIR/tasks.db)", value: stringValue{&c.Database.Path}},
+		{key: "MIGRATE_ON_BOOT", flag: "migrate", section: "Database", usage: "apply pending database migrations before starting", value: boolValue{&c.Database.Migrate}},
+
+		{key: "CORS_ALLOWED_ORIGINS", flag: "cors-origins", section: "CORS", usage: "comma-separated origins allowed to call the API, or *", value: listValue{&c.CORS.AllowedOrigins}},
+		{key: "CORS_ALLOW_CREDENTIALS", section: "CORS", usage: "let browsers send cookies on cross-origin requests", value: boolValue{&c.CORS.AllowCredentials}},
+		{key: "CORS_MAX_AGE", section: "CORS", usage: "how long browsers may cache preflight responses", value: durationValue{&c.CORS.MaxAge}},
+
+		{key: "PLANNING_LINK_SECRET", section: "Planning", usage: "key signing planning links (random per start when empty)", value: stringValue{&c.Planning.LinkSecret}, secret: true},
+		{key: "PLANNING_CHECK_INTERVAL", section: "Planning", usage: "how often planning schedules are checked", value: durationValue{&c.Planning.CheckInterval}},
+
+		{key: "MAIL_TRANSPORT", section: "Email", usage: "smtp or log (default smtp when SMTP_HOST is set, else log)", value: stringValue{&c.Mail.Transport}},
+		{key: "SMTP_HOST", section: "Email", usage: "SMTP relay host", value: stringValue{&c.Mail.Host}},
+		{key: "SMTP_PORT", section: "Email", usage: "SMTP relay port", value: intValue{&c.Mail.Port}},
+		{key: "SMTP_USER", section: "Email", usage: "SMTP username", value: stringValue{&c.Mail.Username}},
+		{key: "SMTP_PASSWORD", section: "Email", usage: "SMTP password", value: stringValue{&c.Mail.Password}, secret: true},
+		{key: "SMTP_FROM", section: "Email", usage: "sender address", value: stringValue{&c.Mail.From}},
+		{key: "SMTP_TLS", section: "Email", usage: "opportunistic, starttls, implicit or none (none sends no credentials except to localhost)", value: stringValue{&c.Mail.TLS}},
+
+		{key: "GOOGLE_CLIENT_ID", section: "Google Calendar", usage: "OAuth client ID", value: stringValue{&c.Google.ClientID}},
+		{key: "GOOGLE_CLIENT_SECRET", section: "Google Calendar", usage: "OAuth client secret", value: stringValue{&c.Google.ClientSecret}, secret: true},
+		{key: "GOOGLE_REFRESH_TOKEN", section: "Google Calendar", usage: "OAuth refresh token; enables sync", value: stringValue{&c.Google.RefreshToken}, secret: true},
+		{key: "GOOGLE_SYNC_USER_ID", section: "Google Calendar", usage: "the one user whose tasks are synced; other users are not", value: stringValue{&c.Google.UserID}},
+		{key: "GOOGLE_CALENDAR_ID", section: "Google Calendar", usage: "calendar to sync with", value: stringValue{&c.Google.CalendarID}},
+		{key: "GOOGLE_SYNC_INTERVAL", section: "Google Calendar", usage: "how often to sync", value: durationValue{&c.Google.SyncInterval}},
+
+		{key: "WEBHOOK_RETRY_INTERVAL", section: "Webhooks", usage: "how often failed deliveries are checked for a due retry", value: durationValue{&c.Webhooks.RetryInterval}},
+		{key: "WEBHOOK_ALLOW_PRIVATE_NETWORKS", section: "Webhooks", usage: "let webhooks reach loopback, private and link-local addresses", value: boolValue{&c.Webhooks.AllowPrivateNetworks}},
+
+		{key: "TRASH_RETENTION", section: "Trash", usage: "how long deleted tasks are kept before they are purged; 0, the default, keeps them", value: durationValue{&c.Trash.Retention}},
+		{key: "TRASH_PURGE_INTERVAL", section: "Trash", usage: "how often the trash is checked for tasks past their retention", value: durationValue{&c.Trash.PurgeInterval}},
+
+		{key: "LOG_FORMAT", section: "Logging", usage: "text or json", value: stringValue{&c.Log.Format}},
+		{key: "LOG_LEVEL", section: "Logging", usage: "debug, info, warn or error", value: levelValue{&c.Log.Level}},
+
+		{key: "TRACE_EXPORTER", section: "Tracing", usage: "none, otlp (configured by OTEL_EXPORTER_OTLP_*), stdout or file", value: stringValue{&c.Tracing.Exporter}},
+		{key: "TRACE_FILE", section: "Tracing", usage: "file spans are appended to when TRACE_EXPORTER is file", value: stringValue{&c.Tracing.File}},
+	}
+}
+
+// Load builds the configuration from defaults, then the file named by
+// -config or CONFIG_FILE, then the environment, then args. lookupEnv is
+// normally os.LookupEnv; empty variables count as unset.
+func Load(args []string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	cfg := Default()
+	settings := cfg.settings()
+
+	// flags are recorded now and applied last, so they override the file and
+	// environment even though -config has to be read first
+	type assignment struct {
+		setting setting
+		value   string
+	}
+	var fromFlags []assignment
+
+	fs := flag.NewFlagSet("vesper", flag.ContinueOnError)
+	file := fs.String("config", "", "KEY=VALUE file of settings (also CONFIG_FILE)")
+	fs.BoolVar(&cfg.PrintConfig, "print-config", false, "print the effective configuration and exit")
+	for _, s := range settings {
+		usage := fmt.Sprintf("%s (%s)", s.usage, s.key)
+		record := func(v string) error {
+			fromFlags = append(fromFlags, assignment{s, v})
+			return nil
+		}
+		if s.isBool() {
+			fs.BoolFunc(s.flagName(), usage, record)
+		} else {
+			fs.Func(s.flagName(), usage, record)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+
+	var errs []error
+	if *file == "" {
+		*file, _ = lookupEnv("CONFIG_FILE")
+	}
+	if *file != "" {
+		cfg.File = *file
+		if err := cfg.loadFile(*file, settings); err != nil {
+			return nil, err
+		}
+	}
+	for _, s := range settings {
+		if v, ok := lookupEnv(s.key); ok && v != "" {
+			if err := s.value.Set(v); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", s.key, err))
+			}
+		}
+	}
+	for _, a := range fromFlags {
+		if err := a.setting.value.Set(a.value); err != nil {
+			errs = append(errs, fmt.Errorf("-%s: %w", a.setting.flagName(), err))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	cfg.fillDerived()
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadFile applies a file of KEY=VALUE lines in the .env format: blank lines
+// and # comments are ignored, and values may be quoted.
+func (c *Config) loadFile(path string, settings []setting) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+	defer f.Close()
+
+	byKey := make(map[string]setting, len(settings))
+	for _, s := range settings {
+		byKey[s.key] = s
+	}
+
+	var errs []error
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		key = strings.TrimSpace(key)
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s:%d: expected KEY=VALUE", path, n))
+			continue
+		}
+		s, known := byKey[key]
+		if !known {
+			errs = append(errs, fmt.Errorf("%s:%d: unknown setting %s", path, n, key))
+			continue
+		}
+		if value = unquote(strings.TrimSpace(value)); value == "" {
+			continue
+		}
+		if err := s.value.Set(value); err != nil {
+			errs = append(errs, fmt.Errorf("%s:%d: %s: %w", path, n, key, err))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+	return errors.Join(errs...)
+}
+
+// unquote strips matching quotes, or else a trailing " # comment"
+func unquote(v string) string {
+	if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+		return v[1 : len(v)-1]
+	}
+	if i := strings.Index(v, " #"); i >= 0 {
+		return strings.TrimSpace(v[:i])
+	}
+	return v
+}
+
+// fillDerived sets the defaults that depend on other settings
+func (c *Config) fillDerived() {
+	if c.Database.Path == "" {
+		c.Database.Path = filepath.Join(c.Database.DataDir, "tasks.db")
+	}
+	if c.Server.PublicURL == "" {
+		c.Server.PublicURL = "http://localhost:" + strconv.Itoa(c.Server.Port)
+	}
+	c.Server.PublicURL = strings.TrimRight(c.Server.PublicURL, "/")
+}
+
+// Validate reports every setting that is out of range or inconsistent
+func (c *Config) Validate() error {
+	var errs []error
+	invalid := func(key, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: "+format, append([]any{key}, args...)...))
+	}
+	positive := func(key string, d time.Duration) {
+		if d <= 0 {
+			invalid(key, "must be positive")
+		}
+	}
+
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		invalid("PORT", "must be between 1 and 65535")
+	}
+	if u, err := url.Parse(c.Server.PublicURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		invalid("PUBLIC_BASE_URL", "must be an absolute http or https URL")
+	}
+	positive("READ_HEADER_TIMEOUT", c.Server.ReadHeaderTimeout)
+	positive("READ_TIMEOUT", c.Server.ReadTimeout)
+	positive("WRITE_TIMEOUT", c.Server.WriteTimeout)
+	positive("IDLE_TIMEOUT", c.Server.IdleTimeout)
+	positive("SHUTDOWN_TIMEOUT", c.Server.ShutdownTimeout)
+	if c.Server.MetricsAddr != "off" {
+		if _, port, err := net.SplitHostPort(c.Server.MetricsAddr); err != nil || port == "" {
+			invalid("METRICS_ADDR", "must be a host:port such as localhost:9464, or off")
+		} else if c.Server.MetricsAddr == c.Server.Addr() {
+			invalid("METRICS_ADDR", "must differ from the API's address")
+		}
+	}
+
+	if c.Database.Path == "" {
+		invalid("DATABASE_PATH", "must not be empty")
+	}
+
+	if len(c.CORS.AllowedOrigins) == 0 {
+		invalid("CORS_ALLOWED_ORIGINS", "must list at least one origin")
+	}
+	for _, origin := range c.CORS.AllowedOrigins {
+		if origin == "*" {
+			continue
+		}
+		if u, err := url.Parse(origin); err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			invalid("CORS_ALLOWED_ORIGINS", "%q is not an origin such as https://app.example.com", origin)
+		}
+	}
+	if c.CORS.MaxAge < 0 {
+		invalid("CORS_MAX_AGE", "must not be negative")
+	}
+
+	positive("PLANNING_CHECK_INTERVAL", c.Planning.CheckInterval)
+
+	if !slices.Contains([]string{"", "smtp", "log"}, c.Mail.Transport) {
+		invalid("MAIL_TRANSPORT", "must be smtp or log")
+	}
+	if c.Mail.Transport == "smtp" && c.Mail.Host == "" {
+		invalid("SMTP_HOST", "is required when MAIL_TRANSPORT is smtp")
+	}
+	if c.Mail.Port < 1 || c.Mail.Port > 65535 {
+		invalid("SMTP_PORT", "must be between 1 and 65535")
+	}
+	if !slices.Contains([]string{"opportunistic", "starttls", "implicit", "none"}, c.Mail.TLS) {
+		invalid("SMTP_TLS", "must be opportunistic, starttls, implicit or none")
+	}
+	// net/smtp only sends a password in the clear to the local machine
+	if c.Mail.TLS == "none" && (c.Mail.Username != "" || c.Mail.Password != "") &&
+		!slices.Contains([]string{"localhost", "127.0.0.1", "::1"}, c.Mail.Host) {
+		invalid("SMTP_TLS", "must not be none when SMTP_USER or SMTP_PASSWORD is set, unless SMTP_HOST is localhost")
+	}
+
+	if c.Google.RefreshToken != "" {
+		if c.Google.ClientID == "" || c.Google.ClientSecret == "" {
+			invalid("GOOGLE_REFRESH_TOKEN", "needs GOOGLE_CLIENT_ID and GOOGLE_CLIENT_SECRET")
+		}
+		if c.Google.UserID == "" {
+			invalid("GOOGLE_REFRESH_TOKEN", "needs GOOGLE_SYNC_USER_ID")
+		}
+	}
+	positive("GOOGLE_SYNC_INTERVAL", c.Google.SyncInterval)
+
+	positive("WEBHOOK_RETRY_INTERVAL", c.Webhooks.RetryInterval)
+
+	if c.Trash.Retention < 0 {
+		invalid("TRASH_RETENTION", "must not be negative")
+	}
+	positive("TRASH_PURGE_INTERVAL", c.Trash.PurgeInterval)
+
+	if c.Log.Format != "text" && c.Log.Format != "json" {
+		invalid("LOG_FORMAT", "must be text or json")
+	}
+
+	if !slices.Contains([]string{"none", "otlp", "stdout", "file"}, c.Tracing.Exporter) {
+		invalid("TRACE_EXPORTER", "must be none, otlp, stdout or file")
+	}
+	if c.Tracing.Exporter == "file" && c.Tracing.File == "" {
+		invalid("TRACE_FILE", "is required when TRACE_EXPORTER is file")
+	}
+
+	return errors.Join(errs...)
+}
+
+// Print writes the configuration in the config file format, with secrets
+// redacted, so the output can be reviewed or saved as a starting file
+func (c *Config) Print(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if c.File != "" {
+		fmt.Fprintf(bw, "# Loaded from %s\n", c.File)
+	}
+	section := ""
+	for _, s := range c.settings() {
+		if s.section != section {
+			if section != "" || c.File != "" {
+				fmt.Fprintln(bw)
+			}
+			section = s.section
+			fmt.Fprintf(bw, "# %s\n", section)
+		}
+		value := s.value.String()
+		if s.secret && value != "" {
+			value = "<redacted>"
+		}
+		fmt.Fprintf(bw, "%s=%s\n", s.key, value)
+	}
+	return bw.Flush()
+}
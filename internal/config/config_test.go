@@ -0,0 +1,136 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := vars[key]
+		return v, ok
+	}
+}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, err := Load(nil, env(nil))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Server.Addr() != ":8080" {
+		t.Errorf("Expected :8080, got %s", cfg.Server.Addr())
+	}
+	if cfg.Database.Path != filepath.Join("data", "tasks.db") {
+		t.Errorf("Expected the database inside DATA_DIR, got %s", cfg.Database.Path)
+	}
+	if cfg.Server.PublicURL != "http://localhost:8080" {
+		t.Errorf("Expected the public URL to follow the port, got %s", cfg.Server.PublicURL)
+	}
+	if cfg.Server.MetricsAddr != "localhost:9464" {
+		t.Errorf("Expected metrics on their own local port, got %q", cfg.Server.MetricsAddr)
+	}
+	if cfg.Trash.Retention != 0 {
+		t.Errorf("Expected the trash to be kept until a retention is set, got %s", cfg.Trash.Retention)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "vesper.env")
+	content := `# overrides
+PORT=7000
+DATA_DIR="/srv/vesper"
+READ_TIMEOUT=10s   # inline comment
+export SMTP_FROM='Planner <plan@example.com>'
+CORS_ALLOWED_ORIGINS=https://a.example.com, https://b.example.com
+`
+	if err := os.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(
+		[]string{"-config", file, "-port", "9000", "-migrate"},
+		env(map[string]string{"PORT": "8000", "READ_TIMEOUT": "20s", "IDLE_TIMEOUT": ""}),
+	)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Server.Port != 9000 {
+		t.Errorf("Expected the flag to win, got port %d", cfg.Server.Port)
+	}
+	if cfg.Server.ReadTimeout != 20*time.Second {
+		t.Errorf("Expected the environment to override the file, got %s", cfg.Server.ReadTimeout)
+	}
+	if cfg.Server.IdleTimeout != 2*time.Minute {
+		t.Errorf("Expected an empty variable to be ignored, got %s", cfg.Server.IdleTimeout)
+	}
+	if cfg.Database.Path != filepath.Join("/srv/vesper", "tasks.db") || !cfg.Database.Migrate {
+		t.Errorf("Unexpected database settings: %+v", cfg.Database)
+	}
+	if cfg.Mail.From != "Planner <plan@example.com>" {
+		t.Errorf("Expected quotes to be stripped, got %q", cfg.Mail.From)
+	}
+	if len(cfg.CORS.AllowedOrigins) != 2 || cfg.CORS.AllowedOrigins[1] != "https://b.example.com" {
+		t.Errorf("Unexpected origins: %v", cfg.CORS.AllowedOrigins)
+	}
+}
+
+func TestLoadRejectsInvalidSettings(t *testing.T) {
+	_, err := Load(
+		[]string{"-port", "70000", "-cors-origins", "example.com"},
+		env(map[string]string{"SMTP_TLS": "sometimes", "GOOGLE_REFRESH_TOKEN": "x", "LOG_FORMAT": "xml", "METRICS_ADDR": "9464"}),
+	)
+	if err == nil {
+		t.Fatal("Expected validation errors")
+	}
+	for _, key := range []string{"PORT", "CORS_ALLOWED_ORIGINS", "SMTP_TLS", "GOOGLE_REFRESH_TOKEN", "LOG_FORMAT", "METRICS_ADDR"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("Expected an error for %s in %q", key, err)
+		}
+	}
+
+	// credentials only travel unencrypted to the local machine
+	plain := map[string]string{"SMTP_HOST": "smtp.example.com", "SMTP_TLS": "none", "SMTP_USER": "vesper"}
+	if _, err := Load(nil, env(plain)); err == nil || !strings.Contains(err.Error(), "SMTP_TLS") {
+		t.Errorf("Expected an SMTP_TLS error for credentials without TLS, got %v", err)
+	}
+	plain["SMTP_HOST"] = "localhost"
+	if _, err := Load(nil, env(plain)); err != nil {
+		t.Errorf("Expected credentials without TLS to be allowed for localhost, got %v", err)
+	}
+
+	file := filepath.Join(t.TempDir(), "vesper.env")
+	os.WriteFile(file, []byte("PROT=8080\n"), 0600)
+	if _, err := Load([]string{"-config", file}, env(nil)); err == nil || !strings.Contains(err.Error(), "unknown setting PROT") {
+		t.Errorf("Expected an unknown setting error, got %v", err)
+	}
+
+	if _, err := Load([]string{"-h"}, env(nil)); !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("Expected flag.ErrHelp, got %v", err)
+	}
+}
+
+func TestPrintRedactsSecrets(t *testing.T) {
+	cfg, err := Load([]string{"-print-config"}, env(map[string]string{"SMTP_PASSWORD": "hunter22"}))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !cfg.PrintConfig {
+		t.Error("Expected -print-config to be recorded")
+	}
+
+	var out strings.Builder
+	if err := cfg.Print(&out); err != nil {
+		t.Fatalf("Print failed: %v", err)
+	}
+	if strings.Contains(out.String(), "hunter22") || !strings.Contains(out.String(), "SMTP_PASSWORD=<redacted>") {
+		t.Errorf("Expected the password to be redacted:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "PORT=8080\n") {
+		t.Errorf("Expected KEY=VALUE lines:\n%s", out.String())
+	}
+}
@@ -0,0 +1,116 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Adjanour/vesper/internal/models"
+)
+
+const (
+	createUserSQL     = `INSERT INTO users (id, username) VALUES (?, ?)`
+	getCredentialsSQL = `
+	SELECT users.id, users.username, user_credentials.password_hash
+	FROM users
+	JOIN user_credentials ON user_credentials.user_id = users.id
+	WHERE users.username = ?
+	`
+	setPasswordSQL = `
+	INSERT INTO user_credentials (user_id, password_hash, updated_at)
+	VALUES (?, ?, ?)
+	ON CONFLICT (user_id) DO UPDATE SET password_hash = excluded.password_hash, updated_at = excluded.updated_at
+	`
+	createSessionSQL  = `INSERT INTO user_sessions (token_hash, user_id, created_at, expires_at) VALUES (?, ?, ?, ?)`
+	getSessionUserSQL = `
+	SELECT users.id, users.username
+	FROM user_sessions
+	JOIN users ON users.id = user_sessions.user_id
+	WHERE user_sessions.token_hash = ? AND user_sessions.expires_at > ?
+	`
+	deleteSessionSQL         = `DELETE FROM user_sessions WHERE token_hash = ?`
+	deleteUserSessionsSQL    = `DELETE FROM user_sessions WHERE user_id = ?`
+	deleteExpiredSessionsSQL = `DELETE FROM user_sessions WHERE expires_at <= ?`
+)
+
+// isUniqueViolation reports whether err is SQLite rejecting a duplicate key
+func isUniqueViolation(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
+
+// CreateUser adds an account with the given password hash. It returns
+// ErrDuplicate when the username is taken.
+func (q *Queries) CreateUser(ctx context.Context, u models.User, passwordHash string) error {
+	return q.InTx(ctx, func(tx *Queries) error {
+		if _, err := tx.db.ExecContext(ctx, createUserSQL, u.ID, u.Username); err != nil {
+			if isUniqueViolation(err) {
+				return ErrDuplicate
+			}
+			return err
+		}
+		return tx.SetPassword(ctx, u.ID, passwordHash)
+	})
+}
+
+// GetCredentials returns the user with the given username and their password
+// hash. Users without a password cannot sign in and are reported as ErrNotFound.
+func (q *Queries) GetCredentials(ctx context.Context, username string) (*models.User, string, error) {
+	var u models.User
+	var hash string
+	err := q.db.QueryRowContext(ctx, getCredentialsSQL, username).Scan(&u.ID, &u.Username, &hash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, "", ErrNotFound
+		}
+		return nil, "", err
+	}
+	return &u, hash, nil
+}
+
+// SetPassword stores a new password hash for a user
+func (q *Queries) SetPassword(ctx context.Context, userID, passwordHash string) error {
+	_, err := q.db.ExecContext(ctx, setPasswordSQL, userID, passwordHash, time.Now().UTC())
+	return err
+}
+
+// CreateSession records a session for the user, keyed by the hash of its cookie value
+func (q *Queries) CreateSession(ctx context.Context, tokenHash, userID string, expiresAt time.Time) error {
+	_, err := q.db.ExecContext(ctx, createSessionSQL, tokenHash, userID, time.Now().UTC(), expiresAt.UTC())
+	return err
+}
+
+// GetSessionUser returns the user owning an unexpired session
+func (q *Queries) GetSessionUser(ctx context.Context, tokenHash string, now time.Time) (*models.User, error) {
+	var u models.User
+	err := q.db.QueryRowContext(ctx, getSessionUserSQL, tokenHash, now.UTC()).Scan(&u.ID, &u.Username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &u, nil
+}
+
+// DeleteSession ends a session
+func (q *Queries) DeleteSession(ctx context.Context, tokenHash string) error {
+	_, err := q.db.ExecContext(ctx, deleteSessionSQL, tokenHash)
+	return err
+}
+
+// DeleteUserSessions ends every session of a user, e.g. after a password change
+func (q *Queries) DeleteUserSessions(ctx context.Context, userID string) error {
+	_, err := q.db.ExecContext(ctx, deleteUserSessionsSQL, userID)
+	return err
+}
+
+// DeleteExpiredSessions removes sessions that expired before now
+func (q *Queries) DeleteExpiredSessions(ctx context.Context, now time.Time) (int64, error) {
+	res, err := q.db.ExecContext(ctx, deleteExpiredSessionsSQL, now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
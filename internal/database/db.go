@@ -5,7 +5,10 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/Adjanour/vesper/internal/models"
@@ -19,6 +22,10 @@ var (
 	ErrInvalid      = errors.New("invalid")
 	ErrUnauthorized = errors.New("unauthorized")
 	ErrTaskOverlap  = errors.New("task overlap")
+	// ErrTaskInactive refuses to change a task that is no longer scheduled.
+	ErrTaskInactive = errors.New("task is not scheduled")
+	// ErrVersionMismatch refuses a write based on an outdated version of a task.
+	ErrVersionMismatch = errors.New("task version does not match")
 )
 
 // DBTX interface allows mocking or using transactions
@@ -31,15 +38,22 @@ type DBTX interface {
 // Queries struct wraps DBTX (could be *sql.DB or *sql.Tx)
 type Queries struct {
 	db DBTX
+	// conn is db without instrumentation, for starting transactions
+	conn DBTX
 }
 
 func NewQueries(db DBTX) *Queries {
-	return &Queries{db: db}
+	return &Queries{db: instrumentedDB{db}, conn: db}
 }
 
-// Connect to SQLite database and ensure schema exists
-func Connect() (*sql.DB, error) {
-	db, err := sql.Open("sqlite", "./data/tasks.db")
+// Connect to the SQLite database at path, creating its directory if needed.
+// The schema is managed separately by the migrate package.
+func Connect(path string) (*sql.DB, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+	// SQLite only enforces the schema's foreign keys, and their cascades, when asked to on each connection
+	db, err := sql.Open("sqlite", path+"?_pragma=foreign_keys(1)")
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -47,13 +61,14 @@ func Connect() (*sql.DB, error) {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	log.Println("Connected to database")
+	slog.Info("Connected to database", "path", path)
 	return db, nil
 }
 
 func WithTx(ctx context.Context, db *sql.DB, fn func(*Queries) error) error {
 	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
+		slog.ErrorContext(ctx, "database transaction failed to start", "error", err)
 		return err
 	}
 
@@ -62,134 +77,251 @@ func WithTx(ctx context.Context, db *sql.DB, fn func(*Queries) error) error {
 		_ = tx.Rollback()
 		return err
 	}
-	return tx.Commit()
+	if err := tx.Commit(); err != nil {
+		slog.ErrorContext(ctx, "database transaction failed to commit", "error", err)
+		return err
+	}
+	return nil
+}
+
+// InTx runs fn inside WithTx when q is backed by a *sql.DB. When q already
+// wraps a transaction, fn simply joins it.
+func (q *Queries) InTx(ctx context.Context, fn func(*Queries) error) error {
+	db, ok := q.conn.(*sql.DB)
+	if !ok {
+		return fn(q)
+	}
+	return WithTx(ctx, db, fn)
 }
 
 const (
 	createTaskSQL = `
-	INSERT INTO tasks (id, title, start, end, status, user_id)
-	VALUES (?, ?, ?, ?, ?, ?)
+	INSERT INTO tasks (id, title, start, end, status, user_id, replaces, deleted_at, ical_uid)
+	VALUES (?, ?, ?, ?, ?, ?, NULLIF(?, ''), ?, NULLIF(?, ''))
 	`
+	// a task keeps the time it was first deleted until it leaves the trash;
+	// an expected version of 0 matches any
 	updateTaskSQL = `
 	UPDATE tasks
-	SET title = ?, start = ?, end = ?, status = ?, user_id = ?
-	WHERE id = ?
+	SET title = ?, start = ?, end = ?, status = ?, user_id = ?,
+	    deleted_at = CASE WHEN ? THEN COALESCE(deleted_at, ?) END,
+	    version = version + 1
+	WHERE id = ? AND (? = 0 OR version = ?)
 	`
-	deleteTaskSQL              = `DELETE FROM tasks WHERE id = ?`
-	getTaskSQL                 = `SELECT id, title, start, end, status, user_id FROM tasks WHERE id = ?`
-	getTasksSQL                = `SELECT id, title, start, end, status, user_id FROM tasks WHERE user_id = ?`
-	checkTaskOverlapSQL = `
-	SELECT 1
-	FROM tasks
-	WHERE user_id = ?
-	  AND status = ?
-	  AND (? < end)
-	  AND (? > start)
+	markTaskReplacedSQL = `
+	UPDATE tasks
+	SET status = ?, version = version + 1
+	WHERE id = ? AND status = ?
 	`
-	checkTaskOverlapExcludeSQL = `
-	SELECT 1
-	FROM tasks
-	WHERE user_id = ?
-	  AND status = ?
-	  AND (? < end)
-	  AND (? > start)
-	  AND id != ?
+	// the link is set once the successor exists, for the foreign key
+	linkReplacementSQL = `
+	UPDATE tasks SET replaced_by = ? WHERE id = ?
+	`
+	deleteTaskSQL = `
+	UPDATE tasks
+	SET status = ?, deleted_at = ?, version = version + 1
+	WHERE id = ? AND status != ? AND (? = 0 OR version = ?)
 	`
+	// taskColumns matches scanTask; the rrule and its zone come from the recurrence store
+	taskColumns = `tasks.id, tasks.title, tasks.start, tasks.end, tasks.status, tasks.user_id, COALESCE(task_recurrences.rrule, ''), COALESCE(task_recurrences.tzid, ''),
+	COALESCE(tasks.replaces, ''), COALESCE(tasks.replaced_by, ''), tasks.deleted_at, tasks.version, COALESCE(tasks.ical_uid, '')`
+	joinRecurrences = `LEFT JOIN task_recurrences ON task_recurrences.task_id = tasks.id`
+	getTaskSQL      = `SELECT ` + taskColumns + ` FROM tasks ` + joinRecurrences + ` WHERE tasks.id = ?`
+	getTaskByUIDSQL = `SELECT ` + taskColumns + ` FROM tasks ` + joinRecurrences + ` WHERE tasks.user_id = ? AND tasks.ical_uid = ?`
+	getTasksSQL     = `SELECT ` + taskColumns + ` FROM tasks ` + joinRecurrences + ` WHERE tasks.user_id = ?`
+	listTasksSQL    = `SELECT ` + taskColumns + ` FROM tasks`
 )
 
-// CreateTask inserts a new task into DB
-func (q *Queries) CreateTask(ctx context.Context, t models.Task) error {
-	if t.Status == models.StatusScheduled {
-		// check if no task overlaps
-		result, err := q.db.QueryContext(ctx, checkTaskOverlapSQL, t.UserID, models.StatusScheduled, t.Start, t.End)
-		if err != nil {
-			return err
-		}
-		defer result.Close()
+type rowScanner interface {
+	Scan(dest ...any) error
+}
 
-		if result.Next() {
-			return ErrTaskOverlap
-		}
+func scanTask(row rowScanner) (*models.Task, error) {
+	var t models.Task
+	var deletedAt sql.NullTime
+	if err := row.Scan(&t.ID, &t.Title, &t.Start, &t.End, &t.Status, &t.UserID, &t.Recurrence, &t.Timezone, &t.Replaces, &t.ReplacedBy, &deletedAt, &t.Version, &t.ICalUID); err != nil {
+		return nil, err
 	}
-
-	_, err := q.db.ExecContext(ctx, createTaskSQL, t.ID, t.Title, t.Start, t.End, t.Status, t.UserID)
-	return err
+	if deletedAt.Valid {
+		t.DeletedAt = &deletedAt.Time
+	}
+	return &t, nil
 }
 
-// UpdateTask updates an existing task
-func (q *Queries) UpdateTask(ctx context.Context, t models.Task) error {
-	if t.Status == models.StatusScheduled {
-		// Check for overlap with other tasks (excluding this task)
-		result, err := q.db.QueryContext(ctx, checkTaskOverlapExcludeSQL, t.UserID, models.StatusScheduled, t.Start, t.End, t.ID)
-		if err != nil {
-			return err
-		}
-		defer result.Close()
+func scanTasks(rows *sql.Rows) ([]*models.Task, error) {
+	defer rows.Close()
 
-		if result.Next() {
-			return ErrTaskOverlap
+	var tasks []*models.Task
+	for rows.Next() {
+		t, err := scanTask(rows)
+		if err != nil {
+			return nil, err
 		}
+		tasks = append(tasks, t)
 	}
+	return tasks, rows.Err()
+}
 
-	// Update the task
-	execResult, err := q.db.ExecContext(ctx, updateTaskSQL, t.Title, t.Start, t.End, t.Status, t.UserID, t.ID)
-	if err != nil {
-		return err
-	}
+// CreateTask inserts a new task into DB and records its first revision. It
+// returns ErrDuplicate when the ID is taken.
+func (q *Queries) CreateTask(ctx context.Context, t models.Task) error {
+	// store UTC so start/end compare correctly as text in window queries
+	t.Start, t.End = t.Start.UTC(), t.End.UTC()
 
-	rows, err := execResult.RowsAffected()
+	rule, err := parseRecurrence(t.Recurrence)
 	if err != nil {
 		return err
 	}
-	if rows == 0 {
-		return ErrNotFound
+	if err := validateTimezone(t.Timezone); err != nil {
+		return err
 	}
-	return nil
+
+	return q.InTx(ctx, func(q *Queries) error {
+		if t.Status == models.StatusScheduled {
+			// check if no task or occurrence overlaps
+			if err := q.checkOverlap(ctx, t, rule, overlapExclusion{}); err != nil {
+				return err
+			}
+		}
+
+		var deletedAt *time.Time
+		if t.Status == models.StatusDeleted {
+			now := time.Now().UTC()
+			deletedAt = &now
+		}
+		if _, err := q.db.ExecContext(ctx, createTaskSQL, t.ID, t.Title, t.Start, t.End, t.Status, t.UserID, t.Replaces, deletedAt, t.ICalUID); err != nil {
+			if isUniqueViolation(err) {
+				return fmt.Errorf("%w: task %s already exists", ErrDuplicate, t.ID)
+			}
+			return err
+		}
+		if rule != nil {
+			if err := q.setRecurrence(ctx, t.ID, rule, t.Timezone); err != nil {
+				return err
+			}
+		}
+		return q.recordRevision(ctx, t.ID, models.RevisionCreate)
+	})
 }
 
-// DeleteTask deletes a task by ID
-func (q *Queries) DeleteTask(ctx context.Context, id string) error {
-	result, err := q.db.ExecContext(ctx, deleteTaskSQL, id)
+// UpdateTask updates an existing task and records the change as a revision.
+// When t.Version is set, the task must still be at that version, or
+// ErrVersionMismatch is returned and nothing changes.
+func (q *Queries) UpdateTask(ctx context.Context, t models.Task) error {
+	t.Start, t.End = t.Start.UTC(), t.End.UTC()
+
+	rule, err := parseRecurrence(t.Recurrence)
 	if err != nil {
 		return err
 	}
-	rows, err := result.RowsAffected()
-	if err != nil {
+	if err := validateTimezone(t.Timezone); err != nil {
 		return err
 	}
-	if rows == 0 {
-		return ErrNotFound
-	}
-	return nil
+
+	return q.InTx(ctx, func(q *Queries) error {
+		existing, err := q.GetTask(ctx, t.ID)
+		if err != nil {
+			return err
+		}
+		// refuse a stale write before judging its times; the UPDATE checks again
+		if t.Version != 0 && t.Version != existing.Version {
+			return ErrVersionMismatch
+		}
+
+		if t.Status == models.StatusScheduled {
+			// Check for overlap with other tasks (excluding this task)
+			if err := q.checkOverlap(ctx, t, rule, overlapExclusion{TaskID: t.ID}); err != nil {
+				return err
+			}
+		}
+
+		// Update the task
+		execResult, err := q.db.ExecContext(ctx, updateTaskSQL, t.Title, t.Start, t.End, t.Status, t.UserID, t.Status == models.StatusDeleted, time.Now().UTC(), t.ID, t.Version, t.Version)
+		if err != nil {
+			return err
+		}
+
+		rows, err := execResult.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return ErrVersionMismatch
+		}
+
+		if rule == nil {
+			err = q.deleteRecurrence(ctx, t.ID)
+		} else {
+			// overrides are keyed by original start, so they no longer line up once the series moves
+			if existing.Recurrence != rule.String() || existing.Timezone != t.Timezone || !existing.Start.Equal(t.Start) {
+				if _, err := q.db.ExecContext(ctx, deleteOccurrenceOverridesSQL, t.ID); err != nil {
+					return err
+				}
+			}
+			err = q.setRecurrence(ctx, t.ID, rule, t.Timezone)
+		}
+		if err != nil {
+			return err
+		}
+		return q.recordRevision(ctx, t.ID, revisionAction(ctx, models.RevisionUpdate))
+	})
+}
+
+// DeleteTask moves a task to the trash. It keeps its recurrence and
+// overrides so RestoreTask can bring it back whole; PurgeTask removes it.
+// A version other than 0 must be the task's current one, as for UpdateTask.
+func (q *Queries) DeleteTask(ctx context.Context, id string, version int64) error {
+	return q.InTx(ctx, func(q *Queries) error {
+		result, err := q.db.ExecContext(ctx, deleteTaskSQL, models.StatusDeleted, time.Now().UTC(), id, models.StatusDeleted, version, version)
+		if err != nil {
+			return err
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			t, err := q.GetTask(ctx, id)
+			if err != nil {
+				return err
+			}
+			if t.Status == models.StatusDeleted {
+				return ErrNotFound
+			}
+			return ErrVersionMismatch
+		}
+		return q.recordRevision(ctx, id, models.RevisionDelete)
+	})
 }
 
 // GetTask retrieves a task by ID
 func (q *Queries) GetTask(ctx context.Context, id string) (*models.Task, error) {
-	row := q.db.QueryRowContext(ctx, getTaskSQL, id)
-
-	var t models.Task
-	err := row.Scan(&t.ID, &t.Title, &t.Start, &t.End, &t.Status, &t.UserID)
+	t, err := scanTask(q.db.QueryRowContext(ctx, getTaskSQL, id))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNotFound
 		}
 		return nil, err
 	}
-	return &t, nil
+	return t, nil
 }
 
-// CheckTaskOverlap checks if a task overlaps with any existing scheduled tasks for a user
-func (q *Queries) CheckTaskOverlap(ctx context.Context, userID string, start, end time.Time) error {
-	result, err := q.db.QueryContext(ctx, checkTaskOverlapSQL, userID, models.StatusScheduled, start, end)
+// GetTaskByICalUID retrieves the task userID imported from the calendar event with the given UID
+func (q *Queries) GetTaskByICalUID(ctx context.Context, userID, uid string) (*models.Task, error) {
+	t, err := scanTask(q.db.QueryRowContext(ctx, getTaskByUIDSQL, userID, uid))
 	if err != nil {
-		return err
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
 	}
-	defer result.Close()
+	return t, nil
+}
 
-	if result.Next() {
-		return ErrTaskOverlap
-	}
-	return nil
+// CheckTaskOverlap checks if a task overlaps with any existing scheduled tasks or occurrences for a user
+func (q *Queries) CheckTaskOverlap(ctx context.Context, userID string, start, end time.Time) error {
+	t := models.Task{UserID: userID, Start: start.UTC(), End: end.UTC(), Status: models.StatusScheduled}
+	return q.checkOverlap(ctx, t, nil, overlapExclusion{})
 }
 
 // GetTasks retrieves all tasks for a user
@@ -198,16 +330,166 @@ func (q *Queries) GetTasks(ctx context.Context, userID string) ([]*models.Task,
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	return scanTasks(rows)
+}
 
-	var tasks []*models.Task
-	for rows.Next() {
-		var t models.Task
-		err := rows.Scan(&t.ID, &t.Title, &t.Start, &t.End, &t.Status, &t.UserID)
-		if err != nil {
-			return nil, err
-		}
-		tasks = append(tasks, &t)
+// TaskSort names a whitelisted ordering for task listings
+type TaskSort string
+
+const (
+	SortStartAsc  TaskSort = "start"
+	SortStartDesc TaskSort = "-start"
+	SortEndAsc    TaskSort = "end"
+	SortEndDesc   TaskSort = "-end"
+	SortTitleAsc  TaskSort = "title"
+	SortTitleDesc TaskSort = "-title"
+)
+
+var taskSortClauses = map[TaskSort]string{
+	SortStartAsc:  "start ASC, id ASC",
+	SortStartDesc: "start DESC, id DESC",
+	SortEndAsc:    "end ASC, id ASC",
+	SortEndDesc:   "end DESC, id DESC",
+	SortTitleAsc:  "title ASC, id ASC",
+	SortTitleDesc: "title DESC, id DESC",
+}
+
+// IsValidSort reports whether s is a supported sort option
+func IsValidSort(s TaskSort) bool {
+	_, ok := taskSortClauses[s]
+	return ok
+}
+
+// TaskFilter narrows a task listing. Zero values mean "no restriction".
+// When both From and To are set, recurring tasks are expanded into their
+// occurrences inside the window instead of being listed as a single row.
+type TaskFilter struct {
+	// From and To bound a window; a task matches when it overlaps [From, To).
+	From     time.Time
+	To       time.Time
+	Statuses []models.TaskStatus
+	Sort     TaskSort
+	// Limit caps the number of rows returned; zero means unlimited.
+	Limit int
+	// After resumes a start-ordered listing just past the given row.
+	After *TaskCursor
+}
+
+// TaskCursor is a keyset position in a listing ordered by (start, id)
+type TaskCursor struct {
+	Start time.Time
+	ID    string
+}
+
+// inUTC returns f with its window and cursor in UTC. Times are stored and
+// compared as UTC text, so a bound in another zone would match the wrong rows.
+func (f TaskFilter) inUTC() TaskFilter {
+	f.From, f.To = f.From.UTC(), f.To.UTC()
+	if f.After != nil {
+		f.After = &TaskCursor{Start: f.After.Start.UTC(), ID: f.After.ID}
+	}
+	return f
+}
+
+// ListTasks retrieves a user's tasks matching the filter
+func (q *Queries) ListTasks(ctx context.Context, userID string, f TaskFilter) ([]*models.Task, error) {
+	f = f.inUTC()
+	expand := !f.From.IsZero() && !f.To.IsZero()
+
+	query, args, err := buildListTasksQuery(userID, f, expand)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := q.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := scanTasks(rows)
+	if err != nil || !expand {
+		return tasks, err
+	}
+
+	occurrences, err := q.listOccurrences(ctx, userID, f)
+	if err != nil {
+		return nil, err
+	}
+	if len(occurrences) == 0 {
+		return tasks, nil
+	}
+
+	// each side is already limited, so merging and trimming keeps the page exact
+	tasks = append(tasks, occurrences...)
+	sortTasks(tasks, f.Sort)
+	if f.Limit > 0 && len(tasks) > f.Limit {
+		tasks = tasks[:f.Limit]
 	}
 	return tasks, nil
 }
+
+func buildListTasksQuery(userID string, f TaskFilter, expand bool) (string, []any, error) {
+	var sb strings.Builder
+	sb.WriteString(listTasksSQL)
+	if !f.From.IsZero() || !f.To.IsZero() {
+		// the planner otherwise prefers idx_tasks_user_id and scans the user's whole history
+		sb.WriteString(" INDEXED BY idx_tasks_start_end")
+	}
+	sb.WriteString(" " + joinRecurrences)
+	sb.WriteString(" WHERE user_id = ?")
+	args := []any{userID}
+
+	if expand {
+		// recurring series are expanded separately by listOccurrences
+		sb.WriteString(" AND task_recurrences.task_id IS NULL")
+	}
+
+	// start < To AND end > From lets SQLite range-scan idx_tasks_start_end
+	if !f.To.IsZero() {
+		sb.WriteString(" AND start < ?")
+		args = append(args, f.To)
+	}
+	if !f.From.IsZero() {
+		sb.WriteString(" AND end > ?")
+		args = append(args, f.From)
+	}
+
+	if len(f.Statuses) > 0 {
+		sb.WriteString(" AND status IN (?")
+		sb.WriteString(strings.Repeat(", ?", len(f.Statuses)-1))
+		sb.WriteString(")")
+		for _, s := range f.Statuses {
+			args = append(args, s)
+		}
+	}
+
+	sort := f.Sort
+	if sort == "" {
+		sort = SortStartAsc
+	}
+	clause, ok := taskSortClauses[sort]
+	if !ok {
+		return "", nil, fmt.Errorf("%w: unknown sort %q", ErrInvalid, sort)
+	}
+
+	if f.After != nil {
+		switch sort {
+		case SortStartAsc:
+			sb.WriteString(" AND (start > ? OR (start = ? AND id > ?))")
+		case SortStartDesc:
+			sb.WriteString(" AND (start < ? OR (start = ? AND id < ?))")
+		default:
+			return "", nil, fmt.Errorf("%w: cursor requires start ordering", ErrInvalid)
+		}
+		args = append(args, f.After.Start, f.After.Start, f.After.ID)
+	}
+
+	sb.WriteString(" ORDER BY ")
+	sb.WriteString(clause)
+
+	if f.Limit > 0 {
+		sb.WriteString(" LIMIT ?")
+		args = append(args, f.Limit)
+	}
+
+	return sb.String(), args, nil
+}
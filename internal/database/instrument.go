@@ -0,0 +1,154 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Adjanour/vesper/internal/metrics"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/Adjanour/vesper/internal/database"
+
+// instrumentedDB times and traces every statement under the name of the
+// query that ran it, and logs failures with the context's request ID so a
+// 500 response can be matched to its cause
+type instrumentedDB struct {
+	DBTX
+}
+
+func (d instrumentedDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, s := begin(ctx, query)
+	res, err := d.DBTX.ExecContext(ctx, query, args...)
+	s.end(err)
+	return res, err
+}
+
+func (d instrumentedDB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	ctx, s := begin(ctx, query)
+	rows, err := d.DBTX.QueryContext(ctx, query, args...)
+	s.end(err)
+	return rows, err
+}
+
+func (d instrumentedDB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	ctx, s := begin(ctx, query)
+	row := d.DBTX.QueryRowContext(ctx, query, args...)
+	// Err leaves out sql.ErrNoRows, which only Scan reports
+	s.end(row.Err())
+	return row
+}
+
+// statement is one execution being observed
+type statement struct {
+	ctx   context.Context
+	name  string
+	query string
+	start time.Time
+	span  trace.Span
+}
+
+// begin starts observing query. Statements are only traced inside an
+// existing trace, so background jobs do not each start one per statement.
+func begin(ctx context.Context, query string) (context.Context, *statement) {
+	s := &statement{ctx: ctx, name: queryName(), query: query, start: time.Now()}
+	if trace.SpanFromContext(ctx).IsRecording() {
+		ctx, s.span = otel.Tracer(tracerName).Start(ctx, s.name,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				semconv.DBSystemNameSQLite,
+				semconv.DBQuerySummary(summarize(query)),
+				semconv.DBQueryText(compact(query)),
+			),
+		)
+	}
+	return ctx, s
+}
+
+// end records the outcome. It skips logging errors callers are expected to
+// handle: duplicate keys become ErrDuplicate, and canceled requests have no
+// one left to answer.
+func (s *statement) end(err error) {
+	metrics.ObserveQuery(s.name, time.Since(s.start), err)
+	if s.span != nil {
+		if err != nil {
+			s.span.RecordError(err)
+			s.span.SetStatus(codes.Error, err.Error())
+		}
+		s.span.End()
+	}
+
+	if err == nil || isUniqueViolation(err) || errors.Is(err, context.Canceled) {
+		return
+	}
+	slog.ErrorContext(s.ctx, "database query failed", "query", s.name, "sql", compact(s.query), "error", err)
+}
+
+const queriesMethodPrefix = "/internal/database.(*Queries)."
+
+// queryName names a statement after the Queries method that ran it, such as
+// CreateTask or checkOverlap, by finding the nearest one on the call stack
+func queryName() string {
+	pcs := make([]uintptr, 16)
+	// skip runtime.Callers, queryName, begin and the instrumentedDB method
+	n := runtime.Callers(4, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if _, method, ok := strings.Cut(frame.Function, queriesMethodPrefix); ok {
+			// closures passed to InTx are named CreateUser.func1
+			method, _, _ = strings.Cut(method, ".")
+			return method
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
+
+// compact puts a statement on one line
+func compact(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
+
+var summaries sync.Map
+
+// summarize names a statement by its operation and table, such as
+// "INSERT tasks", the low-cardinality db.query.summary of the semantic
+// conventions
+func summarize(query string) string {
+	if s, ok := summaries.Load(query); ok {
+		return s.(string)
+	}
+
+	words := strings.Fields(strings.NewReplacer("(", " ", ")", " ", ",", " ").Replace(query))
+	summary := "unknown"
+	if len(words) > 0 {
+		op := strings.ToUpper(words[0])
+		summary = op
+		// the table follows INTO for inserts, FROM for reads and deletes,
+		// and comes straight after UPDATE
+		marker := map[string]string{"INSERT": "INTO", "REPLACE": "INTO", "SELECT": "FROM", "DELETE": "FROM", "UPDATE": "UPDATE"}[op]
+		for i, w := range words[:len(words)-1] {
+			if marker != "" && strings.EqualFold(w, marker) {
+				table := words[i+1]
+				if op == "UPDATE" && strings.EqualFold(table, "OR") && i+3 < len(words) {
+					table = words[i+3]
+				}
+				summary = op + " " + table
+				break
+			}
+		}
+	}
+	summaries.Store(query, summary)
+	return summary
+}
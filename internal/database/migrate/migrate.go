@@ -1,120 +1,373 @@
-package main
+// Package migrate applies the numbered SQL migrations in
+// internal/database/migrations and records each applied version in the
+// schema_migrations table.
+package migrate
 
 import (
+	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"log"
-	"os"
-	"path/filepath"
-	"sort"
+	"io/fs"
+	"slices"
+	"strconv"
 	"strings"
-
-	_ "modernc.org/sqlite"
+	"time"
 )
 
-const (
-	dataDir       = "./data"
-	dbFile        = "./data/tasks.db"
-	migrationsDir = "./internal/database/migrations"
+const createSchemaMigrationsSQL = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+  version INTEGER PRIMARY KEY,
+  name TEXT NOT NULL,
+  checksum TEXT NOT NULL,
+  applied_at DATETIME NOT NULL
+)`
+
+var (
+	// ErrDrift is returned when an applied migration's file no longer matches what was run
+	ErrDrift = errors.New("applied migrations have changed on disk")
+	// ErrSchemaTooNew is returned when the database has migrations this binary does not know
+	ErrSchemaTooNew = errors.New("database schema is newer than this binary supports")
 )
 
-func main() {
-	if len(os.Args) < 2 {
-		log.Fatal("Usage: go run migrate.go [up|down]")
+// Migration is one numbered schema change read from a pair of
+// <version>_<name>.up.sql and .down.sql files
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+	// Checksum is the SHA-256 of the up file, recorded when it is applied.
+	Checksum string
+}
+
+// Status describes one migration, known from its files, the database or both
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+	// Drifted means the up file changed after it was applied.
+	Drifted bool
+	// Missing means the migration was applied but its files are gone.
+	Missing bool
+}
+
+type appliedMigration struct {
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+// Load reads every migration in fsys, ordered by version
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations directory: %w", err)
 	}
 
-	command := os.Args[1]
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		file := entry.Name()
+		var direction string
+		switch {
+		case entry.IsDir():
+			continue
+		case strings.HasSuffix(file, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(file, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+
+		base := strings.TrimSuffix(file, "."+direction+".sql")
+		prefix, name, _ := strings.Cut(base, "_")
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: file name must start with a positive version number", file)
+		}
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration file %s: %w", file, err)
+		}
 
-	// Ensure data directory exists
-	if err := os.MkdirAll(dataDir, 0755); err != nil {
-		log.Fatalf("Failed to create data directory: %v", err)
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, m.Name, name)
+		}
+		if direction == "up" {
+			sum := sha256.Sum256(content)
+			m.Up = string(content)
+			m.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			m.Down = string(content)
+		}
 	}
 
-	// Connect to database
-	db, err := sql.Open("sqlite", dbFile)
-	if err != nil {
-		log.Fatalf("Failed to open database: %v", err)
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Checksum == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
 	}
-	defer db.Close()
+	slices.SortFunc(migrations, func(a, b Migration) int {
+		return compareVersions(a.Version, b.Version)
+	})
+	return migrations, nil
+}
 
-	if err := db.Ping(); err != nil {
-		log.Fatalf("Failed to ping database: %v", err)
+func compareVersions(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
 	}
+	return 0
+}
 
-	switch command {
-	case "up":
-		if err := migrateUp(db); err != nil {
-			log.Fatalf("Migration up failed: %v", err)
+// Migrator applies and rolls back migrations, recording each applied version
+// in schema_migrations. Every migration runs in its own transaction together
+// with its bookkeeping, so a failure leaves the schema at the last good version.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// NewMigrator creates a migrator for the given migrations, as returned by Load
+func NewMigrator(db *sql.DB, migrations []Migration) *Migrator {
+	return &Migrator{db: db, migrations: migrations}
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int64]appliedMigration, error) {
+	if _, err := m.db.ExecContext(ctx, createSchemaMigrationsSQL); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	rows, err := m.db.QueryContext(ctx, `SELECT version, name, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int64]appliedMigration{}
+	for rows.Next() {
+		var version int64
+		var a appliedMigration
+		if err := rows.Scan(&version, &a.Name, &a.Checksum, &a.AppliedAt); err != nil {
+			return nil, err
 		}
-		log.Println("✓ Migrations applied successfully")
-	case "down":
-		if err := migrateDown(db); err != nil {
-			log.Fatalf("Migration down failed: %v", err)
+		applied[version] = a
+	}
+	return applied, rows.Err()
+}
+
+// Status lists every migration on disk or in the database, oldest first
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []Status
+	for _, mig := range m.migrations {
+		s := Status{Version: mig.Version, Name: mig.Name}
+		if a, ok := applied[mig.Version]; ok {
+			s.Applied = true
+			s.AppliedAt = a.AppliedAt
+			s.Drifted = a.Checksum != mig.Checksum
+			delete(applied, mig.Version)
 		}
-		log.Println("✓ Migrations rolled back successfully")
-	default:
-		log.Fatalf("Unknown command: %s. Use 'up' or 'down'", command)
+		statuses = append(statuses, s)
 	}
+	for version, a := range applied {
+		statuses = append(statuses, Status{
+			Version:   version,
+			Name:      a.Name,
+			Applied:   true,
+			AppliedAt: a.AppliedAt,
+			Missing:   true,
+		})
+	}
+	slices.SortFunc(statuses, func(a, b Status) int {
+		return compareVersions(a.Version, b.Version)
+	})
+	return statuses, nil
 }
 
-func migrateUp(db *sql.DB) error {
-	files, err := getMigrationFiles(".up.sql")
+// Version returns the highest applied version, or 0 for an empty database
+func (m *Migrator) Version(ctx context.Context) (int64, error) {
+	statuses, err := m.Status(ctx)
 	if err != nil {
-		return err
+		return 0, err
 	}
+	var version int64
+	for _, s := range statuses {
+		if s.Applied {
+			version = s.Version
+		}
+	}
+	return version, nil
+}
 
-	for _, file := range files {
-		log.Printf("Applying migration: %s", file)
-		content, err := os.ReadFile(filepath.Join(migrationsDir, file))
-		if err != nil {
-			return fmt.Errorf("failed to read migration file %s: %w", file, err)
+// Latest returns the newest version the migrator knows, or 0 if it has none
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Check reports how many migrations are pending. It fails with
+// ErrSchemaTooNew when the database was migrated by a newer build, and with
+// ErrDrift when an applied migration has since changed.
+func (m *Migrator) Check(ctx context.Context) (pending int, err error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for _, s := range statuses {
+		if s.Missing && s.Version > m.Latest() {
+			return 0, fmt.Errorf("%w: database is at version %d, latest known is %d", ErrSchemaTooNew, s.Version, m.Latest())
 		}
+	}
+	if _, err := m.checkDrift(ctx); err != nil {
+		return 0, err
+	}
+	for _, s := range statuses {
+		if !s.Applied {
+			pending++
+		}
+	}
+	return pending, nil
+}
 
-		if _, err := db.Exec(string(content)); err != nil {
-			return fmt.Errorf("failed to execute migration %s: %w", file, err)
+// checkDrift fails if an applied migration was edited or deleted since it ran
+func (m *Migrator) checkDrift(ctx context.Context) ([]Status, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var changed []string
+	for _, s := range statuses {
+		switch {
+		case s.Drifted:
+			changed = append(changed, fmt.Sprintf("%d_%s (checksum differs)", s.Version, s.Name))
+		case s.Missing:
+			changed = append(changed, fmt.Sprintf("%d_%s (file missing)", s.Version, s.Name))
 		}
 	}
+	if len(changed) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrDrift, strings.Join(changed, ", "))
+	}
+	return statuses, nil
+}
 
-	return nil
+// Up applies up to n pending migrations in version order; n <= 0 applies all
+// of them. It returns the migrations that were applied, even on error.
+func (m *Migrator) Up(ctx context.Context, n int) ([]Migration, error) {
+	return m.up(ctx, n, -1)
 }
 
-func migrateDown(db *sql.DB) error {
-	files, err := getMigrationFiles(".down.sql")
+// Down rolls back the n most recently applied migrations; n <= 0 rolls back
+// all of them. It returns the migrations that were rolled back, even on error.
+func (m *Migrator) Down(ctx context.Context, n int) ([]Migration, error) {
+	return m.down(ctx, n, 0)
+}
+
+// Goto migrates up or down until exactly the migrations up to version are
+// applied. Version 0 rolls everything back.
+func (m *Migrator) Goto(ctx context.Context, version int64) ([]Migration, error) {
+	if version != 0 && !slices.ContainsFunc(m.migrations, func(mig Migration) bool { return mig.Version == version }) {
+		return nil, fmt.Errorf("unknown migration version %d", version)
+	}
+	done, err := m.down(ctx, 0, version)
 	if err != nil {
-		return err
+		return done, err
 	}
+	applied, err := m.up(ctx, 0, version)
+	return append(done, applied...), err
+}
 
-	// Reverse order for down migrations
-	sort.Sort(sort.Reverse(sort.StringSlice(files)))
+// up applies pending migrations no newer than target (-1 for no limit)
+func (m *Migrator) up(ctx context.Context, n int, target int64) ([]Migration, error) {
+	statuses, err := m.checkDrift(ctx)
+	if err != nil {
+		return nil, err
+	}
+	isApplied := map[int64]bool{}
+	for _, s := range statuses {
+		isApplied[s.Version] = s.Applied
+	}
 
-	for _, file := range files {
-		log.Printf("Rolling back migration: %s", file)
-		content, err := os.ReadFile(filepath.Join(migrationsDir, file))
-		if err != nil {
-			return fmt.Errorf("failed to read migration file %s: %w", file, err)
+	var done []Migration
+	for _, mig := range m.migrations {
+		if isApplied[mig.Version] || (target >= 0 && mig.Version > target) {
+			continue
 		}
-
-		if _, err := db.Exec(string(content)); err != nil {
-			return fmt.Errorf("failed to execute migration %s: %w", file, err)
+		if n > 0 && len(done) == n {
+			break
+		}
+		err := m.inTx(ctx, mig.Up, `INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)`,
+			mig.Version, mig.Name, mig.Checksum, time.Now().UTC())
+		if err != nil {
+			return done, fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
 		}
+		done = append(done, mig)
 	}
-
-	return nil
+	return done, nil
 }
 
-func getMigrationFiles(suffix string) ([]string, error) {
-	entries, err := os.ReadDir(migrationsDir)
+// down rolls back applied migrations newer than target, newest first
+func (m *Migrator) down(ctx context.Context, n int, target int64) ([]Migration, error) {
+	statuses, err := m.checkDrift(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read migrations directory: %w", err)
+		return nil, err
+	}
+	isApplied := map[int64]bool{}
+	for _, s := range statuses {
+		isApplied[s.Version] = s.Applied
 	}
 
-	var files []string
-	for _, entry := range entries {
-		if !entry.IsDir() && strings.HasSuffix(entry.Name(), suffix) {
-			files = append(files, entry.Name())
+	var done []Migration
+	for _, mig := range slices.Backward(m.migrations) {
+		if !isApplied[mig.Version] || mig.Version <= target {
+			continue
+		}
+		if n > 0 && len(done) == n {
+			break
 		}
+		if strings.TrimSpace(mig.Down) == "" {
+			return done, fmt.Errorf("migration %d_%s has no down file", mig.Version, mig.Name)
+		}
+		err := m.inTx(ctx, mig.Down, `DELETE FROM schema_migrations WHERE version = ?`, mig.Version)
+		if err != nil {
+			return done, fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+		}
+		done = append(done, mig)
+	}
+	return done, nil
+}
+
+// inTx runs a migration script and its schema_migrations bookkeeping atomically
+func (m *Migrator) inTx(ctx context.Context, script, record string, args ...any) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback()
 
-	sort.Strings(files)
-	return files, nil
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
@@ -0,0 +1,182 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	"github.com/Adjanour/vesper/internal/database/migrations"
+	_ "modernc.org/sqlite"
+)
+
+func testMigrations() fstest.MapFS {
+	return fstest.MapFS{
+		"1_create_a.up.sql":   {Data: []byte(`CREATE TABLE a (id INTEGER);`)},
+		"1_create_a.down.sql": {Data: []byte(`DROP TABLE a;`)},
+		"2_create_b.up.sql":   {Data: []byte(`CREATE TABLE b (id INTEGER);`)},
+		"2_create_b.down.sql": {Data: []byte(`DROP TABLE b;`)},
+		"3_create_c.up.sql":   {Data: []byte(`CREATE TABLE c (id INTEGER);`)},
+		"3_create_c.down.sql": {Data: []byte(`DROP TABLE c;`)},
+		"README.md":           {Data: []byte(`ignored`)},
+	}
+}
+
+func setupMigrator(t *testing.T, fsys fstest.MapFS) (*Migrator, *sql.DB) {
+	t.Helper()
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	migrations, err := Load(fsys)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	return NewMigrator(db, migrations), db
+}
+
+func tableExists(t *testing.T, db *sql.DB, name string) bool {
+	t.Helper()
+	var n int
+	err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, name).Scan(&n)
+	if err != nil {
+		t.Fatalf("Failed to inspect schema: %v", err)
+	}
+	return n == 1
+}
+
+func TestMigratorUpDownGoto(t *testing.T) {
+	ctx := context.Background()
+	m, db := setupMigrator(t, testMigrations())
+
+	if done, err := m.Up(ctx, 2); err != nil || len(done) != 2 {
+		t.Fatalf("Up(2) = %d, %v; want 2 migrations", len(done), err)
+	}
+	if v, _ := m.Version(ctx); v != 2 {
+		t.Errorf("Expected version 2, got %d", v)
+	}
+	// already-applied migrations are not run again
+	if done, err := m.Up(ctx, 0); err != nil || len(done) != 1 || done[0].Version != 3 {
+		t.Fatalf("Up(0) = %v, %v; want only migration 3", done, err)
+	}
+
+	if done, err := m.Down(ctx, 1); err != nil || len(done) != 1 || done[0].Version != 3 {
+		t.Fatalf("Down(1) = %v, %v; want only migration 3", done, err)
+	}
+	if tableExists(t, db, "c") || !tableExists(t, db, "b") {
+		t.Error("Expected only table c to be dropped")
+	}
+
+	if _, err := m.Goto(ctx, 1); err != nil {
+		t.Fatalf("Goto(1) failed: %v", err)
+	}
+	if v, _ := m.Version(ctx); v != 1 || tableExists(t, db, "b") {
+		t.Errorf("Expected version 1 without table b, got version %d", v)
+	}
+	if _, err := m.Goto(ctx, 3); err != nil {
+		t.Fatalf("Goto(3) failed: %v", err)
+	}
+	if v, _ := m.Version(ctx); v != 3 {
+		t.Errorf("Expected version 3, got %d", v)
+	}
+	if _, err := m.Goto(ctx, 0); err != nil {
+		t.Fatalf("Goto(0) failed: %v", err)
+	}
+	if tableExists(t, db, "a") {
+		t.Error("Expected Goto(0) to roll everything back")
+	}
+	if _, err := m.Goto(ctx, 7); err == nil {
+		t.Error("Expected an error for an unknown version")
+	}
+}
+
+func TestMigratorFailedMigrationRollsBack(t *testing.T) {
+	ctx := context.Background()
+	fsys := testMigrations()
+	fsys["2_create_b.up.sql"] = &fstest.MapFile{Data: []byte(`CREATE TABLE b (id INTEGER); CREATE TABLE oops (`)}
+	m, db := setupMigrator(t, fsys)
+
+	done, err := m.Up(ctx, 0)
+	if err == nil || len(done) != 1 {
+		t.Fatalf("Up = %d, %v; want migration 1 then an error", len(done), err)
+	}
+	if tableExists(t, db, "b") {
+		t.Error("Expected the failed migration's statements to be rolled back")
+	}
+	if v, _ := m.Version(ctx); v != 1 {
+		t.Errorf("Expected version 1, got %d", v)
+	}
+}
+
+func TestMigratorDetectsDrift(t *testing.T) {
+	ctx := context.Background()
+	fsys := testMigrations()
+	m, db := setupMigrator(t, fsys)
+	if _, err := m.Up(ctx, 0); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+
+	fsys["2_create_b.up.sql"] = &fstest.MapFile{Data: []byte(`CREATE TABLE b (id INTEGER, name TEXT);`)}
+	migrations, err := Load(fsys)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	m = NewMigrator(db, migrations)
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if !statuses[1].Drifted || statuses[0].Drifted {
+		t.Errorf("Expected only migration 2 to have drifted: %+v", statuses)
+	}
+	if _, err := m.Down(ctx, 1); !errors.Is(err, ErrDrift) {
+		t.Errorf("Expected ErrDrift, got %v", err)
+	}
+}
+
+func TestMigratorCheck(t *testing.T) {
+	ctx := context.Background()
+	fsys := testMigrations()
+	m, db := setupMigrator(t, fsys)
+
+	if pending, err := m.Check(ctx); err != nil || pending != 3 {
+		t.Fatalf("Check = %d, %v; want 3 pending", pending, err)
+	}
+	if _, err := m.Up(ctx, 0); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	if pending, err := m.Check(ctx); err != nil || pending != 0 {
+		t.Fatalf("Check = %d, %v; want none pending", pending, err)
+	}
+
+	// an older build only knows the first two migrations
+	delete(fsys, "3_create_c.up.sql")
+	delete(fsys, "3_create_c.down.sql")
+	migrations, err := Load(fsys)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if _, err := NewMigrator(db, migrations).Check(ctx); !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("Expected ErrSchemaTooNew, got %v", err)
+	}
+}
+
+func TestEmbeddedMigrationsLoad(t *testing.T) {
+	loaded, err := Load(migrations.FS)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(loaded) == 0 {
+		t.Fatal("Expected embedded migrations")
+	}
+	for _, mig := range loaded {
+		if mig.Down == "" {
+			t.Errorf("Migration %d_%s has no down file", mig.Version, mig.Name)
+		}
+	}
+}
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS task_occurrence_overrides;
+DROP TABLE IF EXISTS task_recurrences;
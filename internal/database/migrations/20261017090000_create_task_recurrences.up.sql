@@ -0,0 +1,18 @@
+-- Recurrence rules for repeating tasks (one row per recurring series)
+CREATE TABLE IF NOT EXISTS task_recurrences (
+  task_id TEXT PRIMARY KEY,
+  rrule TEXT NOT NULL,
+  FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE
+);
+
+-- Per-occurrence edits and cancellations, keyed by the occurrence's original start
+CREATE TABLE IF NOT EXISTS task_occurrence_overrides (
+  task_id TEXT NOT NULL,
+  original_start DATETIME NOT NULL,
+  title TEXT,
+  start DATETIME,
+  end DATETIME,
+  cancelled INTEGER NOT NULL DEFAULT 0,
+  PRIMARY KEY (task_id, original_start),
+  FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE
+);
@@ -0,0 +1,23 @@
+-- Links between local tasks and events in a remote calendar.
+-- No foreign key to tasks: a link outlives its task until the remote delete is pushed.
+CREATE TABLE IF NOT EXISTS calendar_sync_links (
+  task_id TEXT PRIMARY KEY,
+  user_id TEXT NOT NULL,
+  calendar_id TEXT NOT NULL,
+  remote_id TEXT NOT NULL,
+  etag TEXT NOT NULL DEFAULT '',
+  content_hash TEXT NOT NULL DEFAULT '',
+  synced_at DATETIME NOT NULL,
+  UNIQUE (calendar_id, remote_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_calendar_sync_links_user ON calendar_sync_links(user_id, calendar_id);
+
+-- Incremental sync tokens per user and calendar
+CREATE TABLE IF NOT EXISTS calendar_sync_state (
+  user_id TEXT NOT NULL,
+  calendar_id TEXT NOT NULL,
+  sync_token TEXT NOT NULL DEFAULT '',
+  synced_at DATETIME,
+  PRIMARY KEY (user_id, calendar_id)
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS planning_sessions;
+DROP TABLE IF EXISTS planning_schedules;
@@ -0,0 +1,20 @@
+-- When each user wants their nightly planning link, in their own time zone
+CREATE TABLE IF NOT EXISTS planning_schedules (
+  user_id TEXT PRIMARY KEY,
+  timezone TEXT NOT NULL DEFAULT 'UTC',
+  send_at TEXT NOT NULL DEFAULT '21:00',
+  enabled INTEGER NOT NULL DEFAULT 1,
+  updated_at DATETIME NOT NULL,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- One planning session per user and day; notified_at makes delivery restart-safe
+CREATE TABLE IF NOT EXISTS planning_sessions (
+  id TEXT PRIMARY KEY,
+  user_id TEXT NOT NULL,
+  plan_date TEXT NOT NULL,
+  created_at DATETIME NOT NULL,
+  notified_at DATETIME,
+  UNIQUE (user_id, plan_date),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,7 @@
+-- Delivery address for each user's email notifications
+CREATE TABLE IF NOT EXISTS user_emails (
+  user_id TEXT PRIMARY KEY,
+  email TEXT NOT NULL,
+  updated_at DATETIME NOT NULL,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
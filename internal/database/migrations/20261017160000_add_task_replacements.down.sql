@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_tasks_replaces;
+ALTER TABLE tasks DROP COLUMN replaced_by;
+ALTER TABLE tasks DROP COLUMN replaces;
@@ -0,0 +1,6 @@
+-- Links between a replaced task and its successor; both sides are stored so
+-- the chain can be walked in either direction from any task
+ALTER TABLE tasks ADD COLUMN replaces TEXT REFERENCES tasks(id) ON DELETE SET NULL;
+ALTER TABLE tasks ADD COLUMN replaced_by TEXT REFERENCES tasks(id) ON DELETE SET NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tasks_replaces ON tasks(replaces);
@@ -0,0 +1,7 @@
+-- Deleted tasks stay in the trash until restored or purged; deleted_at is
+-- when they were put there
+ALTER TABLE tasks ADD COLUMN deleted_at DATETIME;
+
+UPDATE tasks SET deleted_at = CURRENT_TIMESTAMP WHERE status = 'deleted';
+
+CREATE INDEX IF NOT EXISTS idx_tasks_deleted_at ON tasks(deleted_at) WHERE deleted_at IS NOT NULL;
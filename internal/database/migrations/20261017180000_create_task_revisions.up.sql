@@ -0,0 +1,23 @@
+-- Append-only history of every task: one row per change, holding the task as
+-- it was left by that change and who made it
+CREATE TABLE IF NOT EXISTS task_revisions (
+  task_id TEXT NOT NULL,
+  revision INTEGER NOT NULL,
+  action TEXT NOT NULL CHECK (action IN ('create', 'update', 'delete', 'restore', 'replace', 'revert')),
+  snapshot TEXT NOT NULL,
+  actor TEXT NOT NULL,
+  created_at DATETIME NOT NULL,
+  PRIMARY KEY (task_id, revision)
+);
+
+CREATE TRIGGER IF NOT EXISTS task_revisions_no_update
+BEFORE UPDATE ON task_revisions
+BEGIN
+  SELECT RAISE(ABORT, 'task revisions are append-only');
+END;
+
+CREATE TRIGGER IF NOT EXISTS task_revisions_no_delete
+BEFORE DELETE ON task_revisions
+BEGIN
+  SELECT RAISE(ABORT, 'task revisions are append-only');
+END;
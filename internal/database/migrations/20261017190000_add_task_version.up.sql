@@ -0,0 +1,3 @@
+-- version counts the changes to a task so clients can detect conflicting
+-- writes; it backs the ETag and If-Match headers
+ALTER TABLE tasks ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
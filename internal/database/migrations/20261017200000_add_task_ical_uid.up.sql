@@ -0,0 +1,4 @@
+-- ical_uid keeps the UID of an imported calendar event so a re-import finds
+-- the task it created; UIDs are only unique within one user's tasks
+ALTER TABLE tasks ADD COLUMN ical_uid TEXT;
+CREATE UNIQUE INDEX idx_tasks_user_ical_uid ON tasks(user_id, ical_uid) WHERE ical_uid IS NOT NULL;
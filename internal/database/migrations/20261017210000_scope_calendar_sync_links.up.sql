@@ -0,0 +1,18 @@
+-- Remote event IDs are only unique within one user's calendar: "primary"
+-- names a different calendar for every user, and a shared event keeps its
+-- ID in each attendee's calendar. SQLite cannot alter a constraint, so the
+-- table is rebuilt.
+CREATE TABLE calendar_sync_links_new (
+  task_id TEXT PRIMARY KEY,
+  user_id TEXT NOT NULL,
+  calendar_id TEXT NOT NULL,
+  remote_id TEXT NOT NULL,
+  etag TEXT NOT NULL DEFAULT '',
+  content_hash TEXT NOT NULL DEFAULT '',
+  synced_at DATETIME NOT NULL,
+  UNIQUE (user_id, calendar_id, remote_id)
+);
+INSERT INTO calendar_sync_links_new SELECT task_id, user_id, calendar_id, remote_id, etag, content_hash, synced_at FROM calendar_sync_links;
+DROP TABLE calendar_sync_links;
+ALTER TABLE calendar_sync_links_new RENAME TO calendar_sync_links;
+CREATE INDEX IF NOT EXISTS idx_calendar_sync_links_user ON calendar_sync_links(user_id, calendar_id);
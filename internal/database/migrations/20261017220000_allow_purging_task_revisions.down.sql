@@ -0,0 +1,6 @@
+DROP TRIGGER IF EXISTS task_revisions_no_delete;
+CREATE TRIGGER task_revisions_no_delete
+BEFORE DELETE ON task_revisions
+BEGIN
+  SELECT RAISE(ABORT, 'task revisions are append-only');
+END;
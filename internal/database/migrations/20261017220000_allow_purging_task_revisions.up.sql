@@ -0,0 +1,9 @@
+-- Revisions may be deleted only while their task is in the trash, so that
+-- purging a task also removes its history
+DROP TRIGGER IF EXISTS task_revisions_no_delete;
+CREATE TRIGGER task_revisions_no_delete
+BEFORE DELETE ON task_revisions
+WHEN NOT EXISTS (SELECT 1 FROM tasks WHERE id = OLD.task_id AND status = 'deleted')
+BEGIN
+  SELECT RAISE(ABORT, 'task revisions are append-only');
+END;
@@ -0,0 +1,2 @@
+-- tzid names the IANA zone a series repeats in; NULL keeps expanding in UTC
+ALTER TABLE task_recurrences ADD COLUMN tzid TEXT;
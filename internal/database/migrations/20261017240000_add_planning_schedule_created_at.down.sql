@@ -0,0 +1 @@
+ALTER TABLE planning_schedules DROP COLUMN created_at;
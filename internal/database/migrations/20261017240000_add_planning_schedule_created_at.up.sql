@@ -0,0 +1,4 @@
+-- created_at dates the schedule itself, so editing it later does not make
+-- the scheduler treat tonight's link as one that fell due before it existed
+ALTER TABLE planning_schedules ADD COLUMN created_at DATETIME;
+UPDATE planning_schedules SET created_at = updated_at;
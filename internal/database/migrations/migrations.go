@@ -0,0 +1,9 @@
+// Package migrations embeds the SQL schema migrations so every binary carries them.
+package migrations
+
+import "embed"
+
+// FS holds the <version>_<name>.up.sql and .down.sql files
+//
+//go:embed *.sql
+var FS embed.FS
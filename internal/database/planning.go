@@ -0,0 +1,155 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Adjanour/vesper/internal/models"
+)
+
+const (
+	getUserSQL      = `SELECT id, username FROM users WHERE id = ?`
+	getUserEmailSQL = `SELECT email FROM user_emails WHERE user_id = ?`
+	setUserEmailSQL = `
+	INSERT INTO user_emails (user_id, email, updated_at)
+	VALUES (?, ?, ?)
+	ON CONFLICT (user_id) DO UPDATE SET email = excluded.email, updated_at = excluded.updated_at
+	`
+	deleteUserEmailSQL        = `DELETE FROM user_emails WHERE user_id = ?`
+	getPlanningScheduleSQL    = `SELECT user_id, timezone, send_at, enabled, created_at, updated_at FROM planning_schedules WHERE user_id = ?`
+	listPlanningSchedulesSQL  = `SELECT user_id, timezone, send_at, enabled, created_at, updated_at FROM planning_schedules WHERE enabled = 1`
+	upsertPlanningScheduleSQL = `
+	INSERT INTO planning_schedules (user_id, timezone, send_at, enabled, created_at, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?)
+	ON CONFLICT (user_id) DO UPDATE
+	SET timezone = excluded.timezone, send_at = excluded.send_at,
+	    enabled = excluded.enabled, updated_at = excluded.updated_at
+	`
+	createPlanningSessionSQL = `
+	INSERT INTO planning_sessions (id, user_id, plan_date, created_at)
+	VALUES (?, ?, ?, ?)
+	ON CONFLICT (user_id, plan_date) DO NOTHING
+	`
+	getPlanningSessionSQL       = `SELECT id, user_id, plan_date, created_at, notified_at FROM planning_sessions WHERE id = ?`
+	getPlanningSessionByDateSQL = `SELECT id, user_id, plan_date, created_at, notified_at FROM planning_sessions WHERE user_id = ? AND plan_date = ?`
+	markPlanningSessionSQL      = `UPDATE planning_sessions SET notified_at = ? WHERE id = ?`
+)
+
+// GetUser retrieves a user by ID
+func (q *Queries) GetUser(ctx context.Context, id string) (*models.User, error) {
+	var u models.User
+	err := q.db.QueryRowContext(ctx, getUserSQL, id).Scan(&u.ID, &u.Username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &u, nil
+}
+
+// GetUserEmail returns the address a user's notifications are sent to
+func (q *Queries) GetUserEmail(ctx context.Context, userID string) (string, error) {
+	var email string
+	err := q.db.QueryRowContext(ctx, getUserEmailSQL, userID).Scan(&email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrNotFound
+		}
+		return "", err
+	}
+	return email, nil
+}
+
+// SetUserEmail sets a user's notification address; an empty address removes it
+func (q *Queries) SetUserEmail(ctx context.Context, userID, email string) error {
+	if email == "" {
+		_, err := q.db.ExecContext(ctx, deleteUserEmailSQL, userID)
+		return err
+	}
+	_, err := q.db.ExecContext(ctx, setUserEmailSQL, userID, email, time.Now().UTC())
+	return err
+}
+
+func scanPlanningSchedule(row rowScanner) (*models.PlanningSchedule, error) {
+	var s models.PlanningSchedule
+	if err := row.Scan(&s.UserID, &s.Timezone, &s.SendAt, &s.Enabled, &s.CreatedAt, &s.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// GetPlanningSchedule retrieves a user's nightly planning schedule
+func (q *Queries) GetPlanningSchedule(ctx context.Context, userID string) (*models.PlanningSchedule, error) {
+	s, err := scanPlanningSchedule(q.db.QueryRowContext(ctx, getPlanningScheduleSQL, userID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return s, nil
+}
+
+// ListPlanningSchedules returns every enabled schedule
+func (q *Queries) ListPlanningSchedules(ctx context.Context) ([]*models.PlanningSchedule, error) {
+	rows, err := q.db.QueryContext(ctx, listPlanningSchedulesSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schedules []*models.PlanningSchedule
+	for rows.Next() {
+		s, err := scanPlanningSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, s)
+	}
+	return schedules, rows.Err()
+}
+
+// UpsertPlanningSchedule creates or replaces a user's planning schedule. The
+// first save dates the schedule; later ones keep its CreatedAt.
+func (q *Queries) UpsertPlanningSchedule(ctx context.Context, s models.PlanningSchedule) error {
+	_, err := q.db.ExecContext(ctx, upsertPlanningScheduleSQL, s.UserID, s.Timezone, s.SendAt, s.Enabled, s.UpdatedAt.UTC(), s.UpdatedAt.UTC())
+	return err
+}
+
+func scanPlanningSession(row rowScanner) (*models.PlanningSession, error) {
+	var s models.PlanningSession
+	var notified sql.NullTime
+	if err := row.Scan(&s.ID, &s.UserID, &s.PlanDate, &s.CreatedAt, &notified); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if notified.Valid {
+		s.NotifiedAt = &notified.Time
+	}
+	return &s, nil
+}
+
+// GetPlanningSession retrieves a planning session by ID
+func (q *Queries) GetPlanningSession(ctx context.Context, id string) (*models.PlanningSession, error) {
+	return scanPlanningSession(q.db.QueryRowContext(ctx, getPlanningSessionSQL, id))
+}
+
+// EnsurePlanningSession returns the user's session for planDate, creating it
+// with the given ID if none exists yet.
+func (q *Queries) EnsurePlanningSession(ctx context.Context, id, userID, planDate string) (*models.PlanningSession, error) {
+	if _, err := q.db.ExecContext(ctx, createPlanningSessionSQL, id, userID, planDate, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+	return scanPlanningSession(q.db.QueryRowContext(ctx, getPlanningSessionByDateSQL, userID, planDate))
+}
+
+// MarkPlanningSessionNotified records that the session's link was delivered
+func (q *Queries) MarkPlanningSessionNotified(ctx context.Context, id string, at time.Time) error {
+	_, err := q.db.ExecContext(ctx, markPlanningSessionSQL, at.UTC(), id)
+	return err
+}
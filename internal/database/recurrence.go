@@ -0,0 +1,361 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Adjanour/vesper/internal/metrics"
+	"github.com/Adjanour/vesper/internal/models"
+)
+
+// overlapCheckHorizon bounds how far an open-ended series is expanded when checking for overlaps
+const overlapCheckHorizon = 366 * 24 * time.Hour
+
+const (
+	upsertRecurrenceSQL = `
+	INSERT INTO task_recurrences (task_id, rrule, tzid)
+	VALUES (?, ?, NULLIF(?, ''))
+	ON CONFLICT (task_id) DO UPDATE SET rrule = excluded.rrule, tzid = excluded.tzid
+	`
+	deleteRecurrenceSQL         = `DELETE FROM task_recurrences WHERE task_id = ?`
+	upsertOccurrenceOverrideSQL = `
+	INSERT INTO task_occurrence_overrides (task_id, original_start, title, start, end, cancelled)
+	VALUES (?, ?, ?, ?, ?, ?)
+	ON CONFLICT (task_id, original_start) DO UPDATE
+	SET title = excluded.title, start = excluded.start, end = excluded.end, cancelled = excluded.cancelled
+	`
+	deleteOccurrenceOverridesSQL = `DELETE FROM task_occurrence_overrides WHERE task_id = ?`
+	// an occurrence edit is a write to its series
+	touchSeriesSQL            = `UPDATE tasks SET version = version + 1 WHERE id = ?`
+	getOccurrenceOverridesSQL = `
+	SELECT original_start, cancelled, COALESCE(title, ''), start, end
+	FROM task_occurrence_overrides
+	WHERE task_id = ?
+	ORDER BY original_start
+	`
+	getRecurringTasksSQL = `
+	SELECT ` + taskColumns + `
+	FROM tasks
+	JOIN task_recurrences ON task_recurrences.task_id = tasks.id
+	WHERE tasks.user_id = ?
+	  AND tasks.start < ?
+	`
+	// single rows are matched by window; series are matched by start and expanded in Go
+	getScheduledInWindowSQL = `
+	SELECT ` + taskColumns + `
+	FROM tasks
+	` + joinRecurrences + `
+	WHERE tasks.user_id = ?
+	  AND tasks.status = ?
+	  AND tasks.id != ?
+	  AND tasks.start < ?
+	  AND (task_recurrences.task_id IS NOT NULL OR tasks.end > ?)
+	`
+)
+
+// parseRecurrence parses a stored or submitted rule; an empty rule yields nil
+func parseRecurrence(s string) (*models.RRule, error) {
+	if s == "" {
+		return nil, nil
+	}
+	rule, err := models.ParseRRule(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: recurrence: %v", ErrInvalid, err)
+	}
+	return rule, nil
+}
+
+// validateTimezone checks a submitted zone name; an empty name is UTC
+func validateTimezone(name string) error {
+	if _, err := time.LoadLocation(name); err != nil {
+		return fmt.Errorf("%w: timezone: unknown zone %q", ErrInvalid, name)
+	}
+	return nil
+}
+
+func (q *Queries) setRecurrence(ctx context.Context, taskID string, rule *models.RRule, tzid string) error {
+	_, err := q.db.ExecContext(ctx, upsertRecurrenceSQL, taskID, rule.String(), tzid)
+	return err
+}
+
+// deleteRecurrence turns a series back into a one-off block, dropping its occurrence edits
+func (q *Queries) deleteRecurrence(ctx context.Context, taskID string) error {
+	if _, err := q.db.ExecContext(ctx, deleteOccurrenceOverridesSQL, taskID); err != nil {
+		return err
+	}
+	_, err := q.db.ExecContext(ctx, deleteRecurrenceSQL, taskID)
+	return err
+}
+
+// GetOccurrenceOverrides returns the edited and cancelled occurrences of a recurring task
+func (q *Queries) GetOccurrenceOverrides(ctx context.Context, taskID string) ([]models.OccurrenceOverride, error) {
+	rows, err := q.db.QueryContext(ctx, getOccurrenceOverridesSQL, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var overrides []models.OccurrenceOverride
+	for rows.Next() {
+		var o models.OccurrenceOverride
+		var start, end sql.NullTime
+		if err := rows.Scan(&o.RecurrenceID, &o.Cancelled, &o.Title, &start, &end); err != nil {
+			return nil, err
+		}
+		o.Start, o.End = start.Time, end.Time
+		overrides = append(overrides, o)
+	}
+	return overrides, rows.Err()
+}
+
+// expand turns a recurring task row into its occurrences overlapping [from, to)
+func (q *Queries) expand(ctx context.Context, t *models.Task, from, to time.Time) ([]models.Task, error) {
+	rule, err := parseRecurrence(t.Recurrence)
+	if err != nil {
+		return nil, err
+	}
+	overrides, err := q.GetOccurrenceOverrides(ctx, t.ID)
+	if err != nil {
+		return nil, err
+	}
+	return models.Occurrences(*t, rule, overrides, from, to), nil
+}
+
+// overlapExclusion names the stored block a write replaces, so it is not
+// counted against itself. A zero RecurrenceID excludes the whole series.
+type overlapExclusion struct {
+	TaskID       string
+	RecurrenceID time.Time
+}
+
+func (e overlapExclusion) excludes(t models.Task) bool {
+	if e.TaskID == "" || t.ID != e.TaskID {
+		return false
+	}
+	if e.RecurrenceID.IsZero() {
+		return true
+	}
+	return t.RecurrenceID != nil && t.RecurrenceID.Equal(e.RecurrenceID)
+}
+
+// checkOverlap returns ErrTaskOverlap when t, or any of its occurrences under
+// rule, overlaps a scheduled block or occurrence of the same user.
+func (q *Queries) checkOverlap(ctx context.Context, t models.Task, rule *models.RRule, exclude overlapExclusion) error {
+	candidates := []models.Task{t}
+	if rule != nil {
+		candidates = models.Occurrences(t, rule, nil, t.Start, t.Start.Add(overlapCheckHorizon))
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	from, to := candidates[0].Start, candidates[0].End
+	for _, c := range candidates[1:] {
+		if c.End.After(to) {
+			to = c.End
+		}
+	}
+
+	existing, err := q.scheduledOccurrences(ctx, t.UserID, from, to, exclude)
+	if err != nil {
+		return err
+	}
+	for _, c := range candidates {
+		if models.IsOverlapping(c, existing) {
+			metrics.CountOverlapRejection()
+			return ErrTaskOverlap
+		}
+	}
+	return nil
+}
+
+// scheduledOccurrences lists every scheduled block of a user overlapping [from, to),
+// with recurring series expanded into occurrences.
+func (q *Queries) scheduledOccurrences(ctx context.Context, userID string, from, to time.Time, exclude overlapExclusion) ([]models.Task, error) {
+	// a series is skipped wholesale by id only when the whole series is excluded
+	excludeID := ""
+	if exclude.RecurrenceID.IsZero() {
+		excludeID = exclude.TaskID
+	}
+
+	rows, err := q.db.QueryContext(ctx, getScheduledInWindowSQL, userID, models.StatusScheduled, excludeID, to, from)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := scanTasks(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	var blocks []models.Task
+	for _, t := range stored {
+		if t.Recurrence == "" {
+			blocks = append(blocks, *t)
+			continue
+		}
+		occurrences, err := q.expand(ctx, t, from, to)
+		if err != nil {
+			return nil, err
+		}
+		for _, o := range occurrences {
+			if !exclude.excludes(o) {
+				blocks = append(blocks, o)
+			}
+		}
+	}
+	return blocks, nil
+}
+
+// listOccurrences expands the user's recurring series inside the filter window,
+// applying the status filter and cursor the same way the SQL listing does.
+func (q *Queries) listOccurrences(ctx context.Context, userID string, f TaskFilter) ([]*models.Task, error) {
+	f = f.inUTC()
+	query := getRecurringTasksSQL
+	args := []any{userID, f.To}
+	if len(f.Statuses) > 0 {
+		query += " AND tasks.status IN (?" + strings.Repeat(", ?", len(f.Statuses)-1) + ")"
+		for _, s := range f.Statuses {
+			args = append(args, s)
+		}
+	}
+
+	rows, err := q.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	series, err := scanTasks(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks []*models.Task
+	for _, s := range series {
+		occurrences, err := q.expand(ctx, s, f.From, f.To)
+		if err != nil {
+			return nil, err
+		}
+		for i := range occurrences {
+			if f.After != nil && !afterCursor(&occurrences[i], f.After, f.Sort) {
+				continue
+			}
+			tasks = append(tasks, &occurrences[i])
+		}
+	}
+	return tasks, nil
+}
+
+func afterCursor(t *models.Task, c *TaskCursor, s TaskSort) bool {
+	if t.Start.Equal(c.Start) {
+		if s == SortStartDesc {
+			return t.ID < c.ID
+		}
+		return t.ID > c.ID
+	}
+	if s == SortStartDesc {
+		return t.Start.Before(c.Start)
+	}
+	return t.Start.After(c.Start)
+}
+
+// sortTasks orders tasks in memory the same way taskSortClauses orders rows
+func sortTasks(tasks []*models.Task, s TaskSort) {
+	compare := func(a, b *models.Task) int { return a.Start.Compare(b.Start) }
+	switch strings.TrimPrefix(string(s), "-") {
+	case "end":
+		compare = func(a, b *models.Task) int { return a.End.Compare(b.End) }
+	case "title":
+		compare = func(a, b *models.Task) int { return strings.Compare(a.Title, b.Title) }
+	}
+
+	desc := strings.HasPrefix(string(s), "-")
+	slices.SortStableFunc(tasks, func(a, b *models.Task) int {
+		c := compare(a, b)
+		if c == 0 {
+			c = strings.Compare(a.ID, b.ID)
+		}
+		if desc {
+			return -c
+		}
+		return c
+	})
+}
+
+// getSeries loads a recurring task and checks that recurrenceID is one of its occurrences
+func (q *Queries) getSeries(ctx context.Context, taskID string, recurrenceID time.Time) (*models.Task, *models.RRule, error) {
+	t, err := q.GetTask(ctx, taskID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if t.Recurrence == "" {
+		return nil, nil, fmt.Errorf("%w: task is not recurring", ErrInvalid)
+	}
+	rule, err := parseRecurrence(t.Recurrence)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !rule.Includes(t.Start.In(t.Location()), recurrenceID.UTC()) {
+		return nil, nil, ErrNotFound
+	}
+	return t, rule, nil
+}
+
+// UpdateOccurrence moves or retitles a single occurrence of a recurring task
+func (q *Queries) UpdateOccurrence(ctx context.Context, taskID string, recurrenceID time.Time, title string, start, end time.Time) (*models.Task, error) {
+	recurrenceID, start, end = recurrenceID.UTC(), start.UTC(), end.UTC()
+
+	var occurrence models.Task
+	err := q.InTx(ctx, func(q *Queries) error {
+		series, _, err := q.getSeries(ctx, taskID, recurrenceID)
+		if err != nil {
+			return err
+		}
+		if title == "" {
+			title = series.Title
+		}
+
+		occurrence = *series
+		occurrence.Title, occurrence.Start, occurrence.End = title, start, end
+		occurrence.RecurrenceID = &recurrenceID
+
+		if series.Status == models.StatusScheduled {
+			exclude := overlapExclusion{TaskID: taskID, RecurrenceID: recurrenceID}
+			if err := q.checkOverlap(ctx, occurrence, nil, exclude); err != nil {
+				return err
+			}
+		}
+
+		if _, err := q.db.ExecContext(ctx, upsertOccurrenceOverrideSQL, taskID, recurrenceID, title, start, end, false); err != nil {
+			return err
+		}
+		if _, err := q.db.ExecContext(ctx, touchSeriesSQL, taskID); err != nil {
+			return err
+		}
+		occurrence.Version++
+		return q.recordRevision(ctx, taskID, models.RevisionUpdate)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &occurrence, nil
+}
+
+// CancelOccurrence removes a single occurrence from a recurring task
+func (q *Queries) CancelOccurrence(ctx context.Context, taskID string, recurrenceID time.Time) error {
+	recurrenceID = recurrenceID.UTC()
+	return q.InTx(ctx, func(q *Queries) error {
+		if _, _, err := q.getSeries(ctx, taskID, recurrenceID); err != nil {
+			return err
+		}
+		if _, err := q.db.ExecContext(ctx, upsertOccurrenceOverrideSQL, taskID, recurrenceID, nil, nil, nil, true); err != nil {
+			return err
+		}
+		if _, err := q.db.ExecContext(ctx, touchSeriesSQL, taskID); err != nil {
+			return err
+		}
+		return q.recordRevision(ctx, taskID, models.RevisionUpdate)
+	})
+}
@@ -0,0 +1,76 @@
+package database
+
+import (
+	"context"
+
+	"github.com/Adjanour/vesper/internal/models"
+)
+
+// ReplaceTask marks the scheduled task id as replaced and creates next as its
+// successor, linking the two. The old block no longer counts towards the
+// overlap check, so the successor may take its place. It returns the old task
+// as stored afterwards.
+func (q *Queries) ReplaceTask(ctx context.Context, id string, next models.Task) (*models.Task, error) {
+	var old *models.Task
+	err := q.InTx(ctx, func(q *Queries) error {
+		result, err := q.db.ExecContext(ctx, markTaskReplacedSQL, models.StatusReplaced, id, models.StatusScheduled)
+		if err != nil {
+			return err
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			if _, err := q.GetTask(ctx, id); err != nil {
+				return err
+			}
+			return ErrTaskInactive
+		}
+
+		next.Replaces = id
+		if err := q.CreateTask(ctx, next); err != nil {
+			return err
+		}
+		if _, err := q.db.ExecContext(ctx, linkReplacementSQL, next.ID, id); err != nil {
+			return err
+		}
+		if err := q.recordRevision(ctx, id, models.RevisionReplace); err != nil {
+			return err
+		}
+		old, err = q.GetTask(ctx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return old, nil
+}
+
+// maxReplacementChain stops a walk over corrupted links that loop
+const maxReplacementChain = 1000
+
+// GetReplacementChain returns every task in id's replacement chain, from the
+// original block to its latest successor
+func (q *Queries) GetReplacementChain(ctx context.Context, id string) ([]*models.Task, error) {
+	task, err := q.GetTask(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// walk back to the original, then forward to the latest successor
+	chain := []*models.Task{task}
+	for first := task; first.Replaces != "" && len(chain) < maxReplacementChain; {
+		if first, err = q.GetTask(ctx, first.Replaces); err != nil {
+			return nil, err
+		}
+		chain = append([]*models.Task{first}, chain...)
+	}
+	for last := task; last.ReplacedBy != "" && len(chain) < maxReplacementChain; {
+		if last, err = q.GetTask(ctx, last.ReplacedBy); err != nil {
+			return nil, err
+		}
+		chain = append(chain, last)
+	}
+	return chain, nil
+}
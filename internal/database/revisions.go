@@ -0,0 +1,141 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/Adjanour/vesper/internal/models"
+)
+
+// systemActor is recorded for changes made without an actor in the context
+const systemActor = "system"
+
+const (
+	// revisions are numbered per task; the surrounding transaction keeps the
+	// next number from being taken twice
+	insertRevisionSQL = `
+	INSERT INTO task_revisions (task_id, revision, action, snapshot, actor, created_at)
+	SELECT ?, COALESCE(MAX(revision), 0) + 1, ?, ?, ?, ?
+	FROM task_revisions
+	WHERE task_id = ?
+	`
+	revisionColumns  = `task_id, revision, action, snapshot, actor, created_at`
+	listRevisionsSQL = `SELECT ` + revisionColumns + ` FROM task_revisions WHERE task_id = ? ORDER BY revision`
+	getRevisionSQL   = `SELECT ` + revisionColumns + ` FROM task_revisions WHERE task_id = ? AND revision = ?`
+)
+
+type actorContextKey struct{}
+
+// WithActor names who changes tasks through ctx, for their revisions
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorContextKey{}, actor)
+}
+
+func actorFromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorContextKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return systemActor
+}
+
+type revisionActionContextKey struct{}
+
+// revisionAction is the action an update through ctx is recorded as, so
+// RevertTask can reuse UpdateTask and still say why the task changed
+func revisionAction(ctx context.Context, fallback models.RevisionAction) models.RevisionAction {
+	if action, ok := ctx.Value(revisionActionContextKey{}).(models.RevisionAction); ok {
+		return action
+	}
+	return fallback
+}
+
+// recordRevision appends the task as it is now stored to its history. It
+// must run in the transaction that made the change.
+func (q *Queries) recordRevision(ctx context.Context, id string, action models.RevisionAction) error {
+	t, err := q.GetTask(ctx, id)
+	if err != nil {
+		return err
+	}
+	snapshot, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	_, err = q.db.ExecContext(ctx, insertRevisionSQL, id, action, string(snapshot), actorFromContext(ctx), time.Now().UTC(), id)
+	return err
+}
+
+func scanRevision(row rowScanner) (*models.Revision, error) {
+	var r models.Revision
+	var snapshot string
+	if err := row.Scan(&r.TaskID, &r.Revision, &r.Action, &snapshot, &r.Actor, &r.CreatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(snapshot), &r.Task); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// ListRevisions returns a task's history, oldest first
+func (q *Queries) ListRevisions(ctx context.Context, taskID string) ([]*models.Revision, error) {
+	rows, err := q.db.QueryContext(ctx, listRevisionsSQL, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revisions []*models.Revision
+	for rows.Next() {
+		r, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, r)
+	}
+	return revisions, rows.Err()
+}
+
+// GetRevision returns one revision of a task
+func (q *Queries) GetRevision(ctx context.Context, taskID string, revision int) (*models.Revision, error) {
+	r, err := scanRevision(q.db.QueryRowContext(ctx, getRevisionSQL, taskID, revision))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return r, nil
+}
+
+// RevertTask puts a task's title, times and recurrence back to how they were
+// at revision, keeping its current status, and records that as a new
+// revision. The overlap check applies as for any update.
+func (q *Queries) RevertTask(ctx context.Context, taskID string, revision int) (*models.Task, error) {
+	var reverted *models.Task
+	err := q.InTx(ctx, func(q *Queries) error {
+		current, err := q.GetTask(ctx, taskID)
+		if err != nil {
+			return err
+		}
+		r, err := q.GetRevision(ctx, taskID, revision)
+		if err != nil {
+			return err
+		}
+
+		t := *current
+		t.Title, t.Start, t.End, t.Recurrence, t.Timezone = r.Task.Title, r.Task.Start, r.Task.End, r.Task.Recurrence, r.Task.Timezone
+		ctx := context.WithValue(ctx, revisionActionContextKey{}, models.RevisionRevert)
+		if err := q.UpdateTask(ctx, t); err != nil {
+			return err
+		}
+		reverted, err = q.GetTask(ctx, taskID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reverted, nil
+}
@@ -0,0 +1,81 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Adjanour/vesper/internal/models"
+)
+
+const (
+	listSyncLinksSQL = `
+	SELECT task_id, user_id, calendar_id, remote_id, etag, content_hash, synced_at
+	FROM calendar_sync_links
+	WHERE user_id = ? AND calendar_id = ?
+	`
+	upsertSyncLinkSQL = `
+	INSERT INTO calendar_sync_links (task_id, user_id, calendar_id, remote_id, etag, content_hash, synced_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT (task_id) DO UPDATE
+	SET remote_id = excluded.remote_id, etag = excluded.etag,
+	    content_hash = excluded.content_hash, synced_at = excluded.synced_at
+	`
+	deleteSyncLinkSQL = `DELETE FROM calendar_sync_links WHERE task_id = ?`
+	getSyncTokenSQL   = `SELECT sync_token FROM calendar_sync_state WHERE user_id = ? AND calendar_id = ?`
+	setSyncTokenSQL   = `
+	INSERT INTO calendar_sync_state (user_id, calendar_id, sync_token, synced_at)
+	VALUES (?, ?, ?, ?)
+	ON CONFLICT (user_id, calendar_id) DO UPDATE
+	SET sync_token = excluded.sync_token, synced_at = excluded.synced_at
+	`
+)
+
+// ListSyncLinks returns every task-to-event link of a user for one calendar
+func (q *Queries) ListSyncLinks(ctx context.Context, userID, calendarID string) ([]models.SyncLink, error) {
+	rows, err := q.db.QueryContext(ctx, listSyncLinksSQL, userID, calendarID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var links []models.SyncLink
+	for rows.Next() {
+		var l models.SyncLink
+		if err := rows.Scan(&l.TaskID, &l.UserID, &l.CalendarID, &l.RemoteID, &l.ETag, &l.ContentHash, &l.SyncedAt); err != nil {
+			return nil, err
+		}
+		links = append(links, l)
+	}
+	return links, rows.Err()
+}
+
+// UpsertSyncLink records or refreshes the remote event behind a task
+func (q *Queries) UpsertSyncLink(ctx context.Context, l models.SyncLink) error {
+	_, err := q.db.ExecContext(ctx, upsertSyncLinkSQL,
+		l.TaskID, l.UserID, l.CalendarID, l.RemoteID, l.ETag, l.ContentHash, l.SyncedAt.UTC())
+	return err
+}
+
+// DeleteSyncLink forgets the remote event behind a task
+func (q *Queries) DeleteSyncLink(ctx context.Context, taskID string) error {
+	_, err := q.db.ExecContext(ctx, deleteSyncLinkSQL, taskID)
+	return err
+}
+
+// GetSyncToken returns the stored incremental sync token, or "" before the first full sync
+func (q *Queries) GetSyncToken(ctx context.Context, userID, calendarID string) (string, error) {
+	var token string
+	err := q.db.QueryRowContext(ctx, getSyncTokenSQL, userID, calendarID).Scan(&token)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return token, err
+}
+
+// SetSyncToken stores the token to resume incremental sync from
+func (q *Queries) SetSyncToken(ctx context.Context, userID, calendarID, token string) error {
+	_, err := q.db.ExecContext(ctx, setSyncTokenSQL, userID, calendarID, token, time.Now().UTC())
+	return err
+}
@@ -0,0 +1,113 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Adjanour/vesper/internal/models"
+)
+
+// lastUsedResolution limits how often a busy token rewrites last_used_at
+const lastUsedResolution = time.Minute
+
+const (
+	apiTokenColumns   = `id, user_id, name, scopes, created_at, expires_at, last_used_at`
+	createAPITokenSQL = `
+	INSERT INTO api_tokens (id, user_id, name, token_hash, scopes, created_at, expires_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+	listAPITokensSQL     = `SELECT ` + apiTokenColumns + ` FROM api_tokens WHERE user_id = ? ORDER BY created_at, id`
+	getAPITokenByHashSQL = `SELECT ` + apiTokenColumns + ` FROM api_tokens WHERE token_hash = ?`
+	deleteAPITokenSQL    = `DELETE FROM api_tokens WHERE id = ? AND user_id = ?`
+	touchAPITokenSQL     = `
+	UPDATE api_tokens SET last_used_at = ?
+	WHERE id = ? AND (last_used_at IS NULL OR last_used_at < ?)
+	`
+)
+
+func scanAPIToken(row rowScanner) (*models.APIToken, error) {
+	var t models.APIToken
+	var scopes string
+	var expires, lastUsed sql.NullTime
+	if err := row.Scan(&t.ID, &t.UserID, &t.Name, &scopes, &t.CreatedAt, &expires, &lastUsed); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	for _, s := range strings.Fields(scopes) {
+		t.Scopes = append(t.Scopes, models.Scope(s))
+	}
+	if expires.Valid {
+		t.ExpiresAt = &expires.Time
+	}
+	if lastUsed.Valid {
+		t.LastUsedAt = &lastUsed.Time
+	}
+	return &t, nil
+}
+
+// CreateAPIToken stores a token under the hash of its secret
+func (q *Queries) CreateAPIToken(ctx context.Context, t models.APIToken, tokenHash string) error {
+	scopes := make([]string, len(t.Scopes))
+	for i, s := range t.Scopes {
+		scopes[i] = string(s)
+	}
+	var expires any
+	if t.ExpiresAt != nil {
+		expires = t.ExpiresAt.UTC()
+	}
+	_, err := q.db.ExecContext(ctx, createAPITokenSQL,
+		t.ID, t.UserID, t.Name, tokenHash, strings.Join(scopes, " "), t.CreatedAt.UTC(), expires)
+	return err
+}
+
+// ListAPITokens returns a user's tokens, oldest first
+func (q *Queries) ListAPITokens(ctx context.Context, userID string) ([]*models.APIToken, error) {
+	rows, err := q.db.QueryContext(ctx, listAPITokensSQL, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []*models.APIToken{}
+	for rows.Next() {
+		t, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+// GetAPITokenByHash finds the token whose secret hashes to tokenHash
+func (q *Queries) GetAPITokenByHash(ctx context.Context, tokenHash string) (*models.APIToken, error) {
+	return scanAPIToken(q.db.QueryRowContext(ctx, getAPITokenByHashSQL, tokenHash))
+}
+
+// DeleteAPIToken revokes one of a user's tokens
+func (q *Queries) DeleteAPIToken(ctx context.Context, id, userID string) error {
+	res, err := q.db.ExecContext(ctx, deleteAPITokenSQL, id, userID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// TouchAPIToken records a use of the token, at most once per lastUsedResolution
+func (q *Queries) TouchAPIToken(ctx context.Context, id string, now time.Time) error {
+	now = now.UTC()
+	_, err := q.db.ExecContext(ctx, touchAPITokenSQL, now, id, now.Add(-lastUsedResolution))
+	return err
+}
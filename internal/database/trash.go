@@ -0,0 +1,129 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Adjanour/vesper/internal/models"
+)
+
+const (
+	listTrashSQL = `
+	SELECT ` + taskColumns + `
+	FROM tasks
+	` + joinRecurrences + `
+	WHERE tasks.user_id = ? AND tasks.status = ?
+	ORDER BY tasks.deleted_at DESC, tasks.id
+	`
+	restoreTaskSQL = `
+	UPDATE tasks
+	SET status = ?, deleted_at = NULL, version = version + 1
+	WHERE id = ? AND status = ?
+	`
+	// the purge statements share one selection of trashed tasks, written in
+	// terms of the outer query's placeholders
+	trashedTaskSQL = `SELECT id FROM tasks WHERE status = ? AND `
+)
+
+// purgeStatements remove everything kept for a trashed task, then the task.
+// Links from other tasks are cleared since foreign keys are not enforced.
+var purgeStatements = []string{
+	`DELETE FROM task_occurrence_overrides WHERE task_id IN (` + trashedTaskSQL + `%s)`,
+	`DELETE FROM task_recurrences WHERE task_id IN (` + trashedTaskSQL + `%s)`,
+	`DELETE FROM task_revisions WHERE task_id IN (` + trashedTaskSQL + `%s)`,
+	`UPDATE tasks SET replaces = NULL, version = version + 1 WHERE replaces IN (` + trashedTaskSQL + `%s)`,
+	`UPDATE tasks SET replaced_by = NULL, version = version + 1 WHERE replaced_by IN (` + trashedTaskSQL + `%s)`,
+	`DELETE FROM tasks WHERE id IN (` + trashedTaskSQL + `%s)`,
+}
+
+// ListTrash returns a user's deleted tasks, most recently deleted first
+func (q *Queries) ListTrash(ctx context.Context, userID string) ([]*models.Task, error) {
+	rows, err := q.db.QueryContext(ctx, listTrashSQL, userID, models.StatusDeleted)
+	if err != nil {
+		return nil, err
+	}
+	return scanTasks(rows)
+}
+
+// RestoreTask takes a task out of the trash. It becomes scheduled again,
+// unless it had been replaced before it was deleted, so it must not overlap
+// any of the user's scheduled blocks.
+func (q *Queries) RestoreTask(ctx context.Context, id string) (*models.Task, error) {
+	var restored *models.Task
+	err := q.InTx(ctx, func(q *Queries) error {
+		t, err := q.GetTask(ctx, id)
+		if err != nil {
+			return err
+		}
+		if t.Status != models.StatusDeleted {
+			return ErrNotFound
+		}
+
+		t.Status, t.DeletedAt = models.StatusScheduled, nil
+		if t.ReplacedBy != "" {
+			t.Status = models.StatusReplaced
+		} else {
+			rule, err := parseRecurrence(t.Recurrence)
+			if err != nil {
+				return err
+			}
+			if err := q.checkOverlap(ctx, *t, rule, overlapExclusion{TaskID: t.ID}); err != nil {
+				return err
+			}
+		}
+
+		if _, err := q.db.ExecContext(ctx, restoreTaskSQL, t.Status, id, models.StatusDeleted); err != nil {
+			return err
+		}
+		restored = t
+		return q.recordRevision(ctx, id, models.RevisionRestore)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return restored, nil
+}
+
+// PurgeTask permanently deletes a task that is in the trash
+func (q *Queries) PurgeTask(ctx context.Context, id string) error {
+	return q.InTx(ctx, func(q *Queries) error {
+		purged, err := q.purge(ctx, "id = ?", id)
+		if err != nil {
+			return err
+		}
+		if purged == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
+
+// PurgeTrash permanently deletes every task deleted before cutoff, returning
+// how many were removed
+func (q *Queries) PurgeTrash(ctx context.Context, cutoff time.Time) (int64, error) {
+	var purged int64
+	err := q.InTx(ctx, func(q *Queries) error {
+		var err error
+		purged, err = q.purge(ctx, "deleted_at < ?", cutoff.UTC())
+		return err
+	})
+	return purged, err
+}
+
+// purge runs purgeStatements over the trashed tasks matching where, and
+// reports how many tasks it deleted
+func (q *Queries) purge(ctx context.Context, where string, arg any) (int64, error) {
+	var purged int64
+	for _, statement := range purgeStatements {
+		result, err := q.db.ExecContext(ctx, fmt.Sprintf(statement, where), models.StatusDeleted, arg)
+		if err != nil {
+			return 0, err
+		}
+		// the last statement deletes the tasks themselves
+		if purged, err = result.RowsAffected(); err != nil {
+			return 0, err
+		}
+	}
+	return purged, nil
+}
@@ -0,0 +1,192 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Adjanour/vesper/internal/models"
+)
+
+const (
+	webhookColumns   = `id, user_id, url, events, secret, created_at`
+	createWebhookSQL = `
+	INSERT INTO webhooks (id, user_id, url, events, secret, created_at)
+	VALUES (?, ?, ?, ?, ?, ?)
+	`
+	getWebhookSQL              = `SELECT ` + webhookColumns + ` FROM webhooks WHERE id = ?`
+	listWebhooksSQL            = `SELECT ` + webhookColumns + ` FROM webhooks WHERE user_id = ? ORDER BY created_at, id`
+	deleteWebhookSQL           = `DELETE FROM webhooks WHERE id = ? AND user_id = ?`
+	deleteWebhookDeliveriesSQL = `DELETE FROM webhook_deliveries WHERE webhook_id = ?`
+
+	webhookDeliveryColumns = `id, webhook_id, event_id, event_type, payload, status, attempts,
+	next_attempt_at, response_status, last_error, created_at, delivered_at`
+	createWebhookDeliverySQL = `
+	INSERT INTO webhook_deliveries (id, webhook_id, event_id, event_type, payload, status, attempts, next_attempt_at, created_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	listDueWebhookDeliveriesSQL = `
+	SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries
+	WHERE status = 'pending' AND next_attempt_at <= ?
+	ORDER BY next_attempt_at, id
+	LIMIT ?
+	`
+	listWebhookDeliveriesSQL = `
+	SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries
+	WHERE webhook_id = ?
+	ORDER BY created_at DESC, id DESC
+	LIMIT ?
+	`
+	updateWebhookDeliverySQL = `
+	UPDATE webhook_deliveries
+	SET status = ?, attempts = ?, next_attempt_at = ?, response_status = ?, last_error = ?, delivered_at = ?
+	WHERE id = ?
+	`
+)
+
+func scanWebhook(row rowScanner) (*models.Webhook, error) {
+	var w models.Webhook
+	var events string
+	if err := row.Scan(&w.ID, &w.UserID, &w.URL, &events, &w.Secret, &w.CreatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	w.Events = strings.Fields(events)
+	return &w, nil
+}
+
+// CreateWebhook stores a new webhook subscription
+func (q *Queries) CreateWebhook(ctx context.Context, w models.Webhook) error {
+	_, err := q.db.ExecContext(ctx, createWebhookSQL,
+		w.ID, w.UserID, w.URL, strings.Join(w.Events, " "), w.Secret, w.CreatedAt.UTC())
+	return err
+}
+
+// GetWebhook retrieves a webhook by ID, whoever owns it
+func (q *Queries) GetWebhook(ctx context.Context, id string) (*models.Webhook, error) {
+	return scanWebhook(q.db.QueryRowContext(ctx, getWebhookSQL, id))
+}
+
+// ListWebhooks returns a user's webhooks, oldest first
+func (q *Queries) ListWebhooks(ctx context.Context, userID string) ([]*models.Webhook, error) {
+	rows, err := q.db.QueryContext(ctx, listWebhooksSQL, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hooks := []*models.Webhook{}
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, w)
+	}
+	return hooks, rows.Err()
+}
+
+// DeleteWebhook removes one of a user's webhooks along with its delivery log
+func (q *Queries) DeleteWebhook(ctx context.Context, id, userID string) error {
+	return q.InTx(ctx, func(tx *Queries) error {
+		res, err := tx.db.ExecContext(ctx, deleteWebhookSQL, id, userID)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return ErrNotFound
+		}
+		_, err = tx.db.ExecContext(ctx, deleteWebhookDeliveriesSQL, id)
+		return err
+	})
+}
+
+func scanWebhookDelivery(row rowScanner) (*models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	var payload string
+	var next, delivered sql.NullTime
+	var responseStatus sql.NullInt64
+	var lastError sql.NullString
+	err := row.Scan(&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &payload, &d.Status, &d.Attempts,
+		&next, &responseStatus, &lastError, &d.CreatedAt, &delivered)
+	if err != nil {
+		return nil, err
+	}
+	d.Payload = []byte(payload)
+	if next.Valid {
+		d.NextAttemptAt = &next.Time
+	}
+	if delivered.Valid {
+		d.DeliveredAt = &delivered.Time
+	}
+	d.ResponseStatus = int(responseStatus.Int64)
+	d.LastError = lastError.String
+	return &d, nil
+}
+
+func (q *Queries) listWebhookDeliveries(ctx context.Context, query string, args ...any) ([]*models.WebhookDelivery, error) {
+	rows, err := q.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []*models.WebhookDelivery{}
+	for rows.Next() {
+		d, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// CreateWebhookDelivery queues a delivery
+func (q *Queries) CreateWebhookDelivery(ctx context.Context, d models.WebhookDelivery) error {
+	var next any
+	if d.NextAttemptAt != nil {
+		next = d.NextAttemptAt.UTC()
+	}
+	_, err := q.db.ExecContext(ctx, createWebhookDeliverySQL,
+		d.ID, d.WebhookID, d.EventID, d.EventType, string(d.Payload), d.Status, d.Attempts, next, d.CreatedAt.UTC())
+	return err
+}
+
+// ListDueWebhookDeliveries returns up to limit pending deliveries whose next attempt is due at now
+func (q *Queries) ListDueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]*models.WebhookDelivery, error) {
+	return q.listWebhookDeliveries(ctx, listDueWebhookDeliveriesSQL, now.UTC(), limit)
+}
+
+// ListWebhookDeliveries returns a webhook's most recent deliveries, newest first
+func (q *Queries) ListWebhookDeliveries(ctx context.Context, webhookID string, limit int) ([]*models.WebhookDelivery, error) {
+	return q.listWebhookDeliveries(ctx, listWebhookDeliveriesSQL, webhookID, limit)
+}
+
+// UpdateWebhookDelivery records the outcome of a delivery attempt
+func (q *Queries) UpdateWebhookDelivery(ctx context.Context, d models.WebhookDelivery) error {
+	var next, delivered, responseStatus, lastError any
+	if d.NextAttemptAt != nil {
+		next = d.NextAttemptAt.UTC()
+	}
+	if d.DeliveredAt != nil {
+		delivered = d.DeliveredAt.UTC()
+	}
+	if d.ResponseStatus != 0 {
+		responseStatus = d.ResponseStatus
+	}
+	if d.LastError != "" {
+		lastError = d.LastError
+	}
+	_, err := q.db.ExecContext(ctx, updateWebhookDeliverySQL,
+		d.Status, d.Attempts, next, responseStatus, lastError, delivered, d.ID)
+	return err
+}
@@ -0,0 +1,101 @@
+// Package email delivers Vesper's notification emails through a pluggable transport.
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/textproto"
+	"time"
+)
+
+// Message is a single email with plain-text and, optionally, HTML bodies
+type Message struct {
+	From    string
+	To      []string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Transport hands a message to a mail system
+type Transport interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Mailer renders and sends messages, retrying transient transport failures
+// with exponential backoff.
+type Mailer struct {
+	transport Transport
+	from      string
+
+	// Attempts is the total number of tries per message.
+	Attempts int
+	// Backoff is the wait before the first retry; it doubles after each one up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// NewMailer creates a mailer sending from the given address
+func NewMailer(t Transport, from string) *Mailer {
+	return &Mailer{
+		transport:  t,
+		from:       from,
+		Attempts:   4,
+		Backoff:    2 * time.Second,
+		MaxBackoff: time.Minute,
+	}
+}
+
+// Send delivers msg, retrying until it succeeds, fails permanently, runs out
+// of attempts or ctx is cancelled.
+func (m *Mailer) Send(ctx context.Context, msg Message) error {
+	if msg.From == "" {
+		msg.From = m.from
+	}
+	if len(msg.To) == 0 {
+		return errors.New("email: message has no recipients")
+	}
+
+	wait := m.Backoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = m.transport.Send(ctx, msg)
+		if err == nil || isPermanent(err) || attempt >= m.Attempts {
+			break
+		}
+		slog.WarnContext(ctx, "email delivery failed, retrying", "attempt", attempt, "to", msg.To, "retry_in", wait, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		wait *= 2
+		if m.MaxBackoff > 0 && wait > m.MaxBackoff {
+			wait = m.MaxBackoff
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("email: send to %v: %w", msg.To, err)
+	}
+	return nil
+}
+
+// SendTemplate renders the named template with data and sends it to the given recipients
+func (m *Mailer) SendTemplate(ctx context.Context, name string, data any, to ...string) error {
+	msg, err := Render(name, data)
+	if err != nil {
+		return err
+	}
+	msg.To = to
+	return m.Send(ctx, msg)
+}
+
+// isPermanent reports whether retrying err cannot help, e.g. a 5xx SMTP
+// reply rejecting the recipient.
+func isPermanent(err error) bool {
+	var smtpErr *textproto.Error
+	return errors.As(err, &smtpErr) && smtpErr.Code >= 500
+}
@@ -0,0 +1,200 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Adjanour/vesper/internal/models"
+	"github.com/Adjanour/vesper/internal/planning"
+)
+
+// fakeSMTP is a minimal plain SMTP server, like the MailHog stand-in used in development
+type fakeSMTP struct {
+	addr     string
+	received chan string
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	s := &fakeSMTP{addr: ln.Addr().String(), received: make(chan string, 1)}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 fake ESMTP")
+
+	var session strings.Builder
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		switch strings.ToUpper(strings.Fields(line)[0]) {
+		case "EHLO", "HELO":
+			tp.PrintfLine("250 fake")
+		case "MAIL", "RCPT":
+			session.WriteString(line + "\n")
+			tp.PrintfLine("250 OK")
+		case "DATA":
+			tp.PrintfLine("354 go ahead")
+			data, _ := io.ReadAll(tp.DotReader())
+			session.Write(data)
+			tp.PrintfLine("250 queued")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			s.received <- session.String()
+			return
+		default:
+			tp.PrintfLine("502 unsupported")
+		}
+	}
+}
+
+func TestSMTPTransportPlainServer(t *testing.T) {
+	server := newFakeSMTP(t)
+	transport := &SMTPTransport{Addr: server.addr, TLS: TLSOpportunistic, Timeout: 5 * time.Second}
+
+	msg := Message{
+		From:    "Vesper <noreply@vesper.test>",
+		To:      []string{"ada@example.com"},
+		Subject: "Plan your day",
+		Text:    "plain body",
+		HTML:    "<p>html body</p>",
+	}
+	if err := transport.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	got := <-server.received
+	for _, want := range []string{
+		"MAIL FROM:<noreply@vesper.test>",
+		"RCPT TO:<ada@example.com>",
+		"Subject: Plan your day",
+		"multipart/alternative",
+		"plain body",
+		"<p>html body</p>",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected session to contain %q, got:\n%s", want, got)
+		}
+	}
+}
+
+type flakyTransport struct {
+	failures int
+	err      error
+	calls    int
+}
+
+func (f *flakyTransport) Send(context.Context, Message) error {
+	f.calls++
+	if f.calls <= f.failures {
+		return f.err
+	}
+	return nil
+}
+
+func TestMailerRetries(t *testing.T) {
+	msg := Message{To: []string{"ada@example.com"}, Subject: "hi", Text: "hi"}
+
+	transient := &flakyTransport{failures: 2, err: errors.New("connection refused")}
+	m := NewMailer(transient, "noreply@vesper.test")
+	m.Backoff = time.Millisecond
+	if err := m.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Expected success after retries, got %v", err)
+	}
+	if transient.calls != 3 {
+		t.Errorf("Expected 3 attempts, got %d", transient.calls)
+	}
+
+	permanent := &flakyTransport{failures: 10, err: &textproto.Error{Code: 550, Msg: "no such user"}}
+	m = NewMailer(permanent, "noreply@vesper.test")
+	m.Backoff = time.Millisecond
+	if err := m.Send(context.Background(), msg); err == nil {
+		t.Fatal("Expected permanent failure")
+	}
+	if permanent.calls != 1 {
+		t.Errorf("Expected a 5xx reply not to be retried, got %d attempts", permanent.calls)
+	}
+
+	exhausted := &flakyTransport{failures: 10, err: errors.New("timeout")}
+	m = NewMailer(exhausted, "noreply@vesper.test")
+	m.Backoff = time.Millisecond
+	if err := m.Send(context.Background(), msg); err == nil {
+		t.Fatal("Expected failure after all attempts")
+	}
+	if exhausted.calls != m.Attempts {
+		t.Errorf("Expected %d attempts, got %d", m.Attempts, exhausted.calls)
+	}
+}
+
+func TestRenderPlanningLink(t *testing.T) {
+	london, _ := time.LoadLocation("Europe/London")
+	notice := planning.Notice{
+		Username:  "ada",
+		PlanDate:  "2025-06-02",
+		Link:      "http://vesper.test/?plan=abc&expires=1&sig=x",
+		ExpiresAt: time.Date(2025, 6, 2, 20, 0, 0, 0, time.UTC),
+		Location:  london,
+		Agenda: []models.Task{{
+			Title: "Standup <daily>",
+			Start: time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC),
+			End:   time.Date(2025, 6, 2, 8, 30, 0, 0, time.UTC),
+		}},
+	}
+
+	msg, err := Render("planning_link", notice)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if msg.Subject != "Plan your day: Monday, 2 June 2025" {
+		t.Errorf("Unexpected subject %q", msg.Subject)
+	}
+	if !strings.Contains(msg.Text, "09:00-09:30  Standup <daily>") {
+		t.Errorf("Expected local agenda times in text body, got:\n%s", msg.Text)
+	}
+	if !strings.Contains(msg.HTML, "Standup &lt;daily&gt;") {
+		t.Errorf("Expected escaped title in HTML body, got:\n%s", msg.HTML)
+	}
+	if !strings.Contains(msg.HTML, `href="http://vesper.test/?plan=abc&amp;expires=1&amp;sig=x"`) {
+		t.Errorf("Expected planning link in HTML body, got:\n%s", msg.HTML)
+	}
+}
+
+func TestRenderPasswordChanged(t *testing.T) {
+	msg, err := Render("password_changed", PasswordChange{Username: "ada", ChangedAt: time.Date(2025, 6, 2, 20, 15, 0, 0, time.UTC)})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if msg.Subject != "Your Vesper password was changed" {
+		t.Errorf("Unexpected subject %q", msg.Subject)
+	}
+	for _, body := range []string{msg.Text, msg.HTML} {
+		if !strings.Contains(body, "ada") || !strings.Contains(body, "2025-06-02 20:15 UTC") {
+			t.Errorf("Expected the user and time of the change, got:\n%s", body)
+		}
+	}
+}
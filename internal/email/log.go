@@ -0,0 +1,31 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// LogTransport prints messages instead of sending them, for development
+type LogTransport struct {
+	// W receives the messages; nil means stdout.
+	W  io.Writer
+	mu sync.Mutex
+}
+
+// Send implements Transport
+func (t *LogTransport) Send(_ context.Context, msg Message) error {
+	w := t.W
+	if w == nil {
+		w = os.Stdout
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, err := fmt.Fprintf(w, "----- email -----\nFrom: %s\nTo: %s\nSubject: %s\n\n%s\n-----------------\n",
+		msg.From, strings.Join(msg.To, ", "), msg.Subject, msg.Text)
+	return err
+}
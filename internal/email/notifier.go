@@ -0,0 +1,61 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/Adjanour/vesper/internal/database"
+	"github.com/Adjanour/vesper/internal/models"
+	"github.com/Adjanour/vesper/internal/planning"
+)
+
+// PlanningNotifier emails nightly planning links to each user's notification address
+type PlanningNotifier struct {
+	Mailer *Mailer
+	DB     *database.Queries
+	// Fallback receives notices for users without an address; nil drops them.
+	Fallback planning.Notifier
+}
+
+// Notify implements planning.Notifier
+func (n *PlanningNotifier) Notify(ctx context.Context, notice planning.Notice) error {
+	to, err := n.DB.GetUserEmail(ctx, notice.UserID)
+	if errors.Is(err, database.ErrNotFound) {
+		if n.Fallback == nil {
+			slog.WarnContext(ctx, "no email address, planning link not sent", "user_id", notice.UserID)
+			return nil
+		}
+		return n.Fallback.Notify(ctx, notice)
+	}
+	if err != nil {
+		return err
+	}
+	return n.Mailer.SendTemplate(ctx, "planning_link", notice, to)
+}
+
+// AccountNotifier emails users about security-relevant changes to their account
+type AccountNotifier struct {
+	Mailer *Mailer
+	DB     *database.Queries
+}
+
+// PasswordChange is the data behind the password_changed message
+type PasswordChange struct {
+	Username  string
+	ChangedAt time.Time
+}
+
+// PasswordChanged tells the user their password was changed; users without
+// an address are skipped
+func (n *AccountNotifier) PasswordChanged(ctx context.Context, user models.User, at time.Time) error {
+	to, err := n.DB.GetUserEmail(ctx, user.ID)
+	if errors.Is(err, database.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return n.Mailer.SendTemplate(ctx, "password_changed", PasswordChange{Username: user.Username, ChangedAt: at}, to)
+}
@@ -0,0 +1,114 @@
+package email
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/smtp"
+	"time"
+)
+
+// TLSMode controls how the SMTP transport secures its connection
+type TLSMode string
+
+const (
+	// TLSOpportunistic upgrades with STARTTLS when the server offers it.
+	TLSOpportunistic TLSMode = "opportunistic"
+	// TLSStartTLS requires STARTTLS.
+	TLSStartTLS TLSMode = "starttls"
+	// TLSImplicit connects over TLS from the start (SMTPS, usually port 465).
+	TLSImplicit TLSMode = "implicit"
+	// TLSNone never uses TLS, as with local SMTP stand-ins such as MailHog.
+	TLSNone TLSMode = "none"
+)
+
+// SMTPTransport sends messages through an SMTP relay
+type SMTPTransport struct {
+	// Addr is the relay's host:port.
+	Addr string
+	// Username and Password enable AUTH PLAIN; leave empty for open relays.
+	Username string
+	Password string
+	TLS      TLSMode
+	// Timeout bounds a whole delivery, from dial to QUIT.
+	Timeout time.Duration
+}
+
+// Send implements Transport
+func (t *SMTPTransport) Send(ctx context.Context, msg Message) error {
+	body, err := msg.Bytes(time.Now())
+	if err != nil {
+		return err
+	}
+
+	timeout := t.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	host, _, err := net.SplitHostPort(t.Addr)
+	if err != nil {
+		return err
+	}
+	tlsConfig := &tls.Config{ServerName: host}
+
+	var conn net.Conn
+	dialer := &net.Dialer{}
+	if t.TLS == TLSImplicit {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", t.Addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", t.Addr)
+	}
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if t.TLS != TLSImplicit && t.TLS != TLSNone {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err := c.StartTLS(tlsConfig); err != nil {
+				return err
+			}
+		} else if t.TLS == TLSStartTLS {
+			return errors.New("smtp: server does not support STARTTLS")
+		}
+	}
+
+	if t.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", t.Username, t.Password, host)); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(addressOf(msg.From)); err != nil {
+		return err
+	}
+	for _, rcpt := range msg.To {
+		if err := c.Rcpt(addressOf(rcpt)); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
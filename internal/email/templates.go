@@ -0,0 +1,66 @@
+package email
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// Each message NAME has a templates/NAME.txt body, which also defines the
+// "subject" template, and an optional templates/NAME.html body.
+//
+//go:embed templates
+var templateFS embed.FS
+
+var templateFuncs = map[string]any{
+	// clock formats t as a wall-clock time in loc (UTC when loc is nil)
+	"clock": func(t time.Time, loc *time.Location) string {
+		if loc == nil {
+			loc = time.UTC
+		}
+		return t.In(loc).Format("15:04")
+	},
+	// longDate formats a YYYY-MM-DD date as e.g. "Tuesday, 2 June 2025"
+	"longDate": func(s string) string {
+		d, err := time.Parse(time.DateOnly, s)
+		if err != nil {
+			return s
+		}
+		return d.Format("Monday, 2 January 2006")
+	},
+}
+
+// Render builds the subject and bodies of the named message from data
+func Render(name string, data any) (Message, error) {
+	text, err := texttemplate.New(name+".txt").Funcs(templateFuncs).ParseFS(templateFS, "templates/"+name+".txt")
+	if err != nil {
+		return Message{}, fmt.Errorf("email: template %s: %w", name, err)
+	}
+
+	var subject, body bytes.Buffer
+	if err := text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, fmt.Errorf("email: template %s: subject: %w", name, err)
+	}
+	if err := text.Execute(&body, data); err != nil {
+		return Message{}, fmt.Errorf("email: template %s: %w", name, err)
+	}
+	msg := Message{Subject: strings.TrimSpace(subject.String()), Text: body.String()}
+
+	if _, err := templateFS.Open("templates/" + name + ".html"); err != nil {
+		return msg, nil // plain-text only
+	}
+	html, err := htmltemplate.New(name+".html").Funcs(templateFuncs).ParseFS(templateFS, "templates/"+name+".html")
+	if err != nil {
+		return Message{}, fmt.Errorf("email: template %s: %w", name, err)
+	}
+	var htmlBody bytes.Buffer
+	if err := html.Execute(&htmlBody, data); err != nil {
+		return Message{}, fmt.Errorf("email: template %s: html: %w", name, err)
+	}
+	msg.HTML = htmlBody.String()
+	return msg, nil
+}
@@ -0,0 +1,169 @@
+// Package events fans task changes out to the clients watching them.
+package events
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Adjanour/vesper/internal/models"
+)
+
+// Type names what happened to a task
+type Type string
+
+const (
+	TaskCreated Type = "task.created"
+	TaskUpdated Type = "task.updated"
+	TaskDeleted Type = "task.deleted"
+)
+
+// Types lists every event type
+var Types = []Type{TaskCreated, TaskUpdated, TaskDeleted}
+
+const (
+	// DefaultHistory is how many recent events a broker keeps for resuming clients.
+	DefaultHistory = 1024
+	// subscriberBuffer is how far a subscriber may fall behind before it is dropped.
+	subscriberBuffer = 64
+)
+
+// Event is one change to one of a user's tasks
+type Event struct {
+	// ID is "<epoch>-<sequence>"; the epoch changes on every restart.
+	ID     string       `json:"id"`
+	Type   Type         `json:"type"`
+	UserID string       `json:"-"`
+	TaskID string       `json:"task_id"`
+	Task   *models.Task `json:"task,omitempty"`
+	Time   time.Time    `json:"time"`
+
+	seq uint64
+}
+
+// Broker is an in-process publish/subscribe hub for task events. It keeps a
+// bounded history so a reconnecting client can resume from its last event ID.
+type Broker struct {
+	mu      sync.Mutex
+	epoch   string
+	seq     uint64
+	history []Event
+	limit   int
+	subs    map[*subscription]struct{}
+	closed  bool
+}
+
+type subscription struct {
+	userID string
+	ch     chan Event
+}
+
+// NewBroker creates a broker remembering the last history events
+func NewBroker(history int) *Broker {
+	return &Broker{
+		epoch: strconv.FormatInt(time.Now().UnixNano(), 36),
+		limit: history,
+		subs:  make(map[*subscription]struct{}),
+	}
+}
+
+// Publish assigns e its ID and delivers it to the owner's subscribers.
+// Subscribers that have fallen too far behind are disconnected rather than
+// allowed to block publishers; they resume through Last-Event-ID.
+func (b *Broker) Publish(e Event) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	e.seq = b.seq
+	e.ID = fmt.Sprintf("%s-%d", b.epoch, e.seq)
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+
+	b.history = append(b.history, e)
+	if len(b.history) > b.limit {
+		b.history = b.history[len(b.history)-b.limit:]
+	}
+
+	for s := range b.subs {
+		if s.userID != e.UserID {
+			continue
+		}
+		select {
+		case s.ch <- e:
+		default:
+			delete(b.subs, s)
+			close(s.ch)
+		}
+	}
+	return e
+}
+
+// Subscribe starts delivering the user's events. When lastEventID is set, the
+// events the client missed since then are returned for replay; complete is
+// false when they are no longer all in the history (or the server restarted),
+// in which case the client should reload its state. The channel is closed
+// when cancel is called or the subscriber falls behind.
+func (b *Broker) Subscribe(userID, lastEventID string) (missed []Event, complete bool, ch <-chan Event, cancel func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	complete = true
+	if lastEventID != "" {
+		missed, complete = b.since(userID, lastEventID)
+	}
+
+	s := &subscription{userID: userID, ch: make(chan Event, subscriberBuffer)}
+	if b.closed {
+		close(s.ch)
+		return missed, complete, s.ch, func() {}
+	}
+	b.subs[s] = struct{}{}
+
+	cancel = func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subs[s]; ok {
+			delete(b.subs, s)
+			close(s.ch)
+		}
+	}
+	return missed, complete, s.ch, cancel
+}
+
+// Close disconnects every subscriber, and any that subscribe later, so
+// streaming handlers return while the server shuts down. Publishing after
+// Close is harmless.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for s := range b.subs {
+		delete(b.subs, s)
+		close(s.ch)
+	}
+}
+
+// since returns the user's events after lastEventID; the caller holds b.mu
+func (b *Broker) since(userID, lastEventID string) ([]Event, bool) {
+	epoch, seqStr, ok := strings.Cut(lastEventID, "-")
+	seq, err := strconv.ParseUint(seqStr, 10, 64)
+	if !ok || err != nil || epoch != b.epoch || seq > b.seq {
+		return nil, false
+	}
+
+	// everything after seq must still be in the history to resume without gaps
+	complete := seq == b.seq || (len(b.history) > 0 && b.history[0].seq <= seq+1)
+
+	var missed []Event
+	for _, e := range b.history {
+		if e.seq > seq && e.UserID == userID {
+			missed = append(missed, e)
+		}
+	}
+	return missed, complete
+}
@@ -0,0 +1,90 @@
+package events
+
+import (
+	"testing"
+)
+
+func TestBrokerDeliversToOwner(t *testing.T) {
+	b := NewBroker(DefaultHistory)
+	_, _, mine, cancel := b.Subscribe("u1", "")
+	defer cancel()
+	_, _, theirs, cancelTheirs := b.Subscribe("u2", "")
+	defer cancelTheirs()
+
+	b.Publish(Event{Type: TaskCreated, UserID: "u1", TaskID: "a"})
+
+	select {
+	case e := <-mine:
+		if e.TaskID != "a" || e.ID == "" {
+			t.Errorf("Unexpected event %+v", e)
+		}
+	default:
+		t.Fatal("Expected the owner to receive the event")
+	}
+	select {
+	case e := <-theirs:
+		t.Errorf("Expected other users not to receive the event, got %+v", e)
+	default:
+	}
+}
+
+func TestBrokerResume(t *testing.T) {
+	b := NewBroker(3)
+	first := b.Publish(Event{Type: TaskCreated, UserID: "u1", TaskID: "a"})
+	b.Publish(Event{Type: TaskCreated, UserID: "u2", TaskID: "x"})
+	b.Publish(Event{Type: TaskUpdated, UserID: "u1", TaskID: "a"})
+
+	missed, complete, _, cancel := b.Subscribe("u1", first.ID)
+	cancel()
+	if !complete || len(missed) != 1 || missed[0].Type != TaskUpdated {
+		t.Fatalf("Expected one missed update, got complete=%v %+v", complete, missed)
+	}
+
+	b.Publish(Event{Type: TaskDeleted, UserID: "u1", TaskID: "a"})
+	b.Publish(Event{Type: TaskCreated, UserID: "u1", TaskID: "b"})
+	if _, complete, _, cancel := b.Subscribe("u1", first.ID); complete {
+		t.Error("Expected resume past the history limit to be incomplete")
+	} else {
+		cancel()
+	}
+
+	if _, complete, _, cancel := b.Subscribe("u1", "stale-1"); complete {
+		t.Error("Expected an ID from another server run to be incomplete")
+	} else {
+		cancel()
+	}
+}
+
+func TestBrokerDropsSlowSubscribers(t *testing.T) {
+	b := NewBroker(DefaultHistory)
+	_, _, ch, cancel := b.Subscribe("u1", "")
+	defer cancel()
+
+	for i := 0; i < subscriberBuffer+1; i++ {
+		b.Publish(Event{Type: TaskUpdated, UserID: "u1", TaskID: "a"})
+	}
+
+	n := 0
+	for range ch {
+		n++
+	}
+	if n != subscriberBuffer {
+		t.Errorf("Expected %d buffered events before the channel closed, got %d", subscriberBuffer, n)
+	}
+}
+
+func TestBrokerClose(t *testing.T) {
+	b := NewBroker(DefaultHistory)
+	_, _, ch, cancel := b.Subscribe("u1", "")
+	defer cancel()
+
+	b.Close()
+	if _, ok := <-ch; ok {
+		t.Error("Expected Close to close open subscriptions")
+	}
+	_, _, late, _ := b.Subscribe("u1", "")
+	if _, ok := <-late; ok {
+		t.Error("Expected subscriptions after Close to be closed")
+	}
+	b.Publish(Event{Type: TaskCreated, UserID: "u1"})
+}
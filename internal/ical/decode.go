@@ -0,0 +1,263 @@
+package ical
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNoCalendar is returned when the input holds no VCALENDAR object
+var ErrNoCalendar = errors.New("no VCALENDAR found")
+
+// Decode parses the first VCALENDAR in r. Malformed events do not fail the
+// whole calendar; their Err field records what could not be parsed.
+// Components other than VEVENT (VTIMEZONE, VALARM, VTODO...) are skipped.
+func Decode(r io.Reader) (*Calendar, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		cal        *Calendar
+		stack      []string
+		ev         *Event
+		duration   time.Duration
+		hasEnd     bool
+		dateOnly   bool
+		seenEndCal bool
+	)
+
+	for _, line := range unfold(string(raw)) {
+		name, params, value, err := parseContentLine(line)
+		if err != nil {
+			if ev != nil && ev.Err == nil {
+				ev.Err = err
+			}
+			continue
+		}
+
+		switch name {
+		case "BEGIN":
+			component := strings.ToUpper(value)
+			if component == "VCALENDAR" && cal == nil && len(stack) == 0 {
+				cal = &Calendar{}
+			}
+			if component == "VEVENT" && cal != nil && len(stack) == 1 {
+				ev = &Event{}
+				duration, hasEnd, dateOnly = 0, false, false
+			}
+			stack = append(stack, component)
+			continue
+		case "END":
+			if len(stack) == 0 {
+				continue
+			}
+			component := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if component == "VEVENT" && ev != nil && len(stack) == 1 {
+				finishEvent(ev, hasEnd, duration, dateOnly)
+				cal.Events = append(cal.Events, *ev)
+				ev = nil
+			}
+			if component == "VCALENDAR" && len(stack) == 0 && cal != nil {
+				seenEndCal = true
+			}
+			continue
+		}
+
+		if seenEndCal || cal == nil || len(stack) == 0 {
+			continue
+		}
+
+		switch stack[len(stack)-1] {
+		case "VCALENDAR":
+			switch name {
+			case "PRODID":
+				cal.ProdID = value
+			case "X-WR-CALNAME":
+				cal.Name = unescapeText(value)
+			}
+		case "VEVENT":
+			if ev == nil {
+				continue
+			}
+			var err error
+			switch name {
+			case "UID":
+				ev.UID = value
+			case "SUMMARY":
+				ev.Summary = unescapeText(value)
+			case "STATUS":
+				ev.Status = strings.ToUpper(value)
+			case "RRULE":
+				ev.RRule = value
+			case "DTSTAMP":
+				ev.Stamp, _, err = parseDateTime(value, params)
+			case "DTSTART":
+				ev.Start, dateOnly, err = parseDateTime(value, params)
+				ev.TZID = params["TZID"]
+			case "DTEND":
+				ev.End, _, err = parseDateTime(value, params)
+				hasEnd = true
+			case "DURATION":
+				duration, err = parseDuration(value)
+			case "EXDATE":
+				for _, v := range strings.Split(value, ",") {
+					var d time.Time
+					if d, _, err = parseDateTime(v, params); err != nil {
+						break
+					}
+					ev.ExDates = append(ev.ExDates, d)
+				}
+			case "RECURRENCE-ID":
+				var id time.Time
+				id, _, err = parseDateTime(value, params)
+				ev.RecurrenceID = &id
+			}
+			if err != nil && ev.Err == nil {
+				ev.Err = fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+
+	if cal == nil {
+		return nil, ErrNoCalendar
+	}
+	return cal, nil
+}
+
+func finishEvent(ev *Event, hasEnd bool, duration time.Duration, dateOnly bool) {
+	if hasEnd || ev.Start.IsZero() {
+		return
+	}
+	switch {
+	case duration > 0:
+		ev.End = ev.Start.Add(duration)
+	case dateOnly:
+		// RFC 5545: an all-day event without DTEND lasts one day
+		ev.End = ev.Start.AddDate(0, 0, 1)
+	}
+}
+
+// unfold joins folded content lines and drops blank ones
+func unfold(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\n ", "")
+	s = strings.ReplaceAll(s, "\n\t", "")
+
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimRight(line, "\r"); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// parseContentLine splits "NAME;PARAM=VALUE:value" into its parts.
+// Parameter values may be quoted, and quoted values may contain ':' and ';'.
+func parseContentLine(line string) (string, map[string]string, string, error) {
+	inQuotes := false
+	colon := -1
+	for i, r := range line {
+		if r == '"' {
+			inQuotes = !inQuotes
+		}
+		if r == ':' && !inQuotes {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return "", nil, "", fmt.Errorf("malformed line %q", line)
+	}
+
+	head, value := line[:colon], line[colon+1:]
+	parts := splitUnquoted(head, ';')
+	params := make(map[string]string, len(parts)-1)
+	for _, p := range parts[1:] {
+		k, v, _ := strings.Cut(p, "=")
+		params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+	}
+	return strings.ToUpper(parts[0]), params, value, nil
+}
+
+func splitUnquoted(s string, sep rune) []string {
+	var parts []string
+	inQuotes := false
+	start := 0
+	for i, r := range s {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+		case r == sep && !inQuotes:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// parseDateTime parses a DATE or DATE-TIME value. UTC values end in Z, TZID
+// values are resolved through the system zone database, and floating times
+// are taken as UTC. The bool reports a DATE (all-day) value.
+func parseDateTime(value string, params map[string]string) (time.Time, bool, error) {
+	loc := time.UTC
+	if tzid := params["TZID"]; tzid != "" {
+		l, err := time.LoadLocation(tzid)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("unknown TZID %q", tzid)
+		}
+		loc = l
+	}
+
+	if params["VALUE"] == "DATE" || len(value) == len("20060102") {
+		t, err := time.ParseInLocation("20060102", value, loc)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse(dateTimeLayout, value)
+		return t, false, err
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	return t, false, err
+}
+
+var durationPattern = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// parseDuration parses an RFC 5545 DURATION value such as PT1H30M or P1D
+func parseDuration(value string) (time.Duration, error) {
+	m := durationPattern.FindStringSubmatch(value)
+	if m == nil || value == "P" || strings.HasSuffix(value, "T") {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var d time.Duration
+	for i, unit := range units {
+		if m[i+2] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(m[i+2])
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		d += time.Duration(n) * unit
+	}
+	if m[1] == "-" {
+		d = -d
+	}
+	return d, nil
+}
+
+var textUnescaper = strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+
+// unescapeText reverses escapeText
+func unescapeText(s string) string {
+	return textUnescaper.Replace(s)
+}
@@ -0,0 +1,155 @@
+// Package ical reads and writes the subset of RFC 5545 iCalendar that Vesper
+// exchanges with other calendar tools: VCALENDAR objects holding VEVENTs.
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// dateTimeLayout is the RFC 5545 UTC DATE-TIME form
+const dateTimeLayout = "20060102T150405Z"
+
+// localDateTimeLayout is the DATE-TIME form qualified by a TZID parameter
+const localDateTimeLayout = "20060102T150405"
+
+// maxLineOctets is the content line length limit before folding
+const maxLineOctets = 75
+
+// Event statuses defined by RFC 5545 for VEVENT
+const (
+	StatusTentative = "TENTATIVE"
+	StatusConfirmed = "CONFIRMED"
+	StatusCancelled = "CANCELLED"
+)
+
+// Event is a single VEVENT
+type Event struct {
+	UID     string
+	Summary string
+	Start   time.Time
+	End     time.Time
+	// TZID names the IANA zone the event's times, and so its RRULE, are
+	// written in; empty writes them in UTC.
+	TZID         string
+	Status       string
+	RRule        string
+	ExDates      []time.Time
+	RecurrenceID *time.Time
+	Stamp        time.Time
+	// Err is set by Decode when a property of the event could not be parsed.
+	Err error
+}
+
+// Calendar is a VCALENDAR object
+type Calendar struct {
+	ProdID string
+	Name   string
+	Events []Event
+}
+
+// Encode writes the calendar to w as an iCalendar stream with CRLF line endings.
+func (c *Calendar) Encode(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	e := &encoder{w: bw}
+
+	e.line("BEGIN", "VCALENDAR")
+	e.line("VERSION", "2.0")
+	e.line("PRODID", c.ProdID)
+	e.line("CALSCALE", "GREGORIAN")
+	if c.Name != "" {
+		e.line("X-WR-CALNAME", escapeText(c.Name))
+	}
+	for _, ev := range c.Events {
+		e.line("BEGIN", "VEVENT")
+		e.line("UID", ev.UID)
+		e.line("DTSTAMP", formatDateTime(ev.Stamp))
+		e.dateTime("DTSTART", ev.TZID, ev.Start)
+		e.dateTime("DTEND", ev.TZID, ev.End)
+		if ev.RecurrenceID != nil {
+			e.dateTime("RECURRENCE-ID", ev.TZID, *ev.RecurrenceID)
+		}
+		if ev.RRule != "" {
+			e.line("RRULE", ev.RRule)
+		}
+		if len(ev.ExDates) > 0 {
+			e.dateTime("EXDATE", ev.TZID, ev.ExDates...)
+		}
+		e.line("SUMMARY", escapeText(ev.Summary))
+		if ev.Status != "" {
+			e.line("STATUS", ev.Status)
+		}
+		e.line("END", "VEVENT")
+	}
+	e.line("END", "VCALENDAR")
+
+	if e.err != nil {
+		return e.err
+	}
+	return bw.Flush()
+}
+
+type encoder struct {
+	w   *bufio.Writer
+	err error
+}
+
+// line writes one content line, folding it so no physical line exceeds 75 octets
+func (e *encoder) line(name, value string) {
+	if e.err != nil {
+		return
+	}
+	content := name + ":" + value
+
+	var sb strings.Builder
+	width := 0
+	for _, r := range content {
+		size := len(string(r))
+		// continuation lines start with a space, which counts toward the limit
+		if width+size > maxLineOctets {
+			sb.WriteString("\r\n ")
+			width = 1
+		}
+		sb.WriteRune(r)
+		width += size
+	}
+	sb.WriteString("\r\n")
+
+	_, e.err = e.w.WriteString(sb.String())
+}
+
+// dateTime writes a DATE-TIME property, in UTC or as local time in the zone tzid
+func (e *encoder) dateTime(name, tzid string, times ...time.Time) {
+	loc := time.UTC
+	if tzid != "" {
+		l, err := time.LoadLocation(tzid)
+		if err != nil {
+			e.err = fmt.Errorf("%s: unknown TZID %q", name, tzid)
+			return
+		}
+		loc, name = l, name+";TZID="+tzid
+	}
+	values := make([]string, len(times))
+	for i, t := range times {
+		if tzid == "" {
+			values[i] = formatDateTime(t)
+		} else {
+			values[i] = t.In(loc).Format(localDateTimeLayout)
+		}
+	}
+	e.line(name, strings.Join(values, ","))
+}
+
+func formatDateTime(t time.Time) string {
+	return t.UTC().Format(dateTimeLayout)
+}
+
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// escapeText escapes a TEXT property value
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
@@ -0,0 +1,177 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncode(t *testing.T) {
+	start := time.Date(2026, 2, 8, 9, 0, 0, 0, time.FixedZone("GMT+1", 3600))
+	cal := Calendar{
+		ProdID: "-//Vesper//Test//EN",
+		Events: []Event{{
+			UID:     "task-1@vesper",
+			Summary: "Review; plan, ship\\done",
+			Start:   start,
+			End:     start.Add(time.Hour),
+			Status:  StatusConfirmed,
+			RRule:   "FREQ=DAILY;COUNT=2",
+			Stamp:   time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+		}},
+	}
+
+	var sb strings.Builder
+	if err := cal.Encode(&sb); err != nil {
+		t.Fatalf("Encode() error: %v", err)
+	}
+	out := sb.String()
+
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\nVERSION:2.0\r\n",
+		"UID:task-1@vesper\r\n",
+		"DTSTART:20260208T080000Z\r\n",
+		"DTEND:20260208T090000Z\r\n",
+		"RRULE:FREQ=DAILY;COUNT=2\r\n",
+		`SUMMARY:Review\; plan\, ship\\done` + "\r\n",
+		"STATUS:CONFIRMED\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Encode() output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestEncodeFoldsLongLines(t *testing.T) {
+	cal := Calendar{
+		ProdID: "-//Vesper//Test//EN",
+		Events: []Event{{UID: "long", Summary: strings.Repeat("é", 80)}},
+	}
+
+	var sb strings.Builder
+	if err := cal.Encode(&sb); err != nil {
+		t.Fatalf("Encode() error: %v", err)
+	}
+
+	var summary []string
+	for _, line := range strings.Split(sb.String(), "\r\n") {
+		if len(line) > maxLineOctets {
+			t.Errorf("line exceeds %d octets: %q", maxLineOctets, line)
+		}
+		if strings.HasPrefix(line, "SUMMARY:") || (len(summary) > 0 && strings.HasPrefix(line, " ")) {
+			summary = append(summary, strings.TrimPrefix(line, " "))
+		}
+	}
+	if got := strings.Join(summary, ""); got != "SUMMARY:"+strings.Repeat("é", 80) {
+		t.Errorf("unfolded summary = %q", got)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	input := "BEGIN:VCALENDAR\r\n" +
+		"VERSION:2.0\r\n" +
+		"PRODID:-//Other//Tool//EN\r\n" +
+		"BEGIN:VTIMEZONE\r\nTZID:Europe/Berlin\r\nBEGIN:STANDARD\r\nDTSTART:19701025T030000\r\nEND:STANDARD\r\nEND:VTIMEZONE\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:evt-1\r\n" +
+		"SUMMARY:Plan\\, then\r\n  ship\r\n" +
+		"DTSTART;TZID=Europe/Berlin:20260208T090000\r\n" +
+		"DURATION:PT1H30M\r\n" +
+		"BEGIN:VALARM\r\nTRIGGER:-PT15M\r\nEND:VALARM\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:evt-2\r\n" +
+		"DTSTART;VALUE=DATE:20260209\r\n" +
+		"STATUS:cancelled\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:evt-3\r\n" +
+		"DTSTART:not-a-date\r\n" +
+		"END:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+
+	cal, err := Decode(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Decode() error: %v", err)
+	}
+	if cal.ProdID != "-//Other//Tool//EN" || len(cal.Events) != 3 {
+		t.Fatalf("Decode() = %+v", cal)
+	}
+
+	ev := cal.Events[0]
+	if ev.Summary != "Plan, then ship" {
+		t.Errorf("Summary = %q", ev.Summary)
+	}
+	if want := time.Date(2026, 2, 8, 8, 0, 0, 0, time.UTC); !ev.Start.Equal(want) || !ev.End.Equal(want.Add(90*time.Minute)) {
+		t.Errorf("times = %v - %v", ev.Start, ev.End)
+	}
+
+	ev = cal.Events[1]
+	if ev.Status != StatusCancelled || !ev.End.Equal(time.Date(2026, 2, 10, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("all-day event = %+v", ev)
+	}
+
+	if cal.Events[2].Err == nil {
+		t.Error("expected Err on event with malformed DTSTART")
+	}
+}
+
+func TestDecodeRoundTrip(t *testing.T) {
+	start := time.Date(2026, 2, 8, 9, 0, 0, 0, time.UTC)
+	want := Event{UID: "a@vesper", Summary: "a;b,c\\d", Start: start, End: start.Add(time.Hour), Status: StatusConfirmed, RRule: "FREQ=DAILY", Stamp: start,
+		ExDates: []time.Time{start.AddDate(0, 0, 1), start.AddDate(0, 0, 3)}}
+
+	var sb strings.Builder
+	if err := (&Calendar{ProdID: "x", Events: []Event{want}}).Encode(&sb); err != nil {
+		t.Fatalf("Encode() error: %v", err)
+	}
+	cal, err := Decode(strings.NewReader(sb.String()))
+	if err != nil {
+		t.Fatalf("Decode() error: %v", err)
+	}
+	got := cal.Events[0]
+	if got.UID != want.UID || got.Summary != want.Summary || !got.Start.Equal(want.Start) || !got.End.Equal(want.End) || got.RRule != want.RRule || got.Status != want.Status ||
+		len(got.ExDates) != 2 || !got.ExDates[1].Equal(want.ExDates[1]) {
+		t.Errorf("round trip = %+v, want %+v", got, want)
+	}
+}
+
+func TestEncodeTZID(t *testing.T) {
+	start := time.Date(2026, 3, 6, 14, 0, 0, 0, time.UTC) // 09:00 in New York
+	want := Event{UID: "ny@vesper", Start: start, End: start.Add(time.Hour), TZID: "America/New_York", RRule: "FREQ=DAILY", ExDates: []time.Time{start.AddDate(0, 0, 1)}}
+
+	var sb strings.Builder
+	if err := (&Calendar{ProdID: "x", Events: []Event{want}}).Encode(&sb); err != nil {
+		t.Fatalf("Encode() error: %v", err)
+	}
+	out := sb.String()
+	for _, line := range []string{
+		"DTSTART;TZID=America/New_York:20260306T090000\r\n",
+		"DTEND;TZID=America/New_York:20260306T100000\r\n",
+		"EXDATE;TZID=America/New_York:20260307T090000\r\n",
+	} {
+		if !strings.Contains(out, line) {
+			t.Errorf("Encode() output missing %q:\n%s", line, out)
+		}
+	}
+
+	cal, err := Decode(strings.NewReader(out))
+	if err != nil {
+		t.Fatalf("Decode() error: %v", err)
+	}
+	if got := cal.Events[0]; got.TZID != want.TZID || !got.Start.Equal(want.Start) {
+		t.Errorf("round trip = %+v, want %+v", got, want)
+	}
+
+	bad := Calendar{ProdID: "x", Events: []Event{{UID: "bad", Start: start, End: start, TZID: "Mars/Olympus"}}}
+	if err := bad.Encode(&strings.Builder{}); err == nil {
+		t.Error("Encode() accepted an unknown TZID")
+	}
+}
+
+func TestDecodeNoCalendar(t *testing.T) {
+	if _, err := Decode(strings.NewReader("hello")); err != ErrNoCalendar {
+		t.Errorf("Decode() error = %v, want ErrNoCalendar", err)
+	}
+}
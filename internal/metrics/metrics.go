@@ -0,0 +1,147 @@
+// Package metrics collects the server's Prometheus metrics and serves them
+// in the text exposition format for scraping.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "vesper"
+
+// Registry holds every metric the server exports, so /metrics does not pick
+// up whatever libraries register on the global default
+var Registry = prometheus.NewRegistry()
+
+var factory = promauto.With(Registry)
+
+var (
+	httpRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests served, by route pattern and status.",
+	}, []string{"method", "route", "status"})
+	httpDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Time taken to serve HTTP requests, by route pattern and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	dbQueryDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "query_duration_seconds",
+		Help:      "Time taken by database statements, by the query that ran them.",
+		// SQLite answers most queries in well under a millisecond
+		Buckets: prometheus.ExponentialBuckets(0.0001, 4, 9),
+	}, []string{"query"})
+	dbQueryErrors = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "query_errors_total",
+		Help:      "Database statements that failed, by the query that ran them.",
+	}, []string{"query"})
+
+	overlapRejections = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "tasks",
+		Name:      "overlap_rejections_total",
+		Help:      "Tasks rejected for overlapping a scheduled block.",
+	})
+
+	jobRuns = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "job",
+		Name:      "runs_total",
+		Help:      "Runs of background jobs, by job and result.",
+	}, []string{"job", "result"})
+	jobDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "job",
+		Name:      "duration_seconds",
+		Help:      "Time taken by runs of background jobs.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 10),
+	}, []string{"job"})
+	jobLastSuccess = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "job",
+		Name:      "last_success_timestamp_seconds",
+		Help:      "Unix time a background job last completed without error.",
+	}, []string{"job"})
+
+	webhookDeliveries = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "webhooks",
+		Name:      "delivery_attempts_total",
+		Help:      "Webhook delivery attempts, by resulting delivery status.",
+	}, []string{"status"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler serves the metrics for Prometheus to scrape
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// RegisterDB exports the connection pool statistics of db
+func RegisterDB(db *sql.DB) {
+	Registry.MustRegister(collectors.NewDBStatsCollector(db, "sqlite"))
+}
+
+// ObserveRequest records a served HTTP request. route is the chi route
+// pattern, never the raw path, which would give every task its own series.
+func ObserveRequest(method, route string, status int, elapsed time.Duration) {
+	if route == "" {
+		route = "unmatched"
+	}
+	code := strconv.Itoa(status)
+	httpRequests.WithLabelValues(method, route, code).Inc()
+	httpDuration.WithLabelValues(method, route, code).Observe(elapsed.Seconds())
+}
+
+// ObserveQuery records a database statement run on behalf of query
+func ObserveQuery(query string, elapsed time.Duration, err error) {
+	dbQueryDuration.WithLabelValues(query).Observe(elapsed.Seconds())
+	if err != nil {
+		dbQueryErrors.WithLabelValues(query).Inc()
+	}
+}
+
+// CountOverlapRejection records a task refused with ErrTaskOverlap
+func CountOverlapRejection() {
+	overlapRejections.Inc()
+}
+
+// ObserveJob records one run of a background job that started at start
+func ObserveJob(job string, start time.Time, err error) {
+	end := time.Now()
+	jobDuration.WithLabelValues(job).Observe(end.Sub(start).Seconds())
+	if err != nil {
+		jobRuns.WithLabelValues(job, "error").Inc()
+		return
+	}
+	jobRuns.WithLabelValues(job, "success").Inc()
+	jobLastSuccess.WithLabelValues(job).Set(float64(end.Unix()))
+}
+
+// CountWebhookDelivery records a delivery attempt ending in status:
+// delivered, pending (to be retried) or failed
+func CountWebhookDelivery(status string) {
+	webhookDeliveries.WithLabelValues(status).Inc()
+}
@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func scrape(t *testing.T) string {
+	t.Helper()
+	server := httptest.NewServer(Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200 from /metrics, got %d", resp.StatusCode)
+	}
+	return string(body)
+}
+
+func TestHandlerExportsRequestsByRoute(t *testing.T) {
+	ObserveRequest(http.MethodGet, "/api/tasks/{id}", http.StatusOK, 3*time.Millisecond)
+	ObserveRequest(http.MethodGet, "/api/tasks/{id}", http.StatusOK, 5*time.Millisecond)
+	ObserveRequest(http.MethodGet, "", http.StatusNotFound, time.Millisecond)
+
+	body := scrape(t)
+	for _, want := range []string{
+		`vesper_http_requests_total{method="GET",route="/api/tasks/{id}",status="200"} 2`,
+		`vesper_http_request_duration_seconds_count{method="GET",route="/api/tasks/{id}",status="200"} 2`,
+		`vesper_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		"go_goroutines ",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %s in the scrape:\n%s", want, body)
+		}
+	}
+}
+
+func TestObserveJob(t *testing.T) {
+	ObserveJob("test_job", time.Now(), nil)
+	ObserveJob("test_job", time.Now(), errors.New("boom"))
+
+	body := scrape(t)
+	for _, want := range []string{
+		`vesper_job_runs_total{job="test_job",result="success"} 1`,
+		`vesper_job_runs_total{job="test_job",result="error"} 1`,
+		`vesper_job_duration_seconds_count{job="test_job"} 2`,
+		`vesper_job_last_success_timestamp_seconds{job="test_job"} `,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %s in the scrape", want)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// PlanningSchedule is when a user receives the nightly link to plan the next day
+type PlanningSchedule struct {
+	UserID string `json:"user_id"`
+	// Timezone is an IANA zone name such as "Europe/London".
+	Timezone string `json:"timezone"`
+	// SendAt is the local wall-clock time as HH:MM.
+	SendAt  string `json:"send_at"`
+	Enabled bool   `json:"enabled"`
+	// CreatedAt is when the schedule was first saved; edits only move UpdatedAt.
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// PlanningSession is one user's planning page for one day
+type PlanningSession struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	PlanDate   string     `json:"plan_date"`
+	CreatedAt  time.Time  `json:"created_at"`
+	NotifiedAt *time.Time `json:"notified_at,omitempty"`
+}
@@ -0,0 +1,284 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequency is the FREQ part of a recurrence rule
+type Frequency string
+
+const (
+	FreqDaily   Frequency = "DAILY"
+	FreqWeekly  Frequency = "WEEKLY"
+	FreqMonthly Frequency = "MONTHLY"
+	FreqYearly  Frequency = "YEARLY"
+)
+
+// maxRecurrencePeriods stops expansion of rules whose filters never match
+const maxRecurrencePeriods = 100000
+
+const untilLayout = "20060102T150405Z"
+
+var weekdayCodes = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// RRule is the subset of an RFC 5545 recurrence rule that Vesper supports:
+// FREQ (DAILY, WEEKLY, MONTHLY, YEARLY), INTERVAL, COUNT, UNTIL and plain
+// weekday BYDAY values for DAILY and WEEKLY rules.
+type RRule struct {
+	Freq     Frequency
+	Interval int
+	Count    int
+	Until    time.Time
+	ByDay    []time.Weekday
+}
+
+// OccurrenceOverride changes or cancels a single occurrence of a recurring task
+type OccurrenceOverride struct {
+	RecurrenceID time.Time
+	Cancelled    bool
+	Title        string
+	Start        time.Time
+	End          time.Time
+}
+
+// ParseRRule parses a rule such as "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=10".
+// An optional "RRULE:" prefix is accepted.
+func ParseRRule(s string) (*RRule, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	if s == "" {
+		return nil, errors.New("empty rule")
+	}
+
+	r := &RRule{Interval: 1}
+	for _, part := range strings.Split(s, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("malformed part %q", part)
+		}
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			r.Freq = Frequency(strings.ToUpper(value))
+			switch r.Freq {
+			case FreqDaily, FreqWeekly, FreqMonthly, FreqYearly:
+			default:
+				return nil, fmt.Errorf("unsupported FREQ %q", value)
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid INTERVAL %q", value)
+			}
+			r.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid COUNT %q", value)
+			}
+			r.Count = n
+		case "UNTIL":
+			until, err := parseUntil(value)
+			if err != nil {
+				return nil, err
+			}
+			r.Until = until
+		case "BYDAY":
+			for _, code := range strings.Split(value, ",") {
+				d, ok := weekdayCodes[strings.ToUpper(code)]
+				if !ok {
+					return nil, fmt.Errorf("unsupported BYDAY %q", code)
+				}
+				if !slices.Contains(r.ByDay, d) {
+					r.ByDay = append(r.ByDay, d)
+				}
+			}
+		case "WKST":
+			if strings.ToUpper(value) != "MO" {
+				return nil, fmt.Errorf("unsupported WKST %q", value)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported rule part %q", key)
+		}
+	}
+
+	if r.Freq == "" {
+		return nil, errors.New("FREQ is required")
+	}
+	if r.Count > 0 && !r.Until.IsZero() {
+		return nil, errors.New("COUNT and UNTIL are mutually exclusive")
+	}
+	if len(r.ByDay) > 0 && r.Freq != FreqDaily && r.Freq != FreqWeekly {
+		return nil, fmt.Errorf("BYDAY is not supported with FREQ=%s", r.Freq)
+	}
+	// keep weekdays in week order (weeks start on Monday)
+	sort.Slice(r.ByDay, func(i, j int) bool {
+		return mondayOffset(r.ByDay[i]) < mondayOffset(r.ByDay[j])
+	})
+	return r, nil
+}
+
+func parseUntil(v string) (time.Time, error) {
+	if t, err := time.Parse(untilLayout, v); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("20060102", v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid UNTIL %q", v)
+	}
+	// a date-only UNTIL includes the whole day
+	return t.Add(24*time.Hour - time.Second), nil
+}
+
+// String renders the rule in canonical RRULE value form (without the "RRULE:" prefix)
+func (r *RRule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format(untilLayout))
+	}
+	if len(r.ByDay) > 0 {
+		codes := make([]string, len(r.ByDay))
+		for i, d := range r.ByDay {
+			codes[i] = strings.ToUpper(d.String()[:2])
+		}
+		parts = append(parts, "BYDAY="+strings.Join(codes, ","))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Each calls fn with successive occurrence starts of the rule anchored at
+// dtstart, in ascending order, until fn returns false or the rule ends.
+func (r *RRule) Each(dtstart time.Time, fn func(time.Time) bool) {
+	emitted := 0
+	for n := 0; n < maxRecurrencePeriods; n++ {
+		for _, t := range r.period(dtstart, n) {
+			if t.Before(dtstart) {
+				continue
+			}
+			if !r.Until.IsZero() && t.After(r.Until) {
+				return
+			}
+			if r.Count > 0 && emitted >= r.Count {
+				return
+			}
+			emitted++
+			if !fn(t) {
+				return
+			}
+		}
+	}
+}
+
+// Includes reports whether at is an occurrence start of the rule anchored at dtstart
+func (r *RRule) Includes(dtstart, at time.Time) bool {
+	found := false
+	r.Each(dtstart, func(t time.Time) bool {
+		found = t.Equal(at)
+		return t.Before(at)
+	})
+	return found
+}
+
+// period returns the candidate starts of the n-th recurrence period
+func (r *RRule) period(dtstart time.Time, n int) []time.Time {
+	step := n * r.Interval
+	switch r.Freq {
+	case FreqDaily:
+		t := dtstart.AddDate(0, 0, step)
+		if len(r.ByDay) > 0 && !slices.Contains(r.ByDay, t.Weekday()) {
+			return nil
+		}
+		return []time.Time{t}
+	case FreqWeekly:
+		if len(r.ByDay) == 0 {
+			return []time.Time{dtstart.AddDate(0, 0, 7*step)}
+		}
+		weekStart := dtstart.AddDate(0, 0, 7*step-mondayOffset(dtstart.Weekday()))
+		starts := make([]time.Time, 0, len(r.ByDay))
+		for _, d := range r.ByDay {
+			starts = append(starts, weekStart.AddDate(0, 0, mondayOffset(d)))
+		}
+		return starts
+	case FreqMonthly, FreqYearly:
+		months := step
+		if r.Freq == FreqYearly {
+			months = 12 * step
+		}
+		y, m, d := dtstart.Date()
+		t := time.Date(y, m+time.Month(months), d,
+			dtstart.Hour(), dtstart.Minute(), dtstart.Second(), dtstart.Nanosecond(), dtstart.Location())
+		if t.Day() != d {
+			return nil // e.g. the 31st in a 30-day month is skipped, as in RFC 5545
+		}
+		return []time.Time{t}
+	}
+	return nil
+}
+
+func mondayOffset(d time.Weekday) int {
+	return (int(d) + 6) % 7
+}
+
+// Occurrences expands a recurring task into the instances overlapping [from, to),
+// applying any per-occurrence overrides. The rule is expanded in the task's
+// Location, so weekdays and wall-clock times hold across offsets and DST
+// changes. Each instance keeps the series ID and carries its original start
+// in RecurrenceID.
+func Occurrences(t Task, rule *RRule, overrides []OccurrenceOverride, from, to time.Time) []Task {
+	duration := t.End.Sub(t.Start)
+	overridden := make(map[int64]bool, len(overrides))
+	for _, o := range overrides {
+		overridden[o.RecurrenceID.UnixNano()] = true
+	}
+
+	var occurrences []Task
+	rule.Each(t.Start.In(t.Location()), func(start time.Time) bool {
+		start = start.UTC()
+		if !start.Before(to) {
+			return false
+		}
+		if !overridden[start.UnixNano()] && start.Add(duration).After(from) {
+			occurrences = append(occurrences, occurrence(t, start, t.Title, start, start.Add(duration)))
+		}
+		return true
+	})
+
+	for _, o := range overrides {
+		if o.Cancelled || !o.Start.Before(to) || !o.End.After(from) {
+			continue
+		}
+		occurrences = append(occurrences, occurrence(t, o.RecurrenceID, o.Title, o.Start, o.End))
+	}
+
+	sort.Slice(occurrences, func(i, j int) bool {
+		return occurrences[i].Start.Before(occurrences[j].Start)
+	})
+	return occurrences
+}
+
+func occurrence(t Task, recurrenceID time.Time, title string, start, end time.Time) Task {
+	id := recurrenceID.UTC()
+	t.Title = title
+	t.Start = start
+	t.End = end
+	t.RecurrenceID = &id
+	return t
+}
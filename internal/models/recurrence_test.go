@@ -0,0 +1,223 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRRule(t *testing.T) {
+	tests := []struct {
+		rule    string
+		want    string
+		wantErr bool
+	}{
+		{rule: "FREQ=DAILY", want: "FREQ=DAILY"},
+		{rule: "RRULE:freq=weekly;byday=we,mo", want: "FREQ=WEEKLY;BYDAY=MO,WE"},
+		{rule: "FREQ=MONTHLY;INTERVAL=2;COUNT=4", want: "FREQ=MONTHLY;INTERVAL=2;COUNT=4"},
+		{rule: "FREQ=WEEKLY;UNTIL=20260301", want: "FREQ=WEEKLY;UNTIL=20260301T235959Z"},
+		{rule: "", wantErr: true},
+		{rule: "COUNT=3", wantErr: true},
+		{rule: "FREQ=HOURLY", wantErr: true},
+		{rule: "FREQ=DAILY;INTERVAL=0", wantErr: true},
+		{rule: "FREQ=DAILY;COUNT=2;UNTIL=20260301", wantErr: true},
+		{rule: "FREQ=MONTHLY;BYDAY=MO", wantErr: true},
+		{rule: "FREQ=WEEKLY;BYDAY=1MO", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			r, err := ParseRRule(tt.rule)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseRRule(%q) expected error, got %v", tt.rule, r)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseRRule(%q) unexpected error: %v", tt.rule, err)
+			}
+			if got := r.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRRuleEach(t *testing.T) {
+	monday := time.Date(2026, 2, 2, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		rule    string
+		dtstart time.Time
+		want    []time.Time
+	}{
+		{
+			name:    "daily count",
+			rule:    "FREQ=DAILY;COUNT=3",
+			dtstart: monday,
+			want:    []time.Time{monday, monday.AddDate(0, 0, 1), monday.AddDate(0, 0, 2)},
+		},
+		{
+			name:    "weekly byday skips days before dtstart",
+			rule:    "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=3",
+			dtstart: monday.AddDate(0, 0, 1),
+			want:    []time.Time{monday.AddDate(0, 0, 2), monday.AddDate(0, 0, 7), monday.AddDate(0, 0, 9)},
+		},
+		{
+			name:    "biweekly until",
+			rule:    "FREQ=WEEKLY;INTERVAL=2;UNTIL=20260302T090000Z",
+			dtstart: monday,
+			want:    []time.Time{monday, monday.AddDate(0, 0, 14), monday.AddDate(0, 0, 28)},
+		},
+		{
+			name:    "monthly skips short months",
+			rule:    "FREQ=MONTHLY;COUNT=3",
+			dtstart: time.Date(2026, 1, 31, 9, 0, 0, 0, time.UTC),
+			want: []time.Time{
+				time.Date(2026, 1, 31, 9, 0, 0, 0, time.UTC),
+				time.Date(2026, 3, 31, 9, 0, 0, 0, time.UTC),
+				time.Date(2026, 5, 31, 9, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name:    "weekday dailies",
+			rule:    "FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR;COUNT=6",
+			dtstart: monday,
+			want: []time.Time{
+				monday, monday.AddDate(0, 0, 1), monday.AddDate(0, 0, 2),
+				monday.AddDate(0, 0, 3), monday.AddDate(0, 0, 4), monday.AddDate(0, 0, 7),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := ParseRRule(tt.rule)
+			if err != nil {
+				t.Fatalf("ParseRRule(%q) unexpected error: %v", tt.rule, err)
+			}
+
+			var got []time.Time
+			r.Each(tt.dtstart, func(s time.Time) bool {
+				got = append(got, s)
+				return len(got) < 10
+			})
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("Each() yielded %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Equal(tt.want[i]) {
+					t.Errorf("occurrence %d = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestOccurrences(t *testing.T) {
+	start := time.Date(2026, 2, 2, 9, 0, 0, 0, time.UTC)
+	task := Task{ID: "series", Title: "Standup", Start: start, End: start.Add(15 * time.Minute), Status: StatusScheduled}
+	rule, _ := ParseRRule("FREQ=DAILY")
+
+	overrides := []OccurrenceOverride{
+		{RecurrenceID: start.AddDate(0, 0, 1), Cancelled: true},
+		{RecurrenceID: start.AddDate(0, 0, 2), Title: "Moved", Start: start.AddDate(0, 0, 2).Add(6 * time.Hour), End: start.AddDate(0, 0, 2).Add(7 * time.Hour)},
+	}
+
+	got := Occurrences(task, rule, overrides, start, start.AddDate(0, 0, 4))
+	if len(got) != 3 {
+		t.Fatalf("Occurrences() returned %d instances, want 3", len(got))
+	}
+	if !got[0].Start.Equal(start) || got[0].Title != "Standup" {
+		t.Errorf("first occurrence = %+v", got[0])
+	}
+	if got[1].Title != "Moved" || !got[1].RecurrenceID.Equal(start.AddDate(0, 0, 2)) {
+		t.Errorf("moved occurrence = %+v", got[1])
+	}
+	if !got[2].Start.Equal(start.AddDate(0, 0, 3)) {
+		t.Errorf("last occurrence = %+v", got[2])
+	}
+
+	if !rule.Includes(start, start.AddDate(0, 0, 5)) || rule.Includes(start, start.Add(time.Hour)) {
+		t.Error("Includes() mismatched occurrence starts")
+	}
+}
+
+func TestOccurrencesInTimezone(t *testing.T) {
+	sydney, err := time.LoadLocation("Australia/Sydney")
+	if err != nil {
+		t.Skipf("zone database unavailable: %v", err)
+	}
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("zone database unavailable: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		start time.Time
+		zone  string
+		rule  string
+		want  []time.Time // in the task's zone
+	}{
+		{
+			// Monday 08:00 in Sydney is still Sunday in UTC
+			name:  "weekdays across the date line",
+			start: time.Date(2026, 2, 2, 8, 0, 0, 0, sydney),
+			zone:  "Australia/Sydney",
+			rule:  "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=4",
+			want: []time.Time{
+				time.Date(2026, 2, 2, 8, 0, 0, 0, sydney),
+				time.Date(2026, 2, 4, 8, 0, 0, 0, sydney),
+				time.Date(2026, 2, 9, 8, 0, 0, 0, sydney),
+				time.Date(2026, 2, 11, 8, 0, 0, 0, sydney),
+			},
+		},
+		{
+			// clocks go forward on Sunday 8 March 2026
+			name:  "wall-clock time across a DST change",
+			start: time.Date(2026, 3, 6, 9, 0, 0, 0, newYork),
+			zone:  "America/New_York",
+			rule:  "FREQ=DAILY;COUNT=4",
+			want: []time.Time{
+				time.Date(2026, 3, 6, 9, 0, 0, 0, newYork),
+				time.Date(2026, 3, 7, 9, 0, 0, 0, newYork),
+				time.Date(2026, 3, 8, 9, 0, 0, 0, newYork),
+				time.Date(2026, 3, 9, 9, 0, 0, 0, newYork),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// tasks are stored in UTC; only the zone name keeps the local anchor
+			start := tt.start.UTC()
+			task := Task{ID: "series", Title: "Block", Start: start, End: start.Add(time.Hour), Timezone: tt.zone, Status: StatusScheduled}
+			rule, err := ParseRRule(tt.rule)
+			if err != nil {
+				t.Fatalf("ParseRRule() error: %v", err)
+			}
+
+			got := Occurrences(task, rule, nil, start, start.AddDate(0, 1, 0))
+			if len(got) != len(tt.want) {
+				t.Fatalf("Occurrences() returned %d instances, want %d", len(got), len(tt.want))
+			}
+			for i, want := range tt.want {
+				if !got[i].Start.Equal(want) || !got[i].RecurrenceID.Equal(want) {
+					t.Errorf("occurrence %d starts %v, want %v", i, got[i].Start, want.UTC())
+				}
+				if got[i].Start.Location() != time.UTC {
+					t.Errorf("occurrence %d start in %v, want UTC", i, got[i].Start.Location())
+				}
+			}
+
+			// the same rule in UTC lands on other instants
+			task.Timezone = ""
+			if utc := Occurrences(task, rule, nil, start, start.AddDate(0, 1, 0)); len(utc) == len(got) && utc[len(utc)-1].Start.Equal(got[len(got)-1].Start) {
+				t.Errorf("expanding in UTC gave the zone's instants; the test does not cover %s", tt.name)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// RevisionAction names the change a revision records
+type RevisionAction string
+
+const (
+	RevisionCreate  RevisionAction = "create"
+	RevisionUpdate  RevisionAction = "update"
+	RevisionDelete  RevisionAction = "delete"
+	RevisionRestore RevisionAction = "restore"
+	RevisionReplace RevisionAction = "replace"
+	RevisionRevert  RevisionAction = "revert"
+)
+
+// Revision is a task as one change left it. Revisions are numbered from 1
+// for each task and never change once written.
+type Revision struct {
+	TaskID   string         `json:"task_id"`
+	Revision int            `json:"revision"`
+	Action   RevisionAction `json:"action"`
+	// Actor is who made the change: "user:<id>" for a signed-in session,
+	// "token:<id>" for a personal API token, or the background job's name.
+	Actor     string    `json:"actor"`
+	CreatedAt time.Time `json:"created_at"`
+	Task      Task      `json:"task"`
+}
+
+// FieldChange is one task field that differs between two revisions. From and
+// To are nil when the field is unset on that side.
+type FieldChange struct {
+	Field string `json:"field"`
+	From  any    `json:"from"`
+	To    any    `json:"to"`
+}
+
+// DiffTasks lists the fields that differ from a to b, named and valued as in
+// the tasks' JSON and sorted by field name
+func DiffTasks(a, b Task) []FieldChange {
+	from, to := taskFields(a), taskFields(b)
+
+	var changes []FieldChange
+	for field := range from {
+		if _, ok := to[field]; !ok {
+			changes = append(changes, FieldChange{Field: field, From: from[field]})
+		}
+	}
+	for field, value := range to {
+		if !reflect.DeepEqual(from[field], value) {
+			changes = append(changes, FieldChange{Field: field, From: from[field], To: value})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes
+}
+
+func taskFields(t Task) map[string]any {
+	data, _ := json.Marshal(t)
+	var fields map[string]any
+	_ = json.Unmarshal(data, &fields)
+	// every change moves the version on, so it says nothing about what changed
+	delete(fields, "version")
+	return fields
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDiffTasks(t *testing.T) {
+	start := time.Date(2026, 2, 8, 9, 0, 0, 0, time.UTC)
+	before := Task{ID: "t1", Title: "Review", Start: start, End: start.Add(time.Hour), UserID: "u1", Status: StatusScheduled, Recurrence: "FREQ=DAILY"}
+	after := before
+	after.Start, after.End = start.Add(time.Hour), start.Add(2*time.Hour)
+	after.Recurrence = ""
+	after.Status = StatusDeleted
+
+	changes := DiffTasks(before, after)
+	want := []FieldChange{
+		{Field: "end", From: "2026-02-08T10:00:00Z", To: "2026-02-08T11:00:00Z"},
+		{Field: "recurrence", From: "FREQ=DAILY"},
+		{Field: "start", From: "2026-02-08T09:00:00Z", To: "2026-02-08T10:00:00Z"},
+		{Field: "status", From: "scheduled", To: "deleted"},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("DiffTasks() = %+v, want %+v", changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("change %d = %+v, want %+v", i, changes[i], want[i])
+		}
+	}
+
+	if changes := DiffTasks(before, before); len(changes) != 0 {
+		t.Errorf("DiffTasks() of identical tasks = %+v, want none", changes)
+	}
+}
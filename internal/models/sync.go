@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// SyncLink ties a local task to its event in a remote calendar
+type SyncLink struct {
+	TaskID     string `json:"task_id"`
+	UserID     string `json:"user_id"`
+	CalendarID string `json:"calendar_id"`
+	RemoteID   string `json:"remote_id"`
+	ETag       string `json:"etag"`
+	// ContentHash fingerprints the task as last synced, to spot local edits.
+	ContentHash string    `json:"content_hash"`
+	SyncedAt    time.Time `json:"synced_at"`
+}
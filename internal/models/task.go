@@ -19,6 +19,36 @@ type Task struct {
 	End    time.Time  `json:"end"`
 	UserID string     `json:"user_id"`
 	Status TaskStatus `json:"status"`
+	// Recurrence is an RFC 5545 RRULE value; empty for one-off blocks.
+	Recurrence string `json:"recurrence,omitempty"`
+	// Timezone is the IANA zone a recurring task repeats in, so its
+	// weekdays and wall-clock time follow that zone; empty means UTC.
+	Timezone string `json:"timezone,omitempty"`
+	// RecurrenceID is the original start of an expanded occurrence.
+	RecurrenceID *time.Time `json:"recurrence_id,omitempty"`
+	// Replaces and ReplacedBy link a replaced task and its successor.
+	Replaces   string `json:"replaces,omitempty"`
+	ReplacedBy string `json:"replaced_by,omitempty"`
+	// DeletedAt is when the task was moved to the trash.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// Version counts the changes to the task, from 1; writes that name a
+	// version only apply while the task is still at it.
+	Version int64 `json:"version,omitempty"`
+	// ICalUID is the UID of the calendar event the task was imported from.
+	ICalUID string `json:"ical_uid,omitempty"`
+}
+
+// Location returns the zone the task's recurrence is expanded in: its
+// Timezone, or UTC when that is empty or unknown
+func (t Task) Location() *time.Location {
+	if t.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(t.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
 }
 
 func IsValidStatus(s TaskStatus) bool {
@@ -33,6 +63,7 @@ func IsValidStatus(s TaskStatus) bool {
 type User struct {
 	ID       string `json:"id"`
 	Username string `json:"username"`
+	Email    string `json:"email,omitempty"`
 }
 
 func IsOverlapping(newTask Task, existing []Task) bool {
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+// Scope limits what a personal API token may do
+type Scope string
+
+const (
+	ScopeTasksRead     Scope = "tasks:read"
+	ScopeTasksWrite    Scope = "tasks:write"
+	ScopePlanningRead  Scope = "planning:read"
+	ScopePlanningWrite Scope = "planning:write"
+	ScopeAccountRead   Scope = "account:read"
+	ScopeWebhooksRead  Scope = "webhooks:read"
+	ScopeWebhooksWrite Scope = "webhooks:write"
+)
+
+// Scopes lists every scope a token can be granted
+var Scopes = []Scope{
+	ScopeTasksRead, ScopeTasksWrite, ScopePlanningRead, ScopePlanningWrite, ScopeAccountRead,
+	ScopeWebhooksRead, ScopeWebhooksWrite,
+}
+
+func IsValidScope(s Scope) bool {
+	return slices.Contains(Scopes, s)
+}
+
+// APIToken is a personal access token. The secret itself is only shown once,
+// when the token is created.
+type APIToken struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	Name       string     `json:"name"`
+	Scopes     []Scope    `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// HasScope reports whether the token was granted s
+func (t *APIToken) HasScope(s Scope) bool {
+	return slices.Contains(t.Scopes, s)
+}
+
+// Expired reports whether the token is past its expiry at now
+func (t *APIToken) Expired(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
@@ -0,0 +1,63 @@
+// Package planning sends each user a nightly link to plan the following day.
+package planning
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrLinkExpired   = errors.New("planning link expired")
+	ErrLinkSignature = errors.New("invalid planning link signature")
+)
+
+// Signer mints and verifies HMAC-signed planning links, so the link alone
+// proves which session it was issued for and until when.
+type Signer struct {
+	key     []byte
+	baseURL string
+	ttl     time.Duration
+}
+
+// NewSigner creates a signer issuing links under baseURL that stay valid for ttl
+func NewSigner(secret []byte, baseURL string, ttl time.Duration) *Signer {
+	return &Signer{key: secret, baseURL: strings.TrimRight(baseURL, "/"), ttl: ttl}
+}
+
+func (s *Signer) sign(sessionID string, expires int64) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(sessionID + "." + strconv.FormatInt(expires, 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Link returns the signed planning URL for a session and when it expires
+func (s *Signer) Link(sessionID string, now time.Time) (string, time.Time) {
+	expires := now.Add(s.ttl).Truncate(time.Second)
+	query := url.Values{
+		"plan":    {sessionID},
+		"expires": {strconv.FormatInt(expires.Unix(), 10)},
+		"sig":     {s.sign(sessionID, expires.Unix())},
+	}
+	return s.baseURL + "/?" + query.Encode(), expires
+}
+
+// Verify checks a link's signature and expiry
+func (s *Signer) Verify(sessionID, expires, sig string, now time.Time) error {
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrLinkSignature
+	}
+	if !hmac.Equal([]byte(sig), []byte(s.sign(sessionID, exp))) {
+		return ErrLinkSignature
+	}
+	if now.Unix() > exp {
+		return ErrLinkExpired
+	}
+	return nil
+}
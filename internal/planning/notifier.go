@@ -0,0 +1,45 @@
+package planning
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/Adjanour/vesper/internal/models"
+)
+
+// Notice is a planning link ready to be delivered to a user
+type Notice struct {
+	UserID    string
+	Username  string
+	PlanDate  string
+	Link      string
+	ExpiresAt time.Time
+	// Agenda holds the blocks already scheduled on PlanDate, in start order.
+	Agenda []models.Task
+	// Location is the user's time zone, for rendering Agenda times.
+	Location *time.Location
+}
+
+// Notifier delivers planning links. Delivery is at-least-once: a crash between
+// sending and recording the send repeats the notice on restart.
+type Notifier interface {
+	Notify(ctx context.Context, n Notice) error
+}
+
+// NotifierFunc adapts a function to the Notifier interface
+type NotifierFunc func(ctx context.Context, n Notice) error
+
+// Notify implements Notifier
+func (f NotifierFunc) Notify(ctx context.Context, n Notice) error {
+	return f(ctx, n)
+}
+
+// LogNotifier writes planning links to the server log, for development
+type LogNotifier struct{}
+
+// Notify implements Notifier
+func (LogNotifier) Notify(_ context.Context, n Notice) error {
+	slog.Info("Planning link", "username", n.Username, "user_id", n.UserID, "plan_date", n.PlanDate, "link", n.Link)
+	return nil
+}
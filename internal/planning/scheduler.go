@@ -0,0 +1,199 @@
+package planning
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/Adjanour/vesper/internal/database"
+	"github.com/Adjanour/vesper/internal/metrics"
+	"github.com/Adjanour/vesper/internal/models"
+)
+
+// Scheduler checks every user's schedule on a fixed interval and sends the
+// planning link for the next day once the user's local send time has passed.
+//
+// Progress lives in planning_sessions, so a restart never repeats a night
+// that was already sent, and a link that fell due while the server was down
+// goes out on the next check. Only the most recent due night is caught up:
+// earlier nights missed during a longer outage are skipped, as the days
+// they would plan have already begun.
+type Scheduler struct {
+	db       *database.Queries
+	notifier Notifier
+	signer   *Signer
+	interval time.Duration
+	now      func() time.Time
+}
+
+// NewScheduler creates a scheduler that checks schedules every interval
+func NewScheduler(q *database.Queries, notifier Notifier, signer *Signer, interval time.Duration) *Scheduler {
+	return &Scheduler{
+		db:       q,
+		notifier: notifier,
+		signer:   signer,
+		interval: interval,
+		now:      time.Now,
+	}
+}
+
+// Run checks schedules immediately and then every interval until ctx is cancelled
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		start := time.Now()
+		err := s.RunOnce(ctx)
+		metrics.ObserveJob("planning_scheduler", start, err)
+		if err != nil {
+			slog.ErrorContext(ctx, "planning scheduler failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// maxConcurrentSends bounds how many planning links are delivered at once
+const maxConcurrentSends = 8
+
+// delivery is a prepared planning link and the outcome of sending it
+type delivery struct {
+	sessionID string
+	notice    Notice
+	err       error
+}
+
+// RunOnce sends every planning link that is due. Links are prepared one user
+// at a time and then delivered concurrently, so a mail server that is slow or
+// being retried for one user does not hold up everyone else.
+func (s *Scheduler) RunOnce(ctx context.Context) error {
+	schedules, err := s.db.ListPlanningSchedules(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := s.now()
+	var due []delivery
+	for _, schedule := range schedules {
+		d, err := s.prepare(ctx, schedule, now)
+		if err != nil {
+			// one user's failure must not hold up everyone else
+			slog.ErrorContext(ctx, "planning scheduler failed for user", "user_id", schedule.UserID, "error", err)
+			continue
+		}
+		if d != nil {
+			due = append(due, *d)
+		}
+	}
+
+	// sessions are marked here as deliveries finish, keeping database writes
+	// on this goroutine
+	results := make(chan delivery)
+	slots := make(chan struct{}, maxConcurrentSends)
+	var wg sync.WaitGroup
+	for _, d := range due {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slots <- struct{}{}
+			d.err = s.notifier.Notify(ctx, d.notice)
+			<-slots
+			results <- d
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	for d := range results {
+		err := d.err
+		if err != nil {
+			err = fmt.Errorf("notify: %w", err)
+		} else {
+			err = s.db.MarkPlanningSessionNotified(ctx, d.sessionID, now)
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "planning scheduler failed for user", "user_id", d.notice.UserID, "error", err)
+		}
+	}
+	return nil
+}
+
+// prepare returns the planning link owed to the schedule's user at now, or
+// nil when none is due or it was already sent
+func (s *Scheduler) prepare(ctx context.Context, schedule *models.PlanningSchedule, now time.Time) (*delivery, error) {
+	due, err := LastDue(schedule, now)
+	if err != nil {
+		return nil, err
+	}
+	// nights that fell due before the schedule existed are not owed a link;
+	// editing it later does not change that
+	if due.Before(schedule.CreatedAt) {
+		return nil, nil
+	}
+	day := time.Date(due.Year(), due.Month(), due.Day()+1, 0, 0, 0, 0, due.Location())
+	planDate := day.Format(time.DateOnly)
+
+	session, err := s.db.EnsurePlanningSession(ctx, newSessionID(), schedule.UserID, planDate)
+	if err != nil {
+		return nil, err
+	}
+	if session.NotifiedAt != nil {
+		return nil, nil
+	}
+
+	notice := Notice{UserID: schedule.UserID, PlanDate: planDate, Location: due.Location()}
+	if user, err := s.db.GetUser(ctx, schedule.UserID); err == nil {
+		notice.Username = user.Username
+	}
+	agenda, err := s.db.ListTasks(ctx, schedule.UserID, database.TaskFilter{
+		From:     day,
+		To:       day.AddDate(0, 0, 1),
+		Statuses: []models.TaskStatus{models.StatusScheduled},
+		Sort:     database.SortStartAsc,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("agenda: %w", err)
+	}
+	for _, t := range agenda {
+		notice.Agenda = append(notice.Agenda, *t)
+	}
+	notice.Link, notice.ExpiresAt = s.signer.Link(session.ID, now)
+
+	return &delivery{sessionID: session.ID, notice: notice}, nil
+}
+
+// LastDue returns the most recent send time at or before now, in the schedule's zone
+func LastDue(schedule *models.PlanningSchedule, now time.Time) (time.Time, error) {
+	loc, err := time.LoadLocation(schedule.Timezone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("timezone: %w", err)
+	}
+	at, err := time.Parse("15:04", schedule.SendAt)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("send_at: %w", err)
+	}
+
+	local := now.In(loc)
+	due := time.Date(local.Year(), local.Month(), local.Day(), at.Hour(), at.Minute(), 0, 0, loc)
+	if local.Before(due) {
+		due = due.AddDate(0, 0, -1)
+	}
+	return due, nil
+}
+
+func newSessionID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,281 @@
+package planning
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Adjanour/vesper/internal/database"
+	"github.com/Adjanour/vesper/internal/models"
+	_ "modernc.org/sqlite"
+)
+
+func setupPlanningDB(t *testing.T) *database.Queries {
+	t.Helper()
+	db, err := sql.Open("sqlite", ":memory:?_pragma=foreign_keys(1)")
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	files, _ := filepath.Glob("../database/migrations/*.up.sql")
+	sort.Strings(files)
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		if _, err := db.Exec(string(content)); err != nil {
+			t.Fatalf("Failed to apply %s: %v", file, err)
+		}
+	}
+	if _, err := db.Exec(`INSERT INTO users (id, username) VALUES ('u1', 'planner')`); err != nil {
+		t.Fatalf("Failed to insert test user: %v", err)
+	}
+	return database.NewQueries(db)
+}
+
+func TestSchedulerSendsOncePerNight(t *testing.T) {
+	ctx := context.Background()
+	q := setupPlanningDB(t)
+	london, _ := time.LoadLocation("Europe/London")
+
+	err := q.UpsertPlanningSchedule(ctx, models.PlanningSchedule{
+		UserID:    "u1",
+		Timezone:  "Europe/London",
+		SendAt:    "21:00",
+		Enabled:   true,
+		UpdatedAt: time.Date(2025, 6, 1, 9, 0, 0, 0, london),
+	})
+	if err != nil {
+		t.Fatalf("UpsertPlanningSchedule failed: %v", err)
+	}
+
+	var sent []Notice
+	notifier := NotifierFunc(func(_ context.Context, n Notice) error {
+		sent = append(sent, n)
+		return nil
+	})
+	signer := NewSigner([]byte("secret"), "http://vesper.test", 24*time.Hour)
+
+	now := time.Date(2025, 6, 1, 20, 59, 0, 0, london)
+	newScheduler := func() *Scheduler {
+		s := NewScheduler(q, notifier, signer, time.Minute)
+		s.now = func() time.Time { return now }
+		return s
+	}
+	s := newScheduler()
+
+	if err := s.RunOnce(ctx); err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+	if len(sent) != 0 {
+		t.Fatalf("Expected nothing before the send time, got %d notices", len(sent))
+	}
+
+	now = time.Date(2025, 6, 1, 21, 1, 0, 0, london)
+	if err := s.RunOnce(ctx); err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+	if len(sent) != 1 {
+		t.Fatalf("Expected 1 notice after the send time, got %d", len(sent))
+	}
+	if sent[0].PlanDate != "2025-06-02" || sent[0].Username != "planner" {
+		t.Errorf("Unexpected notice: %+v", sent[0])
+	}
+
+	// a repeated tick and a restarted scheduler must not send again
+	if err := s.RunOnce(ctx); err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+	if err := newScheduler().RunOnce(ctx); err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+	if len(sent) != 1 {
+		t.Fatalf("Expected no repeat sends, got %d notices", len(sent))
+	}
+
+	// a night missed while the server was down is caught up the next morning
+	now = time.Date(2025, 6, 3, 7, 0, 0, 0, london)
+	if err := newScheduler().RunOnce(ctx); err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+	if len(sent) != 2 || sent[1].PlanDate != "2025-06-03" {
+		t.Fatalf("Expected catch-up notice for 2025-06-03, got %+v", sent)
+	}
+
+	link, err := url.Parse(sent[1].Link)
+	if err != nil {
+		t.Fatalf("Invalid link %q: %v", sent[1].Link, err)
+	}
+	params := link.Query()
+	if err := signer.Verify(params.Get("plan"), params.Get("expires"), params.Get("sig"), now); err != nil {
+		t.Errorf("Expected link to verify, got %v", err)
+	}
+	session, err := q.GetPlanningSession(ctx, params.Get("plan"))
+	if err != nil {
+		t.Fatalf("GetPlanningSession failed: %v", err)
+	}
+	if session.PlanDate != "2025-06-03" || session.NotifiedAt == nil {
+		t.Errorf("Unexpected session: %+v", session)
+	}
+}
+
+func TestSchedulerAgendaInUserZone(t *testing.T) {
+	ctx := context.Background()
+	q := setupPlanningDB(t)
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+
+	err := q.UpsertPlanningSchedule(ctx, models.PlanningSchedule{
+		UserID:    "u1",
+		Timezone:  "Europe/Berlin",
+		SendAt:    "21:00",
+		Enabled:   true,
+		UpdatedAt: time.Date(2025, 6, 1, 9, 0, 0, 0, berlin),
+	})
+	if err != nil {
+		t.Fatalf("UpsertPlanningSchedule failed: %v", err)
+	}
+
+	// tasks are stored in UTC; the agenda is the user's local 2 June
+	at := func(day, hour, minute int) time.Time { return time.Date(2025, 6, day, hour, minute, 0, 0, berlin) }
+	for _, task := range []models.Task{
+		{ID: "late-tonight", Title: "Late tonight", Start: at(1, 23, 0), End: at(1, 23, 30)},
+		{ID: "after-midnight", Title: "After midnight", Start: at(2, 0, 30), End: at(2, 1, 0)},
+		{ID: "last-thing", Title: "Last thing", Start: at(2, 23, 0), End: at(2, 23, 30)},
+		{ID: "next-night", Title: "Next night", Start: at(3, 0, 30), End: at(3, 1, 0)},
+	} {
+		task.Start, task.End = task.Start.UTC(), task.End.UTC()
+		task.UserID, task.Status = "u1", models.StatusScheduled
+		if err := q.CreateTask(ctx, task); err != nil {
+			t.Fatalf("CreateTask(%s) failed: %v", task.ID, err)
+		}
+	}
+
+	var sent []Notice
+	notifier := NotifierFunc(func(_ context.Context, n Notice) error {
+		sent = append(sent, n)
+		return nil
+	})
+	s := NewScheduler(q, notifier, NewSigner([]byte("secret"), "http://vesper.test", 24*time.Hour), time.Minute)
+	s.now = func() time.Time { return at(1, 21, 5) }
+	if err := s.RunOnce(ctx); err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+	if len(sent) != 1 || sent[0].PlanDate != "2025-06-02" {
+		t.Fatalf("Expected one notice for 2025-06-02, got %+v", sent)
+	}
+
+	var got []string
+	for _, task := range sent[0].Agenda {
+		got = append(got, task.ID)
+	}
+	if want := []string{"after-midnight", "last-thing"}; !slices.Equal(got, want) {
+		t.Errorf("Expected agenda %v, got %v", want, got)
+	}
+}
+
+func TestSchedulerSendsAfterScheduleEdit(t *testing.T) {
+	ctx := context.Background()
+	q := setupPlanningDB(t)
+	london, _ := time.LoadLocation("Europe/London")
+
+	schedule := models.PlanningSchedule{UserID: "u1", Timezone: "Europe/London", SendAt: "21:00", Enabled: true, UpdatedAt: time.Date(2025, 6, 1, 9, 0, 0, 0, london)}
+	if err := q.UpsertPlanningSchedule(ctx, schedule); err != nil {
+		t.Fatalf("UpsertPlanningSchedule failed: %v", err)
+	}
+	// the user changes their settings after tonight's send time, before the scheduler ran
+	schedule.UpdatedAt = time.Date(2025, 6, 1, 21, 30, 0, 0, london)
+	if err := q.UpsertPlanningSchedule(ctx, schedule); err != nil {
+		t.Fatalf("UpsertPlanningSchedule failed: %v", err)
+	}
+
+	var sent []Notice
+	notifier := NotifierFunc(func(_ context.Context, n Notice) error {
+		sent = append(sent, n)
+		return nil
+	})
+	s := NewScheduler(q, notifier, NewSigner([]byte("secret"), "http://vesper.test", 24*time.Hour), time.Minute)
+	s.now = func() time.Time { return time.Date(2025, 6, 1, 21, 31, 0, 0, london) }
+	if err := s.RunOnce(ctx); err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+	if len(sent) != 1 || sent[0].PlanDate != "2025-06-02" {
+		t.Fatalf("Expected tonight's notice despite the edit, got %+v", sent)
+	}
+}
+
+func TestSchedulerSendsConcurrently(t *testing.T) {
+	ctx := context.Background()
+	q := setupPlanningDB(t)
+	if err := q.CreateUser(ctx, models.User{ID: "u2", Username: "other"}, "hash"); err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+	for _, userID := range []string{"u1", "u2"} {
+		err := q.UpsertPlanningSchedule(ctx, models.PlanningSchedule{UserID: userID, Timezone: "UTC", SendAt: "21:00", Enabled: true, UpdatedAt: time.Date(2025, 6, 1, 9, 0, 0, 0, time.UTC)})
+		if err != nil {
+			t.Fatalf("UpsertPlanningSchedule failed: %v", err)
+		}
+	}
+
+	// each delivery only completes once both are in flight
+	var mu sync.Mutex
+	arrived := 0
+	both := make(chan struct{})
+	notifier := NotifierFunc(func(_ context.Context, n Notice) error {
+		mu.Lock()
+		if arrived++; arrived == 2 {
+			close(both)
+		}
+		mu.Unlock()
+		select {
+		case <-both:
+			return nil
+		case <-time.After(5 * time.Second):
+			return errors.New("delivered one user at a time")
+		}
+	})
+	s := NewScheduler(q, notifier, NewSigner([]byte("secret"), "http://vesper.test", 24*time.Hour), time.Minute)
+	s.now = func() time.Time { return time.Date(2025, 6, 1, 21, 5, 0, 0, time.UTC) }
+	if err := s.RunOnce(ctx); err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+
+	for _, userID := range []string{"u1", "u2"} {
+		session, err := q.EnsurePlanningSession(ctx, "unused", userID, "2025-06-02")
+		if err != nil {
+			t.Fatalf("EnsurePlanningSession failed: %v", err)
+		}
+		if session.NotifiedAt == nil {
+			t.Errorf("Expected %s's session marked notified", userID)
+		}
+	}
+}
+
+func TestSignerVerify(t *testing.T) {
+	signer := NewSigner([]byte("secret"), "http://vesper.test/", time.Hour)
+	now := time.Date(2025, 6, 1, 21, 0, 0, 0, time.UTC)
+
+	link, expires := signer.Link("abc", now)
+	u, _ := url.Parse(link)
+	params := u.Query()
+
+	if err := signer.Verify("abc", params.Get("expires"), params.Get("sig"), now); err != nil {
+		t.Errorf("Expected valid link, got %v", err)
+	}
+	if err := signer.Verify("other", params.Get("expires"), params.Get("sig"), now); err != ErrLinkSignature {
+		t.Errorf("Expected ErrLinkSignature for another session, got %v", err)
+	}
+	if err := signer.Verify("abc", params.Get("expires"), params.Get("sig"), expires.Add(time.Second)); err != ErrLinkExpired {
+		t.Errorf("Expected ErrLinkExpired, got %v", err)
+	}
+}
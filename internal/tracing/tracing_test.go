@@ -0,0 +1,72 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Adjanour/vesper/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestSetupContinuesIncomingTrace(t *testing.T) {
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+	defer otel.SetTextMapPropagator(otel.GetTextMapPropagator())
+
+	file := filepath.Join(t.TempDir(), "spans.json")
+	shutdown, err := Setup(context.Background(), config.Tracing{Exporter: "file", File: file})
+	if err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+
+	header := http.Header{"Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}}
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), propagation.HeaderCarrier(header))
+	_, span := otel.Tracer("test").Start(ctx, "GET /api/tasks/{id}")
+	span.End()
+
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+	spans, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("Failed to read exported spans: %v", err)
+	}
+	for _, want := range []string{
+		`"Name":"GET /api/tasks/{id}"`,
+		`"TraceID":"4bf92f3577b34da6a3ce929d0e0e4736"`,
+		`"SpanID":"00f067aa0ba902b7"`,
+		`"service.name"`,
+	} {
+		if !strings.Contains(string(spans), want) {
+			t.Errorf("Expected %s in the exported spans:\n%s", want, spans)
+		}
+	}
+}
+
+func TestSetupNone(t *testing.T) {
+	defer otel.SetTextMapPropagator(otel.GetTextMapPropagator())
+
+	shutdown, err := Setup(context.Background(), config.Tracing{Exporter: "none"})
+	if err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	defer shutdown(context.Background())
+
+	// spans are not recorded, but the incoming trace is still passed on
+	header := http.Header{"Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}}
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), propagation.HeaderCarrier(header))
+	outgoing := http.Header{}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(outgoing))
+	if got := outgoing.Get("traceparent"); !strings.Contains(got, "4bf92f3577b34da6a3ce929d0e0e4736") {
+		t.Errorf("Expected the trace to be propagated, got %q", got)
+	}
+
+	if _, err := Setup(context.Background(), config.Tracing{Exporter: "zipkin"}); err == nil {
+		t.Error("Expected an error for an unknown exporter")
+	}
+}
@@ -0,0 +1,63 @@
+// Package trash permanently removes tasks that have been deleted for longer
+// than the retention period.
+package trash
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/Adjanour/vesper/internal/database"
+	"github.com/Adjanour/vesper/internal/metrics"
+)
+
+// Purger empties the trash of tasks older than its retention on a fixed interval
+type Purger struct {
+	db        *database.Queries
+	retention time.Duration
+	interval  time.Duration
+	now       func() time.Time
+}
+
+// NewPurger creates a purger that checks the trash every interval
+func NewPurger(q *database.Queries, retention, interval time.Duration) *Purger {
+	return &Purger{
+		db:        q,
+		retention: retention,
+		interval:  interval,
+		now:       time.Now,
+	}
+}
+
+// Run purges expired tasks until ctx is cancelled
+func (p *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		start := time.Now()
+		err := p.RunOnce(ctx)
+		metrics.ObserveJob("trash_purge", start, err)
+		if err != nil {
+			slog.ErrorContext(ctx, "trash purge failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce deletes every task that has been in the trash longer than the retention
+func (p *Purger) RunOnce(ctx context.Context) error {
+	purged, err := p.db.PurgeTrash(ctx, p.now().Add(-p.retention))
+	if err != nil {
+		return err
+	}
+	if purged > 0 {
+		slog.InfoContext(ctx, "Purged deleted tasks", "count", purged)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package trash
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/Adjanour/vesper/internal/database"
+	"github.com/Adjanour/vesper/internal/models"
+	_ "modernc.org/sqlite"
+)
+
+func setupTrashDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", ":memory:?_pragma=foreign_keys(1)")
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	files, _ := filepath.Glob("../database/migrations/*.up.sql")
+	sort.Strings(files)
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		if _, err := db.Exec(string(content)); err != nil {
+			t.Fatalf("Failed to apply %s: %v", file, err)
+		}
+	}
+	if _, err := db.Exec(`INSERT INTO users (id, username) VALUES ('u1', 'tidy')`); err != nil {
+		t.Fatalf("Failed to insert test user: %v", err)
+	}
+	return db
+}
+
+func TestPurgerRemovesExpiredTrash(t *testing.T) {
+	ctx := context.Background()
+	db := setupTrashDB(t)
+	q := database.NewQueries(db)
+
+	start := time.Date(2026, 2, 8, 9, 0, 0, 0, time.UTC)
+	for i, id := range []string{"old", "recent", "kept"} {
+		err := q.CreateTask(ctx, models.Task{
+			ID:         id,
+			Title:      id,
+			Start:      start.Add(time.Duration(i) * time.Hour),
+			End:        start.Add(time.Duration(i)*time.Hour + 30*time.Minute),
+			UserID:     "u1",
+			Status:     models.StatusScheduled,
+			Recurrence: "FREQ=DAILY;COUNT=3",
+		})
+		if err != nil {
+			t.Fatalf("CreateTask(%s) failed: %v", id, err)
+		}
+	}
+	for _, id := range []string{"old", "recent"} {
+		if err := q.DeleteTask(ctx, id, 0); err != nil {
+			t.Fatalf("DeleteTask(%s) failed: %v", id, err)
+		}
+	}
+
+	now := time.Now().UTC()
+	if _, err := db.Exec(`UPDATE tasks SET deleted_at = ? WHERE id = 'old'`, now.Add(-48*time.Hour)); err != nil {
+		t.Fatalf("Failed to backdate deletion: %v", err)
+	}
+
+	p := NewPurger(q, 24*time.Hour, time.Hour)
+	p.now = func() time.Time { return now }
+	if err := p.RunOnce(ctx); err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+
+	if _, err := q.GetTask(ctx, "old"); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("Expected old to be purged, got %v", err)
+	}
+	for _, id := range []string{"recent", "kept"} {
+		if _, err := q.GetTask(ctx, id); err != nil {
+			t.Errorf("Expected %s to be kept, got %v", id, err)
+		}
+	}
+
+	var rules int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM task_recurrences`).Scan(&rules); err != nil || rules != 2 {
+		t.Errorf("Expected the purged task's recurrence to go with it, %d left (%v)", rules, err)
+	}
+	var history int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM task_revisions WHERE task_id = 'old'`).Scan(&history); err != nil || history != 0 {
+		t.Errorf("Expected the purged task's revisions to go with it, %d left (%v)", history, err)
+	}
+	if revisions, err := q.ListRevisions(ctx, "recent"); err != nil || len(revisions) != 2 {
+		t.Errorf("Expected the trashed task to keep its 2 revisions, got %d (%v)", len(revisions), err)
+	}
+
+	// outside a purge, the history stays append-only
+	if _, err := db.Exec(`DELETE FROM task_revisions WHERE task_id = 'kept'`); err == nil {
+		t.Error("Expected deleting a live task's revisions to be refused")
+	}
+}
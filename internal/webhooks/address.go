@@ -0,0 +1,60 @@
+package webhooks
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+)
+
+// ErrPrivateAddress is returned for a receiver on a loopback, private,
+// link-local or unspecified address
+var ErrPrivateAddress = errors.New("address is not publicly routable")
+
+// publicAddr reports whether ip may receive webhooks
+func publicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	// global unicast already excludes loopback, link-local, multicast and unspecified
+	return ip.IsGlobalUnicast() && !ip.IsPrivate()
+}
+
+// CheckURL refuses a receiver whose host is localhost or an internal IP
+// literal. Other hosts are checked when each delivery connects, since a
+// name can resolve somewhere else by then.
+func (d *Dispatcher) CheckURL(u *url.URL) error {
+	if d.AllowPrivateNetworks {
+		return nil
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, host)
+	}
+	if ip, err := netip.ParseAddr(host); err == nil && !publicAddr(ip) {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, ip)
+	}
+	return nil
+}
+
+// checkDial is the dialer's Control hook. It sees the resolved address of
+// every connection, so a name that resolves, or is later rebound, to an
+// internal address is refused before anything is sent.
+func (d *Dispatcher) checkDial(_, address string, _ syscall.RawConn) error {
+	if d.AllowPrivateNetworks {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !publicAddr(ip) {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, ip)
+	}
+	return nil
+}
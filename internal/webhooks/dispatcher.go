@@ -0,0 +1,255 @@
+// Package webhooks posts task events to the URLs users subscribe.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Adjanour/vesper/internal/database"
+	"github.com/Adjanour/vesper/internal/events"
+	"github.com/Adjanour/vesper/internal/metrics"
+	"github.com/Adjanour/vesper/internal/models"
+)
+
+const (
+	// batchSize caps how many deliveries one pass sends
+	batchSize = 50
+)
+
+// Dispatcher records a delivery for every webhook an event matches and sends
+// them in the background, retrying failures with exponential backoff.
+//
+// Deliveries live in webhook_deliveries, so pending retries survive a restart.
+type Dispatcher struct {
+	db       *database.Queries
+	client   *http.Client
+	interval time.Duration
+	wake     chan struct{}
+	now      func() time.Time
+
+	// MaxAttempts is the total number of tries before a delivery is marked failed.
+	MaxAttempts int
+	// AllowPrivateNetworks lets webhooks reach loopback, private and
+	// link-local addresses, for receivers on the server's own network.
+	AllowPrivateNetworks bool
+	// Backoff is the wait before the first retry; it doubles after each one up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// NewDispatcher creates a dispatcher that looks for due retries every interval
+func NewDispatcher(q *database.Queries, interval time.Duration) *Dispatcher {
+	d := &Dispatcher{
+		db:          q,
+		interval:    interval,
+		wake:        make(chan struct{}, 1),
+		now:         time.Now,
+		MaxAttempts: 8,
+		Backoff:     30 * time.Second,
+		MaxBackoff:  6 * time.Hour,
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// connect straight to the receiver, so the address check sees it rather than a proxy
+	transport.Proxy = nil
+	transport.DialContext = (&net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   d.checkDial,
+	}).DialContext
+	d.client = &http.Client{
+		Transport: transport,
+		Timeout:   10 * time.Second,
+		// a redirect is a misconfigured URL, not a delivery
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return d
+}
+
+// Enqueue records a pending delivery of e for each of the owner's webhooks
+// subscribed to its type, and wakes the dispatcher to send them.
+func (d *Dispatcher) Enqueue(ctx context.Context, e events.Event) error {
+	hooks, err := d.db.ListWebhooks(ctx, e.UserID)
+	if err != nil {
+		return err
+	}
+
+	var payload []byte
+	now := d.now().UTC()
+	queued := false
+	for _, hook := range hooks {
+		if !hook.Wants(string(e.Type)) {
+			continue
+		}
+		if payload == nil {
+			if payload, err = json.Marshal(e); err != nil {
+				return err
+			}
+		}
+		delivery := models.WebhookDelivery{
+			ID:            newDeliveryID(),
+			WebhookID:     hook.ID,
+			EventID:       e.ID,
+			EventType:     string(e.Type),
+			Payload:       payload,
+			Status:        models.DeliveryPending,
+			NextAttemptAt: &now,
+			CreatedAt:     now,
+		}
+		if err := d.db.CreateWebhookDelivery(ctx, delivery); err != nil {
+			return err
+		}
+		queued = true
+	}
+
+	if queued {
+		select {
+		case d.wake <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Run sends due deliveries immediately, then every interval and whenever new
+// ones are queued, until ctx is cancelled
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		start := time.Now()
+		err := d.RunOnce(ctx)
+		metrics.ObserveJob("webhook_dispatcher", start, err)
+		if err != nil {
+			slog.ErrorContext(ctx, "webhook dispatch failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+// RunOnce attempts every delivery that is due
+func (d *Dispatcher) RunOnce(ctx context.Context) error {
+	for {
+		due, err := d.db.ListDueWebhookDeliveries(ctx, d.now(), batchSize)
+		if err != nil {
+			return err
+		}
+		for _, delivery := range due {
+			if err := d.attempt(ctx, delivery); err != nil {
+				return err
+			}
+		}
+		// a full batch may have left more behind it
+		if len(due) < batchSize {
+			return nil
+		}
+	}
+}
+
+// attempt makes one try at a delivery and records the outcome. The error is
+// reserved for storage failures; an unreachable receiver is a failed attempt.
+func (d *Dispatcher) attempt(ctx context.Context, delivery *models.WebhookDelivery) error {
+	hook, err := d.db.GetWebhook(ctx, delivery.WebhookID)
+	if errors.Is(err, database.ErrNotFound) {
+		// the webhook was deleted after the event was queued
+		delivery.Status = models.DeliveryFailed
+		delivery.NextAttemptAt = nil
+		delivery.LastError = "webhook deleted"
+		return d.db.UpdateWebhookDelivery(ctx, *delivery)
+	}
+	if err != nil {
+		return err
+	}
+
+	status, sendErr := d.send(ctx, hook, delivery)
+	now := d.now().UTC()
+	delivery.Attempts++
+	delivery.ResponseStatus = status
+	delivery.LastError = ""
+	switch {
+	case sendErr == nil:
+		delivery.Status = models.DeliveryDelivered
+		delivery.NextAttemptAt = nil
+		delivery.DeliveredAt = &now
+	case delivery.Attempts >= d.MaxAttempts:
+		delivery.Status = models.DeliveryFailed
+		delivery.NextAttemptAt = nil
+		delivery.LastError = sendErr.Error()
+	default:
+		next := now.Add(d.backoff(delivery.Attempts))
+		delivery.NextAttemptAt = &next
+		delivery.LastError = sendErr.Error()
+	}
+	metrics.CountWebhookDelivery(string(delivery.Status))
+	if sendErr != nil {
+		slog.WarnContext(ctx, "webhook delivery failed", "delivery_id", delivery.ID, "url", hook.URL, "attempt", delivery.Attempts, "error", sendErr)
+	}
+	return d.db.UpdateWebhookDelivery(ctx, *delivery)
+}
+
+// send posts the delivery and returns the receiver's status code, if any
+func (d *Dispatcher) send(ctx context.Context, hook *models.Webhook, delivery *models.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	now := d.now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Vesper-Webhooks/1.0")
+	req.Header.Set("X-Vesper-Event", delivery.EventType)
+	req.Header.Set("X-Vesper-Delivery", delivery.ID)
+	req.Header.Set("X-Vesper-Timestamp", strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set("X-Vesper-Signature", Sign(hook.Secret, now, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp.StatusCode, nil
+	}
+	// only the status is logged, so the delivery log cannot be used to read
+	// what an address answers
+	return resp.StatusCode, fmt.Errorf("receiver responded %s", resp.Status)
+}
+
+// backoff is the wait after the given number of failed attempts
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	wait := d.Backoff
+	for i := 1; i < attempts; i++ {
+		wait *= 2
+		if d.MaxBackoff > 0 && wait >= d.MaxBackoff {
+			return d.MaxBackoff
+		}
+	}
+	return wait
+}
+
+func newDeliveryID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
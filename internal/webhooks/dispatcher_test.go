@@ -0,0 +1,262 @@
+package webhooks
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Adjanour/vesper/internal/database"
+	"github.com/Adjanour/vesper/internal/events"
+	"github.com/Adjanour/vesper/internal/models"
+	_ "modernc.org/sqlite"
+)
+
+func setupWebhookDB(t *testing.T) *database.Queries {
+	t.Helper()
+	db, err := sql.Open("sqlite", ":memory:?_pragma=foreign_keys(1)")
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	files, _ := filepath.Glob("../database/migrations/*.up.sql")
+	sort.Strings(files)
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		if _, err := db.Exec(string(content)); err != nil {
+			t.Fatalf("Failed to apply %s: %v", file, err)
+		}
+	}
+	if _, err := db.Exec(`INSERT INTO users (id, username) VALUES ('u1', 'hooker')`); err != nil {
+		t.Fatalf("Failed to insert test user: %v", err)
+	}
+	return database.NewQueries(db)
+}
+
+func TestDispatcherSignsAndDelivers(t *testing.T) {
+	ctx := context.Background()
+	q := setupWebhookDB(t)
+
+	var mu sync.Mutex
+	var received []*http.Request
+	var bodies [][]byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		received = append(received, r)
+		bodies = append(bodies, body)
+		mu.Unlock()
+	}))
+	defer receiver.Close()
+
+	hook := models.Webhook{
+		ID:        "h1",
+		UserID:    "u1",
+		URL:       receiver.URL,
+		Events:    []string{"task.created"},
+		Secret:    "0123456789abcdef",
+		CreatedAt: time.Now(),
+	}
+	if err := q.CreateWebhook(ctx, hook); err != nil {
+		t.Fatalf("CreateWebhook failed: %v", err)
+	}
+
+	d := NewDispatcher(q, time.Minute)
+	d.AllowPrivateNetworks = true // the receiver listens on loopback
+	if err := d.Enqueue(ctx, events.Event{ID: "e-1", Type: events.TaskCreated, UserID: "u1", TaskID: "t1"}); err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	// not subscribed to deletions, and other users' events are never delivered
+	_ = d.Enqueue(ctx, events.Event{ID: "e-2", Type: events.TaskDeleted, UserID: "u1", TaskID: "t1"})
+	_ = d.Enqueue(ctx, events.Event{ID: "e-3", Type: events.TaskCreated, UserID: "u2", TaskID: "t2"})
+
+	if err := d.RunOnce(ctx); err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+	if len(received) != 1 {
+		t.Fatalf("Expected 1 delivery, got %d", len(received))
+	}
+	r := received[0]
+	if r.Header.Get("X-Vesper-Event") != "task.created" {
+		t.Errorf("Expected event header task.created, got %q", r.Header.Get("X-Vesper-Event"))
+	}
+	if !Verify(hook.Secret, r.Header.Get("X-Vesper-Timestamp"), r.Header.Get("X-Vesper-Signature"), bodies[0]) {
+		t.Errorf("Signature %q does not verify", r.Header.Get("X-Vesper-Signature"))
+	}
+	if Verify("wrong-secret-value", r.Header.Get("X-Vesper-Timestamp"), r.Header.Get("X-Vesper-Signature"), bodies[0]) {
+		t.Error("Signature verified with the wrong secret")
+	}
+
+	deliveries, err := q.ListWebhookDeliveries(ctx, "h1", 10)
+	if err != nil {
+		t.Fatalf("ListWebhookDeliveries failed: %v", err)
+	}
+	if len(deliveries) != 1 || deliveries[0].Status != models.DeliveryDelivered || deliveries[0].Attempts != 1 || deliveries[0].ResponseStatus != http.StatusOK {
+		t.Errorf("Unexpected delivery log: %+v", deliveries[0])
+	}
+
+	// delivered events are not sent again
+	if err := d.RunOnce(ctx); err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+	if len(received) != 1 {
+		t.Errorf("Expected no redelivery, got %d deliveries", len(received))
+	}
+}
+
+func TestDispatcherRetriesWithBackoff(t *testing.T) {
+	ctx := context.Background()
+	q := setupWebhookDB(t)
+
+	calls := 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		http.Error(w, "down for maintenance", http.StatusServiceUnavailable)
+	}))
+	defer receiver.Close()
+
+	err := q.CreateWebhook(ctx, models.Webhook{
+		ID: "h1", UserID: "u1", URL: receiver.URL, Events: []string{"task.updated"},
+		Secret: "0123456789abcdef", CreatedAt: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("CreateWebhook failed: %v", err)
+	}
+
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	d := NewDispatcher(q, time.Minute)
+	d.now = func() time.Time { return now }
+	d.AllowPrivateNetworks = true
+	d.MaxAttempts = 3
+	d.Backoff = time.Minute
+
+	if err := d.Enqueue(ctx, events.Event{ID: "e-1", Type: events.TaskUpdated, UserID: "u1", TaskID: "t1"}); err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+
+	delivery := func() *models.WebhookDelivery {
+		deliveries, err := q.ListWebhookDeliveries(ctx, "h1", 1)
+		if err != nil || len(deliveries) != 1 {
+			t.Fatalf("ListWebhookDeliveries: %v, %d rows", err, len(deliveries))
+		}
+		return deliveries[0]
+	}
+
+	_ = d.RunOnce(ctx)
+	got := delivery()
+	if got.Status != models.DeliveryPending || got.NextAttemptAt == nil || !got.NextAttemptAt.Equal(now.Add(time.Minute)) {
+		t.Fatalf("Expected a retry in 1m, got %+v", got)
+	}
+	// the status is logged, the receiver's body is not
+	if got.ResponseStatus != http.StatusServiceUnavailable || got.LastError != "receiver responded 503 Service Unavailable" {
+		t.Errorf("Expected the 503 to be logged, got %+v", got)
+	}
+
+	// nothing is due until the backoff has passed
+	now = now.Add(30 * time.Second)
+	_ = d.RunOnce(ctx)
+	if calls != 1 {
+		t.Fatalf("Expected no attempt during backoff, got %d calls", calls)
+	}
+
+	now = now.Add(30 * time.Second)
+	_ = d.RunOnce(ctx)
+	if got := delivery(); !got.NextAttemptAt.Equal(now.Add(2 * time.Minute)) {
+		t.Fatalf("Expected the backoff to double, got %v", got.NextAttemptAt)
+	}
+
+	now = now.Add(2 * time.Minute)
+	_ = d.RunOnce(ctx)
+	got = delivery()
+	if got.Status != models.DeliveryFailed || got.Attempts != 3 || got.NextAttemptAt != nil {
+		t.Errorf("Expected failure after 3 attempts, got %+v", got)
+	}
+	if calls != 3 {
+		t.Errorf("Expected 3 calls, got %d", calls)
+	}
+}
+
+func TestDispatcherRefusesPrivateAddresses(t *testing.T) {
+	ctx := context.Background()
+	q := setupWebhookDB(t)
+
+	calls := 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer receiver.Close()
+
+	// a name that resolves to loopback gets past CheckURL; the dial is refused all the same
+	err := q.CreateWebhook(ctx, models.Webhook{
+		ID: "h1", UserID: "u1", URL: receiver.URL, Events: []string{"task.created"},
+		Secret: "0123456789abcdef", CreatedAt: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("CreateWebhook failed: %v", err)
+	}
+
+	d := NewDispatcher(q, time.Minute)
+	if err := d.Enqueue(ctx, events.Event{ID: "e-1", Type: events.TaskCreated, UserID: "u1", TaskID: "t1"}); err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	if err := d.RunOnce(ctx); err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+	if calls != 0 {
+		t.Errorf("Expected no request to a loopback receiver, got %d", calls)
+	}
+	deliveries, err := q.ListWebhookDeliveries(ctx, "h1", 1)
+	if err != nil || len(deliveries) != 1 {
+		t.Fatalf("ListWebhookDeliveries: %v, %d rows", err, len(deliveries))
+	}
+	if got := deliveries[0]; got.Status != models.DeliveryPending || !strings.Contains(got.LastError, ErrPrivateAddress.Error()) {
+		t.Errorf("Expected a refused attempt, got %+v", got)
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	d := NewDispatcher(nil, time.Minute)
+	tests := []struct {
+		url     string
+		allowed bool
+	}{
+		{"https://hooks.example.com/vesper", true},
+		{"https://93.184.216.34/hook", true},
+		{"http://localhost:8080/hook", false},
+		{"http://api.localhost/hook", false},
+		{"http://127.0.0.1/hook", false},
+		{"http://10.1.2.3/hook", false},
+		{"http://192.168.0.10/hook", false},
+		{"http://169.254.169.254/latest/meta-data", false},
+		{"http://0.0.0.0/hook", false},
+		{"http://[::1]/hook", false},
+		{"http://[fd00::1]/hook", false},
+		{"http://[::ffff:127.0.0.1]/hook", false},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.url)
+		if err := d.CheckURL(u); (err == nil) != tt.allowed {
+			t.Errorf("CheckURL(%s) = %v, want allowed %v", tt.url, err, tt.allowed)
+		}
+	}
+
+	d.AllowPrivateNetworks = true
+	u, _ := url.Parse("http://127.0.0.1/hook")
+	if err := d.CheckURL(u); err != nil {
+		t.Errorf("CheckURL() with private networks allowed = %v", err)
+	}
+}
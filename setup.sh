@@ -35,7 +35,7 @@ echo "✓ Dependencies installed"
 echo ""
 echo "🗄️  Setting up database..."
 mkdir -p ./data
-go run ./internal/database/migrate/migrate.go up
+go run ./cmd/migrate up
 echo "✓ Database migrations applied"
 
 # Build the project